@@ -0,0 +1,46 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/task/cleaner"
+	"log"
+	"os"
+)
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	section := flag.String("s", constant.CKDBMain, "(optional) DB Section")
+	window := flag.String("w", "", "(optional) Time window HH:MM-HH:MM to start rebuilds in, replaces the config")
+	concurrency := flag.Int("c", 0, "(optional) Number of tables rebuilt at once, replaces the config")
+	method := flag.String("m", "", "(optional) optimize or force, replaces the config")
+	dryRun := flag.Bool("dry-run", false, "(optional) Report the tables to be rebuilt without changing them")
+	flag.Parse()
+
+	task := cleaner.NewDbTableDefragmenter(*section, *dryRun)
+
+	if len(*window) > 0 {
+		if err := task.Window(*window); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if *concurrency > 0 {
+		task.Concurrency(*concurrency)
+	}
+
+	if len(*method) > 0 {
+		task.Method(*method)
+	}
+
+	if !task.Execute() {
+		log.Fatalln("table defragmenter failed")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/task/cleaner"
+	"log"
+	"os"
+	"strings"
+)
+
+func patterns(s string) []string {
+
+	ps := make([]string, 0)
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+func newTask(section, include, exclude string, dryRun bool) *cleaner.DbTableOptimizer {
+
+	task := cleaner.NewDbTableOptimizer(section, dryRun)
+
+	if ps := patterns(include); len(ps) > 0 {
+		task.Include(ps...)
+	}
+	task.Exclude(patterns(exclude)...)
+
+	return task
+}
+
+func confirm() bool {
+
+	fmt.Print("\nrebuild the planned tables? [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	section := flag.String("s", constant.CKDBMain, "(optional) DB Section")
+	include := flag.String("i", "", "(optional) Comma separated include glob patterns, replaces the config")
+	exclude := flag.String("e", "", "(optional) Comma separated exclude glob patterns, added to the config")
+	dryRun := flag.Bool("dry-run", false, "(optional) Report the tables to be rebuilt without changing them")
+	yes := flag.Bool("y", false, "(optional) Do not ask for confirmation")
+	flag.Parse()
+
+	if !*dryRun && !*yes {
+
+		plan := newTask(*section, *include, *exclude, true)
+		plan.Execute()
+
+		if !confirm() {
+			return
+		}
+	}
+
+	task := newTask(*section, *include, *exclude, *dryRun)
+	if !task.Execute() {
+		log.Fatalln("table optimizer failed")
+	}
+}
@@ -6,6 +6,9 @@ replace github.com/alcomist/go-portfolio/internal => ./../internal
 
 replace github.com/alcomist/go-portfolio/task => ./../task
 
+// gin-contrib/sessions requires the retracted v2 tag, the modules share v1.14.22
+replace github.com/mattn/go-sqlite3 => github.com/mattn/go-sqlite3 v1.14.22
+
 require (
 	github.com/bytedance/sonic v1.8.0 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
@@ -0,0 +1,33 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/task/index_dumper"
+	"log"
+)
+
+func main() {
+
+	cluster := flag.String("c", constant.CKECMain, "(optional) ES Cluster Section")
+	index := flag.String("i", "", "(required) Index Name")
+	dir := flag.String("d", ".", "(optional) Dump Directory")
+	query := flag.String("q", "", "(optional) Search body limiting the docs, e.g. {\"query\":{...}}")
+	size := flag.Int("b", index_dumper.DefaultPageSize, "(optional) Page Size")
+	routing := flag.Bool("r", false, "(optional) Keep the routing of the docs")
+	flag.Parse()
+
+	if len(*index) == 0 {
+		flag.Usage()
+		return
+	}
+
+	task := index_dumper.NewDumper(*cluster, *index, *dir, *query, *size, *routing)
+	if !task.Execute() {
+		log.Fatalln("index dump failed")
+	}
+}
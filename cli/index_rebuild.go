@@ -0,0 +1,45 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/task/index_rebuilder"
+	"log"
+	"os"
+)
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	cluster := flag.String("c", constant.CKECMain, "(optional) ES Cluster Section")
+	alias := flag.String("a", "", "(required) Alias Name")
+	sourceCluster := flag.String("s", "", "(optional) Source ES Cluster Section of rebuild, the cluster by default")
+	sourceIndex := flag.String("i", "", "(optional) Source Index of rebuild")
+	template := flag.String("t", "", "(optional) Template File (<kind>/<name>.json) of the new generation, the source index mapping by default")
+	keep := flag.Int("k", 2, "(optional) Previous generations kept for rollback")
+	minDocs := flag.Int64("min", 1, "(optional) Minimum doc count of the new generation")
+	minRatio := flag.Float64("ratio", 0.9, "(optional) Minimum doc count ratio of the new generation to the current one")
+	replace := flag.Bool("replace", false, "(optional) Delete the index named as the alias in the swap")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -a alias [options] rebuild | rollback | status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(*alias) == 0 || flag.NArg() == 0 {
+		flag.Usage()
+		return
+	}
+
+	task := index_rebuilder.New(*cluster, *alias, *sourceCluster, *sourceIndex, *template, *keep, *minDocs, *minRatio, *replace)
+	if !task.Execute(flag.Arg(0)) {
+		log.Fatalln("index rebuild failed")
+	}
+}
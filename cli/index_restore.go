@@ -0,0 +1,46 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/task/index_dumper"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	cluster := flag.String("c", constant.CKECMain, "(optional) ES Cluster Section")
+	index := flag.String("i", "", "(required) Dumped Index Name")
+	dir := flag.String("d", ".", "(optional) Dump Directory")
+	targetIndex := flag.String("ti", "", "(optional) Target Index Name, the dumped index by default")
+	includes := flag.String("fields", "", "(optional) Comma separated fields to restore, dotted for object fields")
+	excludes := flag.String("exclude", "", "(optional) Comma separated fields not to restore")
+	appendDocs := flag.Bool("a", false, "(optional) Restore into an existing index")
+	flag.Parse()
+
+	if len(*index) == 0 {
+		flag.Usage()
+		return
+	}
+
+	split := func(s string) []string {
+		if len(s) == 0 {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
+
+	task := index_dumper.NewRestorer(*cluster, *index, *dir, *targetIndex, split(*includes), split(*excludes), *appendDocs)
+	if !task.Execute() {
+		log.Fatalln("index restore failed")
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/task/mapping_diff"
+	"log"
+)
+
+func main() {
+
+	cluster := flag.String("c", constant.CKECMain, "(optional) ES Cluster Section")
+	index := flag.String("i", "", "(required) Index Name")
+	targetCluster := flag.String("tc", "", "(optional) Target ES Cluster Section, the cluster by default")
+	targetIndex := flag.String("ti", "", "(optional) Target Index Name")
+	template := flag.String("tt", "", "(optional) Target Template Name, used instead of the target index")
+	kind := flag.String("k", es.TemplateLegacy, "(optional) Template Kind (legacy, composable, component)")
+	flag.Parse()
+
+	if len(*index) == 0 || (len(*targetIndex) == 0 && len(*template) == 0) {
+		flag.Usage()
+		return
+	}
+
+	task := mapping_diff.New(*cluster, *index, *targetCluster, *targetIndex, *template, *kind)
+	if !task.Execute() {
+		log.Fatalln("mapping diff failed")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"github.com/alcomist/go-portfolio/task/migrator"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+
+	section := flag.String("s", "", "(required) DB Section")
+	dir := flag.String("d", filepath.Join(util.ExecutableDir(), "migrations"), "(optional) Migration Directory")
+	dryRun := flag.Bool("n", false, "(optional) Dry run, print the statements only")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -s section [options] up | down [N] | status | redo\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(*section) == 0 || flag.NArg() == 0 {
+		flag.Usage()
+		return
+	}
+
+	task := migrator.New(*section, *dir, *dryRun)
+	if !task.Execute(flag.Arg(0), flag.Args()[1:]...) {
+		log.Fatalln("migration failed")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"github.com/alcomist/go-portfolio/task/reindexer"
+	"log"
+	"path/filepath"
+)
+
+func main() {
+
+	source := flag.String("s", "", "(required) Source ES Cluster Section")
+	target := flag.String("t", "", "(required) Target ES Cluster Section")
+	index := flag.String("i", "", "(required) Index Name")
+	targetIndex := flag.String("ti", "", "(optional) Target Index Name, the same as the source by default")
+	query := flag.String("q", "", "(optional) Search body limiting the docs, e.g. {\"query\":{...}}")
+	tiebreaker := flag.String("tb", "_id", "(optional) Unique Field sorting the pages, keeps the checkpoint valid")
+	size := flag.Int("b", reindexer.DefaultPageSize, "(optional) Page Size")
+	dir := flag.String("d", filepath.Join(util.ExecutableDir(), "checkpoint"), "(optional) Checkpoint Directory")
+	resume := flag.Bool("r", false, "(optional) Resume from the saved checkpoint")
+	verify := flag.Bool("v", false, "(optional) Verify doc counts after the reindex")
+	flag.Parse()
+
+	if len(*source) == 0 || len(*target) == 0 || len(*index) == 0 {
+		flag.Usage()
+		return
+	}
+
+	task := reindexer.New(*source, *target, *index, *targetIndex, *dir, *size, *resume, *verify)
+	task.Query(*query)
+	task.Tiebreaker(*tiebreaker)
+
+	if !task.Execute() {
+		log.Fatalln("reindex failed")
+	}
+}
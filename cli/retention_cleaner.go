@@ -0,0 +1,27 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/task/retention"
+	"log"
+	"os"
+)
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	name := flag.String("p", "", "(optional) Policy (config section) to apply, every policy by default")
+	dryRun := flag.Bool("dry-run", false, "(optional) Report the tables and indices to be deleted without deleting them")
+	flag.Parse()
+
+	task := retention.New(*name, *dryRun)
+	if !task.Execute() {
+		log.Fatalln("retention cleaner failed")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/task/schema_diff"
+	"log"
+)
+
+func main() {
+
+	target := flag.String("t", "", "(required) Target DB Section (the one to be altered)")
+	source := flag.String("s", "", "(optional) Source DB Section")
+	dir := flag.String("d", "", "(optional) DDL Directory (*.sql), used when no source section")
+	prefix := flag.String("p", "", "(optional) Table Prefix")
+	drop := flag.Bool("drop", false, "(optional) Print DROP TABLE / DROP COLUMN statements")
+	flag.Parse()
+
+	if len(*target) == 0 || (len(*source) == 0 && len(*dir) == 0) {
+		flag.Usage()
+		return
+	}
+
+	task := schema_diff.New(*target, *source, *dir, *prefix, *drop)
+	if !task.Execute() {
+		log.Fatalln("schema diff failed")
+	}
+}
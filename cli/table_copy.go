@@ -0,0 +1,37 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"github.com/alcomist/go-portfolio/task/table_copier"
+	"log"
+	"path/filepath"
+)
+
+func main() {
+
+	source := flag.String("s", "", "(required) Source DB Section")
+	target := flag.String("t", "", "(required) Target DB Section")
+	table := flag.String("n", "", "(required) Table Name")
+	targetTable := flag.String("tn", "", "(optional) Target Table Name, the same as the source by default")
+	key := flag.String("k", "", "(optional) Unique key column to copy by, the primary key by default")
+	size := flag.Int("b", table_copier.DefaultBatchSize, "(optional) Batch Size")
+	dir := flag.String("d", filepath.Join(util.ExecutableDir(), "checkpoint"), "(optional) Checkpoint Directory")
+	resume := flag.Bool("r", false, "(optional) Resume from the saved checkpoint")
+	verify := flag.Bool("v", false, "(optional) Verify row counts and chunk checksums after the copy")
+	flag.Parse()
+
+	if len(*source) == 0 || len(*target) == 0 || len(*table) == 0 {
+		flag.Usage()
+		return
+	}
+
+	task := table_copier.New(*source, *target, *table, *targetTable, *key, *dir, *size, *resume, *verify)
+	if !task.Execute() {
+		log.Fatalln("table copy failed")
+	}
+}
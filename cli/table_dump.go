@@ -0,0 +1,54 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/task/table_dumper"
+	"log"
+	"strings"
+)
+
+func main() {
+
+	section := flag.String("s", "", "(required) DB Section")
+	table := flag.String("t", "", "(required) Table Name")
+	dir := flag.String("d", ".", "(optional) Dump Directory")
+	format := flag.String("f", table_dumper.FormatCSV, "(optional) Format (csv, jsonl, sql)")
+	compress := flag.Bool("z", false, "(optional) Gzip the data file (export)")
+	where := flag.String("w", "", "(optional) WHERE condition (export)")
+	key := flag.String("k", "", "(optional) Unique key column to stream by, the primary key by default (export)")
+	size := flag.Int("b", table_dumper.DefaultBatchSize, "(optional) Batch Size")
+	load := flag.Bool("i", false, "(optional) Import the dump instead of exporting")
+	mode := flag.String("m", table_dumper.ModeAppend, "(optional) Import Mode (truncate, append, upsert)")
+	conflicts := flag.String("c", "", "(optional) Comma separated unique key columns of upsert (import, not needed on mysql)")
+	flag.Parse()
+
+	if len(*section) == 0 || len(*table) == 0 {
+		flag.Usage()
+		return
+	}
+
+	if *load {
+
+		cs := make([]string, 0)
+		for _, c := range strings.Split(*conflicts, ",") {
+			if c = strings.TrimSpace(c); len(c) > 0 {
+				cs = append(cs, c)
+			}
+		}
+
+		task := table_dumper.NewImporter(*section, *table, *dir, *format, *mode, cs, *size)
+		if !task.Execute() {
+			log.Fatalln("table import failed")
+		}
+		return
+	}
+
+	task := table_dumper.NewExporter(*section, *table, *dir, *format, *where, *key, *compress, *size)
+	if !task.Execute() {
+		log.Fatalln("table export failed")
+	}
+}
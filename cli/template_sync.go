@@ -0,0 +1,32 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/glog"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"github.com/alcomist/go-portfolio/task/template_syncer"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+
+	defer glog.Set(os.Args[0])()
+
+	cluster := flag.String("c", constant.CKECMain, "(optional) ES Cluster Section")
+	dir := flag.String("d", filepath.Join(util.ExecutableDir(), "templates"), "(optional) Template Directory (legacy, composable, component)")
+	apply := flag.Bool("apply", false, "(optional) Apply the changes, only report them by default")
+	prune := flag.Bool("prune", false, "(optional) Delete the cluster templates without a file, the managed ones are kept")
+	flag.Parse()
+
+	task := template_syncer.New(*cluster, *dir, *apply, *prune)
+	if !task.Execute() {
+		log.Fatalln("template sync failed")
+	}
+}
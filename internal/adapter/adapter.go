@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"github.com/alcomist/go-portfolio/internal/es"
 	"github.com/alcomist/go-portfolio/internal/util"
+	"io"
 	"log"
 )
 
@@ -66,9 +67,41 @@ func (a *Adapter) AdaptHashData(in, tn string) {
 	}
 }
 
-func (a *Adapter) DocUpsertData() []byte {
+// writePair writes the action line and the source line of a bulk item
+func writePair(w io.Writer, action map[string]any, source map[string]any) error {
+
+	h, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+
+	d, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
 
 	var b bytes.Buffer
+	b.Write(h)
+	b.WriteString("\n")
+	b.Write(d)
+	b.WriteString("\n")
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// bulkData collects the bulk items of write in memory
+func bulkData(write func(w io.Writer) error) []byte {
+
+	var b bytes.Buffer
+	if err := write(&b); err != nil {
+		log.Println(err)
+	}
+	return b.Bytes()
+}
+
+// WriteDocUpsert streams the docs as update / doc_as_upsert items into w (an es.BulkWriter)
+func (a *Adapter) WriteDocUpsert(w io.Writer) error {
 
 	for _, doc := range a.Result.Docs {
 
@@ -76,34 +109,25 @@ func (a *Adapter) DocUpsertData() []byte {
 
 		header["update"] = doc.Meta
 
-		h, err := json.Marshal(header)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
 		data := make(map[string]any)
 		data["doc"] = doc.Source.Map()
 		data["doc_as_upsert"] = true
 
-		d, err := json.Marshal(data)
-		if err != nil {
-			log.Println(err)
-			continue
+		if err := writePair(w, header, data); err != nil {
+			return err
 		}
-
-		b.Write(h)
-		b.WriteString("\n")
-		b.Write(d)
-		b.WriteString("\n")
 	}
 
-	return b.Bytes()
+	return nil
 }
 
-func (a *Adapter) ScriptedUpsertData() []byte {
+func (a *Adapter) DocUpsertData() []byte {
 
-	var b bytes.Buffer
+	return bulkData(a.WriteDocUpsert)
+}
+
+// WriteScriptedUpsert streams the docs as scripted upsert items into w
+func (a *Adapter) WriteScriptedUpsert(w io.Writer) error {
 
 	for _, doc := range a.Result.Docs {
 
@@ -120,13 +144,7 @@ func (a *Adapter) ScriptedUpsertData() []byte {
 
 		header["update"] = doc.Meta
 
-		h, err := json.Marshal(header)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		doc := map[string]any{
+		data := map[string]any{
 			"scripted_upsert": true,
 			"script": map[string]any{
 				"lang":   "painless",
@@ -136,24 +154,21 @@ func (a *Adapter) ScriptedUpsertData() []byte {
 			"upsert": doc.Source.Map(),
 		}
 
-		d, err := json.Marshal(doc)
-		if err != nil {
-			log.Println(err)
-			continue
+		if err := writePair(w, header, data); err != nil {
+			return err
 		}
-
-		b.Write(h)
-		b.WriteString("\n")
-		b.Write(d)
-		b.WriteString("\n")
 	}
 
-	return b.Bytes()
+	return nil
 }
 
-func (a *Adapter) ModelNamesBulkData() []byte {
+func (a *Adapter) ScriptedUpsertData() []byte {
 
-	var b bytes.Buffer
+	return bulkData(a.WriteScriptedUpsert)
+}
+
+// WriteModelNames streams the model names of the docs as update items into w
+func (a *Adapter) WriteModelNames(w io.Writer) error {
 
 	for _, doc := range a.Result.Docs {
 
@@ -163,12 +178,6 @@ func (a *Adapter) ModelNamesBulkData() []byte {
 		header := make(map[string]any)
 		header["update"] = hd
 
-		h, err := json.Marshal(header)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
 		t := doc.String("primary")
 		o := doc.String("secondary")
 
@@ -184,21 +193,19 @@ func (a *Adapter) ModelNamesBulkData() []byte {
 			"tertiary":    all,
 		}
 
-		doc := make(map[string]any)
-		doc["doc"] = e
-		doc["doc_as_upsert"] = true
+		data := make(map[string]any)
+		data["doc"] = e
+		data["doc_as_upsert"] = true
 
-		d, err := json.Marshal(doc)
-		if err != nil {
-			log.Println(err)
-			continue
+		if err := writePair(w, header, data); err != nil {
+			return err
 		}
-
-		b.Write(h)
-		b.WriteString("\n")
-		b.Write(d)
-		b.WriteString("\n")
 	}
 
-	return b.Bytes()
+	return nil
+}
+
+func (a *Adapter) ModelNamesBulkData() []byte {
+
+	return bulkData(a.WriteModelNames)
 }
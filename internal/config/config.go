@@ -66,3 +66,35 @@ func MustGet(s string) *ini.Section {
 
 	return f.Section(s)
 }
+
+// Get returns the section, false when the ini file or the section does not exist
+func Get(s string) (*ini.Section, bool) {
+
+	f, err := Load(DefaultIniFile())
+	if err != nil || !f.HasSection(s) {
+		return nil, false
+	}
+
+	return f.Section(s), true
+}
+
+// Values returns every value of a multi valued key, given as repeated keys
+// (shadows) or as a comma separated list
+func Values(section *ini.Section, k string) []string {
+
+	vs := make([]string, 0)
+
+	if section == nil || !section.HasKey(k) {
+		return vs
+	}
+
+	for _, v := range section.Key(k).ValueWithShadows() {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				vs = append(vs, s)
+			}
+		}
+	}
+
+	return vs
+}
@@ -11,6 +11,15 @@ const (
 	// CKDBMain Database config keys
 	CKDBMain = "main_db"
 
+	// CKTableOptimizer table optimizer config keys
+	CKTableOptimizer = "table_optimizer"
+
+	// CKTableDefragmenter table defragmenter config keys
+	CKTableDefragmenter = "table_defragmenter"
+
+	// CKRetention retention policy config section prefix
+	CKRetention = "retention"
+
 	// CKECMain ElasticCluster config keys
 	CKECMain = "es"
 
@@ -41,6 +50,10 @@ const (
 
 	EQ  = "="
 	NEQ = "<>"
+	GT  = ">"
+	GTE = ">="
+	LT  = "<"
+	LTE = "<="
 	IN  = "IN"
 
 	DBOrderAsc  = "ASC"
@@ -0,0 +1,144 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strings"
+)
+
+// batchParams keeps a multi row statement under the bind variable limit
+// of the dialect (mysql, postgres 65535, sqlite 999 on older builds)
+func batchParams(d Dialect) int {
+
+	if d.Name() == AdapterSQLite {
+		return 999
+	}
+	return 30000
+}
+
+// batchInsert renders a multi row insert of n rows, with upsert the
+// columns not in conflicts are updated with the inserted values
+func batchInsert(d Dialect, table string, columns []string, n int, upsert bool, conflicts []string) string {
+
+	var buf bytes.Buffer
+
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, d.Quote(c))
+	}
+
+	fmt.Fprintf(&buf, "INSERT INTO %s (%s) VALUES ", d.Quote(table), strings.Join(quoted, ", "))
+
+	p := 0
+	for i := 0; i < n; i++ {
+
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		ps := make([]string, 0, len(columns))
+		for range columns {
+			p++
+			ps = append(ps, d.Placeholder(p))
+		}
+		fmt.Fprintf(&buf, "(%s)", strings.Join(ps, ", "))
+	}
+
+	if upsert {
+
+		keys := make(map[string]bool)
+		for _, c := range conflicts {
+			keys[c] = true
+		}
+
+		sets := make([]string, 0, len(columns))
+		for _, c := range columns {
+			if !keys[c] {
+				sets = append(sets, fmt.Sprintf("%s=%s", d.Quote(c), d.Excluded(c)))
+			}
+		}
+
+		// every column is a key, nothing to update but the conflict must not fail
+		if len(sets) == 0 {
+			sets = append(sets, fmt.Sprintf("%s=%s", d.Quote(columns[0]), d.Excluded(columns[0])))
+		}
+
+		fmt.Fprintf(&buf, " %s", d.Upsert(conflicts, sets))
+	}
+
+	return buf.String()
+}
+
+func insertRows(ext sqlx.Execer, d Dialect, table string, columns []string, rows [][]any, upsert bool, conflicts []string) (int64, error) {
+
+	if len(columns) == 0 || len(rows) == 0 {
+		return 0, nil
+	}
+
+	size := batchParams(d) / len(columns)
+	if size == 0 {
+		size = 1
+	}
+
+	total := int64(0)
+
+	for start := 0; start < len(rows); start += size {
+
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		args := make([]any, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			if len(row) != len(columns) {
+				return total, fmt.Errorf("row has %d values for %d columns", len(row), len(columns))
+			}
+			args = append(args, row...)
+		}
+
+		q := batchInsert(d, table, columns, end-start, upsert, conflicts)
+
+		r, err := ext.Exec(q, args...)
+		if err != nil {
+			return total, err
+		}
+
+		if n, err := r.RowsAffected(); err == nil {
+			total += n
+		}
+	}
+
+	return total, nil
+}
+
+// InsertRows inserts the rows with multi row statements, it returns the affected row count
+func (db *DB) InsertRows(table string, columns []string, rows [][]any) (int64, error) {
+
+	return insertRows(db, db.dialect, table, columns, rows, false, nil)
+}
+
+// UpsertRows inserts the rows, updating the existing ones. conflicts are the unique
+// key columns, mysql detects them by itself but the other dialects need them.
+// The affected row count follows the driver (mysql counts an update twice).
+func (db *DB) UpsertRows(table string, columns, conflicts []string, rows [][]any) (int64, error) {
+
+	return insertRows(db, db.dialect, table, columns, rows, true, conflicts)
+}
+
+// InsertRowsTx is InsertRows in a transaction
+func (db *DB) InsertRowsTx(tx *Tx, table string, columns []string, rows [][]any) (int64, error) {
+
+	return insertRows(tx, db.dialect, table, columns, rows, false, nil)
+}
+
+// UpsertRowsTx is UpsertRows in a transaction
+func (db *DB) UpsertRowsTx(tx *Tx, table string, columns, conflicts []string, rows [][]any) (int64, error) {
+
+	return insertRows(tx, db.dialect, table, columns, rows, true, conflicts)
+}
@@ -0,0 +1,326 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/config"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cluster section keys
+//
+// [main_db]
+// host=...
+// replica=main_db_replica_1   (shadow values, each one is a db section name)
+// replica=main_db_replica_2
+// max_lag=5                   (seconds, replicas behind this are skipped)
+// check_interval=10           (seconds between health / lag checks)
+
+const (
+	defaultMaxLag        = 5 * time.Second
+	defaultCheckInterval = 10 * time.Second
+)
+
+type node struct {
+	name string
+	db   *DB
+
+	mu       sync.Mutex
+	checking bool
+	checked  time.Time
+	healthy  bool
+	lag      time.Duration
+}
+
+func newNode(name string) *node {
+
+	return &node{name: name, db: MustGet(name)}
+}
+
+// LagFunc returns how far a replica is behind its source
+type LagFunc func(db *DB) (time.Duration, error)
+
+// ReplicaLag is the LagFunc of SHOW SLAVE STATUS. A db that is not replicating
+// at all reports no lag.
+func ReplicaLag(db *DB) (time.Duration, error) {
+
+	rows, err := db.Queryx("SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	status := make(map[string]any)
+	if err := rows.MapScan(status); err != nil {
+		return 0, err
+	}
+
+	for _, k := range []string{"Seconds_Behind_Master", "Seconds_Behind_Source"} {
+
+		v, ok := status[k]
+		if !ok {
+			continue
+		}
+
+		if v == nil {
+			return 0, fmt.Errorf("replication is not running")
+		}
+
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+
+		var sec int64
+		if _, err := fmt.Sscan(fmt.Sprint(v), &sec); err != nil {
+			return 0, err
+		}
+		return time.Duration(sec) * time.Second, nil
+	}
+
+	return 0, nil
+}
+
+// check returns the health and the lag of the node, checked again after interval.
+// The checks run outside the lock, the other callers get the last state meanwhile
+// so that a slow node never blocks the routing. lagOf is nil for the primary.
+func (n *node) check(interval time.Duration, lagOf LagFunc) (bool, time.Duration) {
+
+	n.mu.Lock()
+	if n.checking || (!n.checked.IsZero() && time.Since(n.checked) < interval) {
+		healthy, lag := n.healthy, n.lag
+		n.mu.Unlock()
+		return healthy, lag
+	}
+	n.checking = true
+	n.mu.Unlock()
+
+	healthy, lag := n.probe(lagOf)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.checking = false
+	n.checked, n.healthy, n.lag = time.Now(), healthy, lag
+	return healthy, lag
+}
+
+func (n *node) probe(lagOf LagFunc) (bool, time.Duration) {
+
+	if err := n.db.Ping(); err != nil {
+		log.Printf("[%s] ping error : %v", n.name, err)
+		return false, 0
+	}
+
+	if lagOf == nil {
+		return true, 0
+	}
+
+	lag, err := lagOf(n.db)
+	if err != nil {
+		log.Printf("[%s] replica status error : %v", n.name, err)
+		return false, 0
+	}
+	return true, lag
+}
+
+// Cluster is a primary with N read replicas. Writes and transactions always
+// go to the primary, reads are routed with the Target* constants.
+type Cluster struct {
+	name          string
+	primary       *node
+	replicas      []*node
+	maxLag        time.Duration
+	checkInterval time.Duration
+	lagOf         LagFunc
+
+	next uint32
+}
+
+type DBCluster struct {
+	mu      sync.Mutex
+	cluster map[string]*Cluster
+}
+
+var dbCluster DBCluster
+
+func init() {
+	dbCluster.cluster = make(map[string]*Cluster)
+}
+
+func MustGetCluster(s string) *Cluster {
+
+	dbCluster.mu.Lock()
+	defer dbCluster.mu.Unlock()
+
+	c, ok := dbCluster.cluster[s]
+	if ok && c != nil {
+		return c
+	}
+
+	section := config.MustGet(s)
+
+	c = &Cluster{
+		name:          s,
+		primary:       newNode(s),
+		replicas:      make([]*node, 0),
+		maxLag:        defaultMaxLag,
+		checkInterval: defaultCheckInterval,
+		lagOf:         ReplicaLag,
+	}
+
+	if lag, err := section.Key("max_lag").Int(); err == nil {
+		c.maxLag = time.Duration(lag) * time.Second
+	}
+
+	if interval, err := section.Key("check_interval").Int(); err == nil {
+		c.checkInterval = time.Duration(interval) * time.Second
+	}
+
+	if section.HasKey("replica") {
+		for _, r := range section.Key("replica").ValueWithShadows() {
+			if len(r) > 0 {
+				c.replicas = append(c.replicas, newNode(r))
+			}
+		}
+	}
+
+	dbCluster.cluster[s] = c
+	return c
+}
+
+// NewCluster creates a cluster of opened dbs with the default max lag and
+// check interval, MustGetCluster creates the clusters of the config
+func NewCluster(name string, primary *DB, replicas ...*DB) *Cluster {
+
+	c := &Cluster{
+		name:          name,
+		primary:       &node{name: name, db: primary},
+		replicas:      make([]*node, 0, len(replicas)),
+		maxLag:        defaultMaxLag,
+		checkInterval: defaultCheckInterval,
+		lagOf:         ReplicaLag,
+	}
+
+	for i, r := range replicas {
+		c.replicas = append(c.replicas, &node{name: fmt.Sprintf("%s_replica_%d", name, i+1), db: r})
+	}
+
+	return c
+}
+
+// LagFunc replaces SHOW SLAVE STATUS for the replica lag, e.g. with a heartbeat table
+func (c *Cluster) LagFunc(f LagFunc) {
+	c.lagOf = f
+}
+
+func (c *Cluster) MaxLag(d time.Duration) {
+	c.maxLag = d
+}
+
+func (c *Cluster) CheckInterval(d time.Duration) {
+	c.checkInterval = d
+}
+
+func (c *Cluster) Name() string {
+	return c.name
+}
+
+func (c *Cluster) Primary() *DB {
+	return c.primary.db
+}
+
+// replica picks a healthy replica in round robin order. When lagAware is set,
+// replicas behind max_lag are skipped as well.
+func (c *Cluster) replica(lagAware bool) *DB {
+
+	n := len(c.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	start := int(atomic.AddUint32(&c.next, 1))
+
+	for i := 0; i < n; i++ {
+
+		r := c.replicas[(start+i)%n]
+
+		healthy, lag := r.check(c.checkInterval, c.lagOf)
+		if !healthy {
+			continue
+		}
+
+		if lagAware && lag > c.maxLag {
+			log.Printf("[%s] replica lag %s exceeds %s", r.name, lag, c.maxLag)
+			continue
+		}
+
+		return r.db
+	}
+
+	return nil
+}
+
+// Target returns the DB a query should run on.
+//
+// TargetPrimaryOnly : primary, no fallback (writes, transactions)
+// TargetPrimary     : primary, a healthy replica if the primary is unreachable
+// TargetSecondary   : any healthy replica regardless of lag, primary if none
+// TargetMainIf      : a replica within max_lag, primary if none (default)
+func (c *Cluster) Target(t string) *DB {
+
+	switch t {
+	case constant.TargetPrimaryOnly:
+		return c.primary.db
+	case constant.TargetPrimary:
+		if healthy, _ := c.primary.check(c.checkInterval, nil); healthy {
+			return c.primary.db
+		}
+		if db := c.replica(false); db != nil {
+			return db
+		}
+		return c.primary.db
+	case constant.TargetSecondary:
+		if db := c.replica(false); db != nil {
+			return db
+		}
+		return c.primary.db
+	case constant.TargetMainIf, "":
+		if db := c.replica(true); db != nil {
+			return db
+		}
+		return c.primary.db
+	default:
+		panic(fmt.Sprintf("not allowed target : %s", t))
+	}
+}
+
+func (c *Cluster) Select(dest any, b *DqlBuilder) error {
+
+	return c.Target(b.target).SelectBy(dest, b)
+}
+
+func (c *Cluster) Get(dest any, b *DqlBuilder) error {
+
+	return c.Target(b.target).GetBy(dest, b)
+}
+
+func (c *Cluster) Run(q string, arg map[string]any) int64 {
+
+	return c.primary.db.Run(q, arg)
+}
+
+func (c *Cluster) Beginx() (*Tx, error) {
+
+	return c.primary.db.Beginx()
+}
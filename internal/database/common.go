@@ -6,7 +6,9 @@ package database
 
 import (
 	"fmt"
+	"github.com/jmoiron/sqlx"
 	"log"
+	"strings"
 )
 
 type CreateTableStatement struct {
@@ -20,7 +22,7 @@ func (db *DB) Exist(t string) bool {
 		return false
 	}
 
-	q := fmt.Sprintf("SHOW TABLES LIKE '%s';", t)
+	q := db.dialect.TablesQuery(t)
 
 	r := make([]string, 0)
 	err := db.Select(&r, q)
@@ -29,8 +31,11 @@ func (db *DB) Exist(t string) bool {
 		return false
 	}
 
-	if len(r) > 0 {
-		return true
+	// LIKE treats _ as a wildcard, bak_a matches baka as well
+	for _, name := range r {
+		if strings.EqualFold(name, t) {
+			return true
+		}
 	}
 
 	return false
@@ -55,10 +60,10 @@ func (db *DB) Run(q string, arg map[string]any) int64 {
 }
 func (db *DB) Tables(p string) []string {
 
-	q := "SHOW TABLES "
+	q := db.dialect.TablesQuery("")
 
 	if len(p) > 0 {
-		q += fmt.Sprintf("LIKE '%s%%'", p)
+		q = db.dialect.TablesQuery(p + "%")
 	}
 
 	tables := make([]string, 0)
@@ -74,7 +79,7 @@ func (db *DB) Count(t string) int {
 
 	count := 0
 
-	err := db.Get(&count, fmt.Sprintf("SELECT COUNT(*) AS count FROM %s", t))
+	err := db.Get(&count, fmt.Sprintf("SELECT COUNT(*) AS count FROM %s", db.dialect.Quote(t)))
 	if err != nil {
 		log.Println(err)
 	}
@@ -100,7 +105,7 @@ func (db *DB) Rename(s, t string) bool {
 		return false
 	}
 
-	q := fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`;", s, t)
+	q := fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", db.dialect.Quote(s), db.dialect.Quote(t))
 
 	if db.Run(q, nil) != -1 {
 		return true
@@ -114,7 +119,7 @@ func (db *DB) Drop(t string) bool {
 		return false
 	}
 
-	q := fmt.Sprintf("DROP TABLE `%s`;", t)
+	q := fmt.Sprintf("DROP TABLE %s;", db.dialect.Quote(t))
 
 	if db.Run(q, nil) != -1 {
 		return true
@@ -143,3 +148,38 @@ func (db *DB) Columns(t string) []string {
 
 	return columns
 }
+
+func (db *DB) bindDql(b *DqlBuilder) (string, []any, error) {
+
+	q, arg := b.Build()
+
+	if b.named {
+		nq, args, err := sqlx.Named(q, arg.arg)
+		if err != nil {
+			return "", nil, err
+		}
+		return db.Rebind(nq), args, nil
+	}
+
+	return q, arg.args, nil
+}
+
+func (db *DB) SelectBy(dest any, b *DqlBuilder) error {
+
+	q, args, err := db.bindDql(b)
+	if err != nil {
+		return err
+	}
+
+	return db.Select(dest, q, args...)
+}
+
+func (db *DB) GetBy(dest any, b *DqlBuilder) error {
+
+	q, args, err := db.bindDql(b)
+	if err != nil {
+		return err
+	}
+
+	return db.Get(dest, q, args...)
+}
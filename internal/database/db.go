@@ -10,8 +10,12 @@ import (
 	"github.com/go-sql-driver/mysql"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/ini.v1"
 	"log"
 	"sync"
+	"time"
 )
 
 type DBConfig struct {
@@ -63,6 +67,27 @@ func (c *DBConfig) Config(s string) *mysql.Config {
 
 type DB struct {
 	*sqlx.DB
+	dialect Dialect
+	hooks   []Hook
+}
+
+// Dialect returns the dialect of the db, builders created through the db use it
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+func (db *DB) QueryBuilder(op string) QueryBuilder {
+
+	builder := NewQueryBuilder(op)
+	builder.Dialect(db.dialect)
+	return builder
+}
+
+func (db *DB) DqlBuilder() *DqlBuilder {
+
+	builder := NewDqlBuilder()
+	builder.Dialect(db.dialect)
+	return builder
 }
 
 type MysqlDB struct {
@@ -76,6 +101,19 @@ func init() {
 	mysqlDB.db = make(map[string]*DB)
 }
 
+// Open opens a db without ini section (ex. in-process sqlite for tests)
+func Open(adapter, dsn string) (*DB, error) {
+
+	dialect := NewDialect(adapter)
+
+	sqlxDB, err := sqlx.Open(dialect.Driver(), dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{DB: sqlxDB, dialect: dialect}, nil
+}
+
 func MustGet(s string) *DB {
 
 	mysqlDB.mu.Lock()
@@ -86,16 +124,40 @@ func MustGet(s string) *DB {
 		return db
 	}
 
-	cfg := dbConfig.Config(s)
+	section := config.MustGet(s)
 
-	dsn := cfg.FormatDSN()
+	dialect := NewDialect(section.Key("adapter").String())
 
-	sqlxDB, err := sqlx.Open("mysql", dsn)
+	db, err := Open(dialect.Name(), dialect.DSN(section))
 	if err != nil {
 		log.Fatal(err)
 		return nil
 	}
 
-	mysqlDB.db[s] = &DB{sqlxDB}
+	addHooks(db, section)
+
+	mysqlDB.db[s] = db
 	return mysqlDB.db[s]
 }
+
+// addHooks adds the hooks configured in the db section
+//
+// query_log = true
+// slow_query_ms = 500
+// slow_query_explain = true
+// query_metrics = true
+func addHooks(db *DB, section *ini.Section) {
+
+	if section.Key("query_log").MustBool(false) {
+		db.AddHook(NewLogHook(nil))
+	}
+
+	if ms := section.Key("slow_query_ms").MustInt(0); ms > 0 {
+		explain := section.Key("slow_query_explain").MustBool(true)
+		db.AddHook(NewSlowQueryHook(time.Duration(ms)*time.Millisecond, explain, nil))
+	}
+
+	if section.Key("query_metrics").MustBool(false) {
+		db.AddHook(DefaultMetrics)
+	}
+}
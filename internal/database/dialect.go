@@ -0,0 +1,339 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"gopkg.in/ini.v1"
+	"strings"
+)
+
+const (
+	AdapterMySQL    = "mysql"
+	AdapterPostgres = "postgres"
+	AdapterSQLite   = "sqlite3"
+)
+
+// Dialect covers the syntax differences between the supported databases.
+type Dialect interface {
+	Name() string
+	Driver() string
+
+	// Quote quotes an identifier, "a.b" is quoted part by part
+	Quote(id string) string
+
+	// Placeholder returns the n-th (1-based) positional bind variable
+	Placeholder(n int) string
+
+	// Limit renders the limit / offset clause, offset -1 means no offset
+	Limit(limit, offset int) string
+
+	// Upsert renders the conflict clause of an insert statement, sets are
+	// already rendered assignments (`col`=:param)
+	Upsert(conflicts []string, sets []string) string
+
+	// Excluded returns the value an upsert tried to insert into the column
+	Excluded(column string) string
+
+	TableOptions() string
+
+	// ColumnType maps a generic DDL type (pk, int, bigint, string, text,
+	// bool, float, double, date, datetime, json, blob) to the dialect type.
+	// Anything else is passed through as it is.
+	ColumnType(t string) string
+
+	TablesQuery(p string) string
+
+	DSN(section *ini.Section) string
+}
+
+func NewDialect(adapter string) Dialect {
+
+	switch strings.ToLower(adapter) {
+	case "", "mysql", "mariadb":
+		return mysqlDialect{}
+	case "postgres", "postgresql", "pgsql":
+		return postgresDialect{}
+	case "sqlite", "sqlite3":
+		return sqliteDialect{}
+	default:
+		panic(fmt.Sprintf("not allowed adapter : %s", adapter))
+	}
+}
+
+func quoteWith(id, q string) string {
+
+	parts := strings.Split(id, ".")
+	for i, p := range parts {
+		if p == "*" {
+			continue
+		}
+		p = strings.Trim(p, "`\"")
+		parts[i] = q + strings.ReplaceAll(p, q, q+q) + q
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func columnType(t string, types map[string]string) string {
+
+	head, rest, _ := strings.Cut(strings.TrimSpace(t), " ")
+
+	mapped, ok := types[strings.ToLower(head)]
+	if !ok {
+		return t
+	}
+
+	if len(rest) > 0 {
+		return mapped + " " + rest
+	}
+	return mapped
+}
+
+func escapeLike(p string) string {
+
+	return strings.ReplaceAll(p, "'", "''")
+}
+
+type mysqlDialect struct{}
+
+var mysqlTypes = map[string]string{
+	"pk":       "BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY",
+	"int":      "INT",
+	"bigint":   "BIGINT",
+	"string":   "VARCHAR(255)",
+	"text":     "TEXT",
+	"bool":     "TINYINT(1)",
+	"float":    "FLOAT",
+	"double":   "DOUBLE",
+	"date":     "DATE",
+	"datetime": "DATETIME",
+	"json":     "JSON",
+	"blob":     "LONGBLOB",
+}
+
+func (mysqlDialect) Name() string {
+	return AdapterMySQL
+}
+
+func (mysqlDialect) Driver() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Quote(id string) string {
+	return quoteWith(id, "`")
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) Limit(limit, offset int) string {
+
+	if offset != -1 {
+		return fmt.Sprintf("LIMIT %d, %d", offset, limit)
+	}
+	return fmt.Sprintf("LIMIT %d", limit)
+}
+
+func (mysqlDialect) Upsert(conflicts []string, sets []string) string {
+
+	return fmt.Sprintf("ON DUPLICATE KEY UPDATE %s", strings.Join(sets, ", "))
+}
+
+func (d mysqlDialect) Excluded(column string) string {
+	return fmt.Sprintf("VALUES(%s)", d.Quote(column))
+}
+
+func (mysqlDialect) TableOptions() string {
+	return "ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE utf8mb4_unicode_ci"
+}
+
+func (mysqlDialect) ColumnType(t string) string {
+	return columnType(t, mysqlTypes)
+}
+
+func (mysqlDialect) TablesQuery(p string) string {
+
+	q := "SHOW TABLES "
+	if len(p) > 0 {
+		q += fmt.Sprintf("LIKE '%s'", escapeLike(p))
+	}
+	return q
+}
+
+func (mysqlDialect) DSN(section *ini.Section) string {
+
+	return dbConfig.Config(section.Name()).FormatDSN()
+}
+
+type postgresDialect struct{}
+
+var postgresTypes = map[string]string{
+	"pk":       "BIGSERIAL PRIMARY KEY",
+	"int":      "INTEGER",
+	"bigint":   "BIGINT",
+	"string":   "VARCHAR(255)",
+	"text":     "TEXT",
+	"bool":     "BOOLEAN",
+	"float":    "REAL",
+	"double":   "DOUBLE PRECISION",
+	"date":     "DATE",
+	"datetime": "TIMESTAMP",
+	"json":     "JSONB",
+	"blob":     "BYTEA",
+}
+
+func (postgresDialect) Name() string {
+	return AdapterPostgres
+}
+
+func (postgresDialect) Driver() string {
+	return "postgres"
+}
+
+func (postgresDialect) Quote(id string) string {
+	return quoteWith(id, `"`)
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgresDialect) Limit(limit, offset int) string {
+
+	if offset != -1 {
+		return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	}
+	return fmt.Sprintf("LIMIT %d", limit)
+}
+
+func (d postgresDialect) Upsert(conflicts []string, sets []string) string {
+
+	return conflictUpsert(d, conflicts, sets)
+}
+
+func (d postgresDialect) Excluded(column string) string {
+	return "EXCLUDED." + d.Quote(column)
+}
+
+func (postgresDialect) TableOptions() string {
+	return ""
+}
+
+func (postgresDialect) ColumnType(t string) string {
+	return columnType(t, postgresTypes)
+}
+
+func (postgresDialect) TablesQuery(p string) string {
+
+	q := "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = current_schema() "
+	if len(p) > 0 {
+		q += fmt.Sprintf("AND tablename LIKE '%s' ", escapeLike(p))
+	}
+	return q + "ORDER BY tablename"
+}
+
+func (postgresDialect) DSN(section *ini.Section) string {
+
+	port := 5432
+	if p, err := section.Key("port").Int(); err == nil {
+		port = p
+	}
+
+	sslmode := section.Key("sslmode").MustString("disable")
+
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		section.Key("host").String(), port,
+		section.Key("username").String(), section.Key("password").String(),
+		section.Key("dbname").String(), sslmode)
+}
+
+type sqliteDialect struct{}
+
+var sqliteTypes = map[string]string{
+	"pk":       "INTEGER PRIMARY KEY AUTOINCREMENT",
+	"int":      "INTEGER",
+	"bigint":   "INTEGER",
+	"string":   "TEXT",
+	"text":     "TEXT",
+	"bool":     "INTEGER",
+	"float":    "REAL",
+	"double":   "REAL",
+	"date":     "TEXT",
+	"datetime": "TEXT",
+	"json":     "TEXT",
+	"blob":     "BLOB",
+}
+
+func (sqliteDialect) Name() string {
+	return AdapterSQLite
+}
+
+func (sqliteDialect) Driver() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) Quote(id string) string {
+	return quoteWith(id, `"`)
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) Limit(limit, offset int) string {
+
+	if offset != -1 {
+		return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	}
+	return fmt.Sprintf("LIMIT %d", limit)
+}
+
+func (d sqliteDialect) Upsert(conflicts []string, sets []string) string {
+
+	return conflictUpsert(d, conflicts, sets)
+}
+
+func (d sqliteDialect) Excluded(column string) string {
+	return "excluded." + d.Quote(column)
+}
+
+func (sqliteDialect) TableOptions() string {
+	return ""
+}
+
+func (sqliteDialect) ColumnType(t string) string {
+	return columnType(t, sqliteTypes)
+}
+
+func (sqliteDialect) TablesQuery(p string) string {
+
+	q := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' "
+	if len(p) > 0 {
+		q += fmt.Sprintf("AND name LIKE '%s' ", escapeLike(p))
+	}
+	return q + "ORDER BY name"
+}
+
+func (sqliteDialect) DSN(section *ini.Section) string {
+
+	// path is the database file, ":memory:" for an in-process database
+	return section.Key("path").MustString(section.Key("dbname").String())
+}
+
+func conflictUpsert(d Dialect, conflicts []string, sets []string) string {
+
+	if len(conflicts) == 0 {
+		panic("no conflict columns in upsert query")
+	}
+
+	cs := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		cs = append(cs, d.Quote(c))
+	}
+
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(cs, ", "), strings.Join(sets, ", "))
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	QueryOpExec  = "exec"
+	QueryOpQuery = "query"
+)
+
+// QueryEvent describes a statement passing through the db.
+// Args holds the positional args, named statements keep the original
+// query and arg in NamedQuery / NamedArg as well.
+type QueryEvent struct {
+	Op    string
+	Query string
+	Args  []any
+
+	NamedQuery string
+	NamedArg   any
+
+	Table string
+	Start time.Time
+
+	// set before After is called
+	Duration time.Duration
+	Rows     int64
+	Err      error
+
+	db *DB
+}
+
+// DB returns the db the statement ran on, hooks may use it to run their own statements
+func (e *QueryEvent) DB() *DB {
+	return e.db
+}
+
+// Hook is called around every Exec and Query of a DB, including
+// the statements of the builders run through SelectBy, GetBy and Run
+// and the statements of its transactions (Beginx).
+type Hook interface {
+	Before(e *QueryEvent)
+	After(e *QueryEvent)
+}
+
+// AddHook adds h to the db, hooks should be added before the db is shared
+func (db *DB) AddHook(h Hook) {
+	db.hooks = append(db.hooks, h)
+}
+
+var tableName = regexp.MustCompile("(?i)\\b(?:FROM|INTO|UPDATE|TABLE|JOIN)\\s+([`\"\\w.]+)")
+
+// tableOf returns the first table of q, "" if not found
+func tableOf(q string) string {
+
+	ms := tableName.FindStringSubmatch(q)
+	if ms == nil {
+		return ""
+	}
+
+	t := strings.ReplaceAll(ms[1], "`", "")
+	t = strings.ReplaceAll(t, "\"", "")
+
+	// schema.table
+	if i := strings.LastIndex(t, "."); i != -1 {
+		t = t[i+1:]
+	}
+
+	return strings.ToLower(t)
+}
+
+func (db *DB) before(op, q string, args []any) *QueryEvent {
+
+	if len(db.hooks) == 0 {
+		return nil
+	}
+
+	e := &QueryEvent{Op: op, Query: q, Args: args, Table: tableOf(q), Start: time.Now(), Rows: -1, db: db}
+
+	for _, h := range db.hooks {
+		h.Before(e)
+	}
+
+	return e
+}
+
+func (db *DB) after(e *QueryEvent, rows int64, err error) {
+
+	if e == nil {
+		return
+	}
+
+	e.Duration = time.Since(e.Start)
+	e.Rows = rows
+	e.Err = err
+
+	for i := len(db.hooks) - 1; i >= 0; i-- {
+		db.hooks[i].After(e)
+	}
+}
+
+func affected(r sql.Result, err error) int64 {
+
+	if err != nil || r == nil {
+		return -1
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// length returns the row count of a Select destination
+func length(dest any) int64 {
+
+	v := reflect.Indirect(reflect.ValueOf(dest))
+	if v.Kind() == reflect.Slice {
+		return int64(v.Len())
+	}
+	return -1
+}
+
+// statements are the methods shared by *sqlx.DB and *sqlx.Tx
+type statements interface {
+	ExecContext(ctx context.Context, q string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, q string, args ...any) (*sql.Rows, error)
+	QueryxContext(ctx context.Context, q string, args ...any) (*sqlx.Rows, error)
+	QueryRowContext(ctx context.Context, q string, args ...any) *sql.Row
+	QueryRowxContext(ctx context.Context, q string, args ...any) *sqlx.Row
+	SelectContext(ctx context.Context, dest any, q string, args ...any) error
+	GetContext(ctx context.Context, dest any, q string, args ...any) error
+}
+
+func (db *DB) exec(ctx context.Context, st statements, q string, args []any) (sql.Result, error) {
+
+	e := db.before(QueryOpExec, q, args)
+	r, err := st.ExecContext(ctx, q, args...)
+	db.after(e, affected(r, err), err)
+
+	return r, err
+}
+
+func (db *DB) query(ctx context.Context, st statements, q string, args []any) (*sql.Rows, error) {
+
+	e := db.before(QueryOpQuery, q, args)
+	rows, err := st.QueryContext(ctx, q, args...)
+	db.after(e, -1, err)
+
+	return rows, err
+}
+
+func (db *DB) queryx(ctx context.Context, st statements, q string, args []any) (*sqlx.Rows, error) {
+
+	e := db.before(QueryOpQuery, q, args)
+	rows, err := st.QueryxContext(ctx, q, args...)
+	db.after(e, -1, err)
+
+	return rows, err
+}
+
+func (db *DB) queryRow(ctx context.Context, st statements, q string, args []any) *sql.Row {
+
+	e := db.before(QueryOpQuery, q, args)
+	row := st.QueryRowContext(ctx, q, args...)
+	db.after(e, -1, row.Err())
+
+	return row
+}
+
+func (db *DB) queryRowx(ctx context.Context, st statements, q string, args []any) *sqlx.Row {
+
+	e := db.before(QueryOpQuery, q, args)
+	row := st.QueryRowxContext(ctx, q, args...)
+	db.after(e, -1, row.Err())
+
+	return row
+}
+
+func (db *DB) selectRows(ctx context.Context, st statements, dest any, q string, args []any) error {
+
+	e := db.before(QueryOpQuery, q, args)
+	err := st.SelectContext(ctx, dest, q, args...)
+	db.after(e, length(dest), err)
+
+	return err
+}
+
+func (db *DB) get(ctx context.Context, st statements, dest any, q string, args []any) error {
+
+	e := db.before(QueryOpQuery, q, args)
+	err := st.GetContext(ctx, dest, q, args...)
+
+	rows := int64(1)
+	if err != nil {
+		rows = 0
+	}
+	db.after(e, rows, err)
+
+	return err
+}
+
+// named binds a named statement, keeping the named form in the event
+func (db *DB) named(op, q string, arg any) (*QueryEvent, string, []any, error) {
+
+	bq, args, err := db.BindNamed(q, arg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	e := db.before(op, bq, args)
+	if e != nil {
+		e.NamedQuery = q
+		e.NamedArg = arg
+	}
+
+	return e, bq, args, nil
+}
+
+func (db *DB) namedExec(ctx context.Context, st statements, q string, arg any) (sql.Result, error) {
+
+	e, bq, args, err := db.named(QueryOpExec, q, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := st.ExecContext(ctx, bq, args...)
+	db.after(e, affected(r, err), err)
+
+	return r, err
+}
+
+func (db *DB) namedQuery(ctx context.Context, st statements, q string, arg any) (*sqlx.Rows, error) {
+
+	e, bq, args, err := db.named(QueryOpQuery, q, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := st.QueryxContext(ctx, bq, args...)
+	db.after(e, -1, err)
+
+	return rows, err
+}
+
+// the statement methods of the embedded *sqlx.DB are wrapped, context or not,
+// so that no Exec or Query skips the hooks
+
+func (db *DB) Exec(q string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), q, args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	return db.exec(ctx, db.DB, q, args)
+}
+
+func (db *DB) MustExec(q string, args ...any) sql.Result {
+	return db.MustExecContext(context.Background(), q, args...)
+}
+
+func (db *DB) MustExecContext(ctx context.Context, q string, args ...any) sql.Result {
+
+	r, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (db *DB) Query(q string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), q, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	return db.query(ctx, db.DB, q, args)
+}
+
+func (db *DB) Queryx(q string, args ...any) (*sqlx.Rows, error) {
+	return db.QueryxContext(context.Background(), q, args...)
+}
+
+func (db *DB) QueryxContext(ctx context.Context, q string, args ...any) (*sqlx.Rows, error) {
+	return db.queryx(ctx, db.DB, q, args)
+}
+
+func (db *DB) QueryRow(q string, args ...any) *sql.Row {
+	return db.QueryRowContext(context.Background(), q, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, q string, args ...any) *sql.Row {
+	return db.queryRow(ctx, db.DB, q, args)
+}
+
+func (db *DB) QueryRowx(q string, args ...any) *sqlx.Row {
+	return db.QueryRowxContext(context.Background(), q, args...)
+}
+
+func (db *DB) QueryRowxContext(ctx context.Context, q string, args ...any) *sqlx.Row {
+	return db.queryRowx(ctx, db.DB, q, args)
+}
+
+func (db *DB) Select(dest any, q string, args ...any) error {
+	return db.SelectContext(context.Background(), dest, q, args...)
+}
+
+func (db *DB) SelectContext(ctx context.Context, dest any, q string, args ...any) error {
+	return db.selectRows(ctx, db.DB, dest, q, args)
+}
+
+func (db *DB) Get(dest any, q string, args ...any) error {
+	return db.GetContext(context.Background(), dest, q, args...)
+}
+
+func (db *DB) GetContext(ctx context.Context, dest any, q string, args ...any) error {
+	return db.get(ctx, db.DB, dest, q, args)
+}
+
+func (db *DB) NamedExec(q string, arg any) (sql.Result, error) {
+	return db.NamedExecContext(context.Background(), q, arg)
+}
+
+func (db *DB) NamedExecContext(ctx context.Context, q string, arg any) (sql.Result, error) {
+
+	if len(db.hooks) == 0 {
+		return db.DB.NamedExecContext(ctx, q, arg)
+	}
+	return db.namedExec(ctx, db.DB, q, arg)
+}
+
+func (db *DB) NamedQuery(q string, arg any) (*sqlx.Rows, error) {
+	return db.NamedQueryContext(context.Background(), q, arg)
+}
+
+func (db *DB) NamedQueryContext(ctx context.Context, q string, arg any) (*sqlx.Rows, error) {
+
+	if len(db.hooks) == 0 {
+		return db.DB.NamedQueryContext(ctx, q, arg)
+	}
+	return db.namedQuery(ctx, db.DB, q, arg)
+}
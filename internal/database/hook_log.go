@@ -0,0 +1,214 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedactKeywords are the arg names whose values are never logged
+var RedactKeywords = []string{"password", "passwd", "secret", "token", "api_key", "apikey", "credential"}
+
+const (
+	redactedValue = "'[REDACTED]'"
+	maxLoggedArg  = 256
+)
+
+func redacted(name string) bool {
+
+	name = strings.ToLower(name)
+	for _, k := range RedactKeywords {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatArg(v any) string {
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		if dv, err := valuer.Value(); err == nil {
+			v = dv
+		}
+	}
+
+	quote := func(s string) string {
+		if len(s) > maxLoggedArg {
+			s = fmt.Sprintf("%s...(%d bytes)", s[:maxLoggedArg], len(s))
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quote(x)
+	case []byte:
+		return quote(string(x))
+	case time.Time:
+		return quote(x.Format("2006-01-02 15:04:05"))
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+var (
+	namedParam    = regexp.MustCompile(`(?:^|[^:]):(\w+)`)
+	comparedParam = regexp.MustCompile("(?i)[`\"]?(\\w+)[`\"]?\\s*(?:=|<>|!=|<=|>=|<|>|LIKE)\\s*$")
+	insertColumns = regexp.MustCompile(`(?is)^\s*(?:INSERT|REPLACE)\s+(?:IGNORE\s+)?INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+)
+
+// argNames guesses the name of every placeholder of the event, used for redaction
+func argNames(e *QueryEvent, positions []int) []string {
+
+	names := make([]string, len(e.Args))
+
+	if len(e.NamedQuery) > 0 {
+		for i, ms := range namedParam.FindAllStringSubmatch(e.NamedQuery, -1) {
+			if i < len(names) {
+				names[i] = ms[1]
+			}
+		}
+		return names
+	}
+
+	var columns []string
+	if ms := insertColumns.FindStringSubmatch(e.Query); ms != nil {
+		for _, c := range strings.Split(ms[1], ",") {
+			columns = append(columns, unquote(c))
+		}
+	}
+
+	for i, pos := range positions {
+
+		if i >= len(names) {
+			break
+		}
+
+		if ms := comparedParam.FindStringSubmatch(e.Query[:pos]); ms != nil {
+			names[i] = ms[1]
+		} else if len(columns) > 0 {
+			names[i] = columns[i%len(columns)]
+		}
+	}
+
+	return names
+}
+
+// placeholders returns the offset and arg index of every placeholder (? or $n) outside quotes
+func placeholders(q string) ([]int, []int, []int) {
+
+	offsets, ends, indexes := make([]int, 0), make([]int, 0), make([]int, 0)
+
+	var quote byte
+	n := 0
+
+	for i := 0; i < len(q); i++ {
+
+		c := q[i]
+
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '?':
+			offsets, ends, indexes = append(offsets, i), append(ends, i+1), append(indexes, n)
+			n++
+		case '$':
+			j := i + 1
+			for j < len(q) && q[j] >= '0' && q[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				idx, _ := strconv.Atoi(q[i+1 : j])
+				offsets, ends, indexes = append(offsets, i), append(ends, j), append(indexes, idx-1)
+				i = j - 1
+			}
+		}
+	}
+
+	return offsets, ends, indexes
+}
+
+// Interpolate returns the statement of e with its args inlined, sensitive args redacted.
+// The result is for logging only, it is not safe to execute.
+func Interpolate(e *QueryEvent) string {
+
+	if len(e.Args) == 0 {
+		return e.Query
+	}
+
+	offsets, ends, indexes := placeholders(e.Query)
+	names := argNames(e, offsets)
+
+	var b strings.Builder
+	last := 0
+
+	for i, off := range offsets {
+
+		b.WriteString(e.Query[last:off])
+		last = ends[i]
+
+		idx := indexes[i]
+		if idx < 0 || idx >= len(e.Args) {
+			b.WriteString(e.Query[off:ends[i]])
+			continue
+		}
+
+		if redacted(names[idx]) {
+			b.WriteString(redactedValue)
+		} else {
+			b.WriteString(formatArg(e.Args[idx]))
+		}
+	}
+
+	b.WriteString(e.Query[last:])
+	return b.String()
+}
+
+// LogHook logs every statement with its args inlined
+type LogHook struct {
+	logger *log.Logger
+}
+
+// NewLogHook creates a log hook, logger nil logs with the standard logger
+func NewLogHook(logger *log.Logger) *LogHook {
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &LogHook{logger: logger}
+}
+
+func (h *LogHook) Before(e *QueryEvent) {}
+
+func (h *LogHook) After(e *QueryEvent) {
+
+	q := strings.Join(strings.Fields(Interpolate(e)), " ")
+
+	if e.Err != nil {
+		h.logger.Printf("[sql] %s (%s) error : %v", q, e.Duration, e.Err)
+		return
+	}
+
+	h.logger.Printf("[sql] %s (%s, rows %d)", q, e.Duration, e.Rows)
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// LatencyBounds are the upper bounds (ms) of the latency histogram buckets
+	LatencyBounds = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+
+	// RowBounds are the upper bounds of the row count histogram buckets
+	RowBounds = []float64{0, 1, 10, 100, 1000, 10000, 100000}
+)
+
+// Histogram counts observations by bucket, the last bucket has no upper bound
+type Histogram struct {
+	Bounds []float64
+	Counts []int64
+	Count  int64
+	Sum    float64
+	Max    float64
+}
+
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *Histogram) Observe(v float64) {
+
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+	h.Max = math.Max(h.Max, v)
+}
+
+func (h *Histogram) Mean() float64 {
+
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile estimates the q quantile (0~1) as the upper bound of its bucket
+func (h *Histogram) Quantile(q float64) float64 {
+
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	seen := int64(0)
+
+	for i, c := range h.Counts {
+		seen += c
+		if seen >= rank {
+			if i < len(h.Bounds) {
+				return h.Bounds[i]
+			}
+			return h.Max
+		}
+	}
+	return h.Max
+}
+
+func (h *Histogram) clone() *Histogram {
+
+	c := *h
+	c.Counts = append([]int64(nil), h.Counts...)
+	return &c
+}
+
+type TableMetrics struct {
+	Table   string
+	Op      string
+	Errors  int64
+	Latency *Histogram // ms
+	Rows    *Histogram
+}
+
+// MetricsHook collects latency and row count histograms by table and operation
+type MetricsHook struct {
+	mu      sync.Mutex
+	metrics map[string]*TableMetrics
+}
+
+func NewMetricsHook() *MetricsHook {
+	return &MetricsHook{metrics: make(map[string]*TableMetrics)}
+}
+
+func (h *MetricsHook) Before(e *QueryEvent) {}
+
+func (h *MetricsHook) After(e *QueryEvent) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := e.Table + "|" + e.Op
+
+	m, ok := h.metrics[key]
+	if !ok {
+		m = &TableMetrics{Table: e.Table, Op: e.Op, Latency: NewHistogram(LatencyBounds), Rows: NewHistogram(RowBounds)}
+		h.metrics[key] = m
+	}
+
+	if e.Err != nil {
+		m.Errors++
+	}
+
+	m.Latency.Observe(float64(e.Duration.Microseconds()) / 1000)
+
+	if e.Rows >= 0 {
+		m.Rows.Observe(float64(e.Rows))
+	}
+}
+
+// Metrics returns a snapshot of the collected metrics ordered by table and operation
+func (h *MetricsHook) Metrics() []TableMetrics {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := make([]TableMetrics, 0, len(h.metrics))
+	for _, m := range h.metrics {
+		c := *m
+		c.Latency = m.Latency.clone()
+		c.Rows = m.Rows.clone()
+		r = append(r, c)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Table != r[j].Table {
+			return r[i].Table < r[j].Table
+		}
+		return r[i].Op < r[j].Op
+	})
+
+	return r
+}
+
+func (h *MetricsHook) Reset() {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.metrics = make(map[string]*TableMetrics)
+}
+
+// Report formats the metrics as a table, for logs and slack
+func (h *MetricsHook) Report() string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-30s %-6s %8s %6s %10s %10s %10s %10s %12s\n",
+		"table", "op", "count", "errors", "mean(ms)", "p95(ms)", "max(ms)", "rows(avg)", "rows(total)")
+
+	for _, m := range h.Metrics() {
+
+		table := m.Table
+		if len(table) == 0 {
+			table = "-"
+		}
+
+		fmt.Fprintf(&b, "%-30s %-6s %8d %6d %10.2f %10.2f %10.2f %10.1f %12.0f\n",
+			table, m.Op, m.Latency.Count, m.Errors, m.Latency.Mean(), m.Latency.Quantile(0.95), m.Latency.Max, m.Rows.Mean(), m.Rows.Sum)
+	}
+
+	return b.String()
+}
+
+// DefaultMetrics collects the metrics of the dbs with query_metrics set in their section
+var DefaultMetrics = NewMetricsHook()
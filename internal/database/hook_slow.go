@@ -0,0 +1,119 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// SlowQueryHook logs the statements running longer than the threshold,
+// with the EXPLAIN output of the statement when explain is set
+type SlowQueryHook struct {
+	threshold time.Duration
+	explain   bool
+	logger    *log.Logger
+}
+
+func NewSlowQueryHook(threshold time.Duration, explain bool, logger *log.Logger) *SlowQueryHook {
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &SlowQueryHook{threshold: threshold, explain: explain, logger: logger}
+}
+
+func (h *SlowQueryHook) Before(e *QueryEvent) {}
+
+func (h *SlowQueryHook) After(e *QueryEvent) {
+
+	if e.Duration < h.threshold {
+		return
+	}
+
+	q := strings.Join(strings.Fields(Interpolate(e)), " ")
+	h.logger.Printf("[slow query] %s table %s rows %d : %s", e.Duration, e.Table, e.Rows, q)
+
+	if !h.explain || e.Err != nil {
+		return
+	}
+
+	plan, err := Explain(e)
+	if err != nil {
+		h.logger.Printf("[slow query] explain failed : %v", err)
+		return
+	}
+
+	for _, l := range plan {
+		h.logger.Printf("[slow query] %s", l)
+	}
+}
+
+func explainable(q string) bool {
+
+	fs := strings.Fields(q)
+	if len(fs) == 0 {
+		return false
+	}
+
+	switch strings.ToUpper(fs[0]) {
+	case "SELECT", "UPDATE", "DELETE", "WITH":
+		return true
+	}
+	return false
+}
+
+// Explain runs EXPLAIN of the event statement, one line per plan row.
+// The statement itself is not executed.
+func Explain(e *QueryEvent) ([]string, error) {
+
+	if !explainable(e.Query) {
+		return nil, fmt.Errorf("statement can not be explained : %s", e.Query)
+	}
+
+	prefix := "EXPLAIN "
+	if e.db.dialect.Name() == AdapterSQLite {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+
+	// the embedded sqlx db, explain is not hooked
+	rows, err := e.db.DB.Queryx(prefix+e.Query, e.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]string, 0)
+
+	for rows.Next() {
+
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, err
+		}
+
+		fs := make([]string, 0, len(values))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if v == nil {
+				continue
+			}
+			fs = append(fs, fmt.Sprintf("%s=%v", columns[i], v))
+		}
+		plan = append(plan, strings.Join(fs, " "))
+	}
+
+	return plan, rows.Err()
+}
@@ -38,6 +38,10 @@ type TableSetter interface {
 	Table(t string)
 }
 
+type DialectSetter interface {
+	Dialect(d Dialect)
+}
+
 type CondAdder interface {
 	AddCond(k string, op string, v any)
 }
@@ -50,18 +54,52 @@ type Updater interface {
 	AddUpdate(k string, v any)
 }
 
+type ConflictAdder interface {
+	AddConflict(k string)
+}
+
 type Builder interface {
 	Build() (string, map[string]any)
 }
 
 type QueryBuilder interface {
 	TableSetter
+	DialectSetter
 	CondAdder
 	Setter
 	Updater
+	ConflictAdder
 	Builder
 }
 
+type cond struct {
+	key   string
+	op    string
+	param string
+	value any
+}
+
+func (c cond) in() bool {
+	return c.op == constant.IN
+}
+
+func (c cond) render(d Dialect) string {
+
+	if c.in() {
+		return fmt.Sprintf("%s %s (%v)", d.Quote(c.key), c.op, c.value)
+	}
+	return fmt.Sprintf("%s%s:%s", d.Quote(c.key), c.op, c.param)
+}
+
+func renderConds(d Dialect, cs []cond) []string {
+
+	r := make([]string, 0, len(cs))
+	for _, c := range cs {
+		r = append(r, c.render(d))
+	}
+	return r
+}
+
 func NewQueryBuilder(op string) QueryBuilder {
 
 	if len(op) == 0 {
@@ -11,16 +11,18 @@ import (
 )
 
 type createBuilder struct {
-	table string
-	stmt  []string
-	arg   map[string]any
+	dialect Dialect
+	table   string
+	stmt    [][2]string
+	arg     map[string]any
 }
 
 func newCreateBuilder() *createBuilder {
 
 	return &createBuilder{
-		stmt: make([]string, 0),
-		arg:  make(map[string]any),
+		dialect: mysqlDialect{},
+		stmt:    make([][2]string, 0),
+		arg:     make(map[string]any),
 	}
 }
 
@@ -28,26 +30,47 @@ func (b *createBuilder) Table(t string) {
 	b.table = t
 }
 
+func (b *createBuilder) Dialect(d Dialect) {
+	b.dialect = d
+}
+
 func (b *createBuilder) AddCond(k string, op string, v any) {
 
 }
 
+// AddSet adds a column definition, v is a generic DDL type (see Dialect.ColumnType)
 func (b *createBuilder) AddSet(k string, v any) {
 
+	if len(k) > 0 {
+		b.stmt = append(b.stmt, [2]string{k, fmt.Sprint(v)})
+	}
 }
 
 func (b *createBuilder) AddUpdate(k string, v any) {
 
 }
 
+func (b *createBuilder) AddConflict(k string) {
+
+}
+
 func (b *createBuilder) Build() (string, map[string]any) {
 
 	var buf bytes.Buffer
 
-	buf.WriteString(fmt.Sprintf("CREATE TABLE `%s` (", b.table))
-	buf.WriteString(strings.Join(b.stmt, ", "))
+	columns := make([]string, 0, len(b.stmt))
+	for _, s := range b.stmt {
+		columns = append(columns, fmt.Sprintf("%s %s", b.dialect.Quote(s[0]), b.dialect.ColumnType(s[1])))
+	}
+
+	buf.WriteString(fmt.Sprintf("CREATE TABLE %s (", b.dialect.Quote(b.table)))
+	buf.WriteString(strings.Join(columns, ", "))
 	buf.WriteString(") ")
-	buf.WriteString("ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE utf8mb4_unicode_ci ;")
+	if options := b.dialect.TableOptions(); len(options) > 0 {
+		buf.WriteString(options)
+		buf.WriteString(" ")
+	}
+	buf.WriteString(";")
 
 	return string(buf.Bytes()), b.arg
 }
@@ -11,16 +11,18 @@ import (
 )
 
 type deleteBuilder struct {
-	table  string
-	wheres []string
-	arg    map[string]any
+	dialect Dialect
+	table   string
+	wheres  []cond
+	arg     map[string]any
 }
 
 func newDeleteBuilder() *deleteBuilder {
 
 	return &deleteBuilder{
-		wheres: make([]string, 0),
-		arg:    make(map[string]any),
+		dialect: mysqlDialect{},
+		wheres:  make([]cond, 0),
+		arg:     make(map[string]any),
 	}
 }
 
@@ -28,11 +30,15 @@ func (b *deleteBuilder) Table(t string) {
 	b.table = t
 }
 
+func (b *deleteBuilder) Dialect(d Dialect) {
+	b.dialect = d
+}
+
 func (b *deleteBuilder) AddCond(k string, op string, v any) {
 
 	if len(k) > 0 {
 		b.arg[k] = v
-		b.wheres = append(b.wheres, fmt.Sprintf("`%s`%s:%s", k, op, k))
+		b.wheres = append(b.wheres, cond{key: k, op: op, param: k})
 	}
 }
 
@@ -44,6 +50,10 @@ func (b *deleteBuilder) AddUpdate(k string, v any) {
 
 }
 
+func (b *deleteBuilder) AddConflict(k string) {
+
+}
+
 func (b *deleteBuilder) Build() (string, map[string]any) {
 
 	if len(b.wheres) == 0 {
@@ -52,8 +62,8 @@ func (b *deleteBuilder) Build() (string, map[string]any) {
 
 	var buf bytes.Buffer
 
-	buf.WriteString(fmt.Sprintf("DELETE FROM `%s` ", b.table))
-	buf.WriteString(fmt.Sprintf("WHERE %s", strings.Join(b.wheres, " AND ")))
+	buf.WriteString(fmt.Sprintf("DELETE FROM %s ", b.dialect.Quote(b.table)))
+	buf.WriteString(fmt.Sprintf("WHERE %s", strings.Join(renderConds(b.dialect, b.wheres), " AND ")))
 
 	return buf.String(), b.arg
 }
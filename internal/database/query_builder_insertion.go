@@ -11,10 +11,11 @@ import (
 )
 
 type insertBuilder struct {
-	table   string
-	keys    []string
-	values  []string
-	updates []string
+	dialect   Dialect
+	table     string
+	keys      []string
+	updates   []string
+	conflicts []string
 
 	arg map[string]any
 }
@@ -22,9 +23,11 @@ type insertBuilder struct {
 func newInsertBuilder() *insertBuilder {
 
 	return &insertBuilder{
-		keys:   make([]string, 0),
-		values: make([]string, 0),
-		arg:    make(map[string]any),
+		dialect:   mysqlDialect{},
+		keys:      make([]string, 0),
+		updates:   make([]string, 0),
+		conflicts: make([]string, 0),
+		arg:       make(map[string]any),
 	}
 }
 
@@ -32,6 +35,10 @@ func (b *insertBuilder) Table(t string) {
 	b.table = t
 }
 
+func (b *insertBuilder) Dialect(d Dialect) {
+	b.dialect = d
+}
+
 func (b *insertBuilder) AddCond(k string, op string, v any) {
 
 }
@@ -40,8 +47,7 @@ func (b *insertBuilder) AddSet(k string, v any) {
 
 	if len(k) > 0 {
 		b.arg[k] = v
-		b.keys = append(b.keys, fmt.Sprintf("`%s`", k))
-		b.values = append(b.values, fmt.Sprintf(":%s", k))
+		b.keys = append(b.keys, k)
 	}
 }
 
@@ -50,7 +56,15 @@ func (b *insertBuilder) AddUpdate(k string, v any) {
 	if len(k) > 0 {
 		nk := fmt.Sprintf("update_%s", k)
 		b.arg[nk] = v
-		b.updates = append(b.updates, fmt.Sprintf("`%s`=:%s", k, nk))
+		b.updates = append(b.updates, k)
+	}
+}
+
+// AddConflict adds a unique key column used by ON CONFLICT dialects
+func (b *insertBuilder) AddConflict(k string) {
+
+	if len(k) > 0 {
+		b.conflicts = append(b.conflicts, k)
 	}
 }
 
@@ -61,12 +75,25 @@ func (b *insertBuilder) Build() (string, map[string]any) {
 
 	var buf bytes.Buffer
 
-	buf.WriteString(fmt.Sprintf("INSERT INTO `%s` ", b.table))
-	buf.WriteString(fmt.Sprintf("(%s) ", strings.Join(b.keys, ", ")))
-	buf.WriteString(fmt.Sprintf("VALUES(%s) ", strings.Join(b.values, ", ")))
+	keys := make([]string, 0, len(b.keys))
+	values := make([]string, 0, len(b.keys))
+	for _, k := range b.keys {
+		keys = append(keys, b.dialect.Quote(k))
+		values = append(values, fmt.Sprintf(":%s", k))
+	}
+
+	buf.WriteString(fmt.Sprintf("INSERT INTO %s ", b.dialect.Quote(b.table)))
+	buf.WriteString(fmt.Sprintf("(%s) ", strings.Join(keys, ", ")))
+	buf.WriteString(fmt.Sprintf("VALUES(%s) ", strings.Join(values, ", ")))
 
 	if len(b.updates) > 0 {
-		buf.WriteString(fmt.Sprintf("ON DUPLICATE KEY UPDATE %s;", strings.Join(b.updates, ", ")))
+
+		sets := make([]string, 0, len(b.updates))
+		for _, k := range b.updates {
+			sets = append(sets, fmt.Sprintf("%s=:update_%s", b.dialect.Quote(k), k))
+		}
+
+		buf.WriteString(fmt.Sprintf("%s;", b.dialect.Upsert(b.conflicts, sets)))
 	}
 
 	return buf.String(), b.arg
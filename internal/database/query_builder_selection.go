@@ -23,11 +23,13 @@ func (a *DqlBuilderArg) new() {
 }
 
 type DqlBuilder struct {
+	dialect Dialect
 	named   bool
+	target  string
 	table   string
 	joins   []string
 	columns []string
-	wheres  []string
+	wheres  []cond
 	orders  []string
 	groups  []string
 	having  []string
@@ -39,9 +41,10 @@ type DqlBuilder struct {
 func NewDqlBuilder() *DqlBuilder {
 
 	b := DqlBuilder{
+		dialect: mysqlDialect{},
 		joins:   make([]string, 0),
 		columns: make([]string, 0),
-		wheres:  make([]string, 0),
+		wheres:  make([]cond, 0),
 		orders:  make([]string, 0),
 		groups:  make([]string, 0),
 		having:  make([]string, 0),
@@ -70,7 +73,7 @@ func quoteSlice(x any) string {
 		res := make([]string, 0, len(x))
 		for _, xs := range x {
 			if len(xs) > 0 {
-				res = append(res, fmt.Sprintf(`"%s"`, xs))
+				res = append(res, fmt.Sprintf("'%s'", strings.ReplaceAll(xs, "'", "''")))
 			}
 		}
 		return strings.Join(res, ", ")
@@ -79,6 +82,30 @@ func quoteSlice(x any) string {
 	}
 }
 
+// clone returns a copy of the builder, the copy can be changed without touching b
+func (b *DqlBuilder) clone() *DqlBuilder {
+
+	c := *b
+	c.joins = append([]string(nil), b.joins...)
+	c.columns = append([]string(nil), b.columns...)
+	c.wheres = append([]cond(nil), b.wheres...)
+	c.orders = append([]string(nil), b.orders...)
+	c.groups = append([]string(nil), b.groups...)
+	c.having = append([]string(nil), b.having...)
+
+	c.arg.new()
+	for k, v := range b.arg.arg {
+		c.arg.arg[k] = v
+	}
+	c.arg.args = append(c.arg.args, b.arg.args...)
+
+	return &c
+}
+
+func (b *DqlBuilder) Dialect(d Dialect) {
+	b.dialect = d
+}
+
 func (b *DqlBuilder) Named(n bool) {
 	b.named = n
 }
@@ -87,6 +114,11 @@ func (b *DqlBuilder) Table(t string) {
 	b.table = t
 }
 
+// Target pins the query to a cluster target (constant.Target*)
+func (b *DqlBuilder) Target(t string) {
+	b.target = t
+}
+
 func (b *DqlBuilder) AddJoin(j string) {
 
 	b.joins = append(b.joins, j)
@@ -101,10 +133,6 @@ func (b *DqlBuilder) AddCond(k string, op string, v any) {
 
 	if len(k) > 0 {
 
-		if strings.Index(k, ".") == -1 {
-			k = fmt.Sprintf("`%s`", k)
-		}
-
 		if op == constant.IN {
 			val := reflect.ValueOf(v)
 			if val.Kind() == reflect.Slice {
@@ -113,21 +141,31 @@ func (b *DqlBuilder) AddCond(k string, op string, v any) {
 		}
 
 		if op == constant.IN {
-			b.wheres = append(b.wheres, fmt.Sprintf("%s %s (%v)", k, op, v))
+			b.wheres = append(b.wheres, cond{key: k, op: op, value: v})
 		} else {
 
 			if b.named {
-				b.arg.arg[k] = v
-				b.wheres = append(b.wheres, fmt.Sprintf("%s%s:%s", k, op, k))
+				// named parameters can not contain quotes or dots
+				n := strings.ReplaceAll(k, ".", "_")
+				b.arg.arg[n] = v
+				b.wheres = append(b.wheres, cond{key: k, op: op, param: n})
 			} else {
 				b.arg.args = append(b.arg.args, v)
-				b.wheres = append(b.wheres, fmt.Sprintf("%s%s?", k, op))
+				b.wheres = append(b.wheres, cond{key: k, op: op})
 			}
 		}
 
 	}
 }
 
+// AddWhere adds a raw condition, it is rendered in parentheses as it is
+func (b *DqlBuilder) AddWhere(w string) {
+
+	if len(w) > 0 {
+		b.wheres = append(b.wheres, cond{key: w})
+	}
+}
+
 func (b *DqlBuilder) AddGroup(g ...string) {
 
 	if len(g) > 0 {
@@ -159,6 +197,36 @@ func (b *DqlBuilder) Offset(o int) {
 	b.offset = o
 }
 
+func (b *DqlBuilder) renderWheres() []string {
+
+	r := make([]string, 0, len(b.wheres))
+
+	n := 0
+	for _, c := range b.wheres {
+
+		if len(c.op) == 0 {
+			r = append(r, fmt.Sprintf("(%s)", c.key))
+			continue
+		}
+
+		k := c.key
+		if strings.Index(k, ".") == -1 {
+			k = b.dialect.Quote(k)
+		}
+
+		if c.in() {
+			r = append(r, fmt.Sprintf("%s %s (%v)", k, c.op, c.value))
+		} else if len(c.param) > 0 {
+			r = append(r, fmt.Sprintf("%s%s:%s", k, c.op, c.param))
+		} else {
+			n++
+			r = append(r, fmt.Sprintf("%s%s%s", k, c.op, b.dialect.Placeholder(n)))
+		}
+	}
+
+	return r
+}
+
 func (b *DqlBuilder) build() string {
 
 	if len(b.table) == 0 {
@@ -178,13 +246,13 @@ func (b *DqlBuilder) build() string {
 		fmt.Fprintf(&buf, "%s ", strings.Join(b.joins, " "))
 	}
 	if len(b.wheres) > 0 {
-		fmt.Fprintf(&buf, "WHERE %s ", strings.Join(b.wheres, " AND "))
+		fmt.Fprintf(&buf, "WHERE %s ", strings.Join(b.renderWheres(), " AND "))
 	}
 	if len(b.groups) > 0 {
 		fmt.Fprintf(&buf, "GROUP BY %s ", strings.Join(b.groups, " , "))
 	}
 	if len(b.having) > 0 {
-		fmt.Fprintf(&buf, "HAVING %s ", b.having)
+		fmt.Fprintf(&buf, "HAVING %s ", strings.Join(b.having, " AND "))
 	}
 	if len(b.orders) > 0 {
 		fmt.Fprintf(&buf, "ORDER BY %s ", strings.Join(b.orders, " , "))
@@ -192,9 +260,9 @@ func (b *DqlBuilder) build() string {
 
 	if b.limit > 0 {
 		if b.offset != -1 {
-			fmt.Fprintf(&buf, "LIMIT %d, %d ", b.offset, b.limit)
+			fmt.Fprintf(&buf, "%s ", b.dialect.Limit(b.limit, b.offset))
 		} else {
-			fmt.Fprintf(&buf, "LIMIT %d", b.limit)
+			fmt.Fprintf(&buf, "%s", b.dialect.Limit(b.limit, b.offset))
 		}
 	}
 	return buf.String()
@@ -13,18 +13,20 @@ import (
 )
 
 type updateBuilder struct {
-	table  string
-	sets   []string
-	wheres []string
-	arg    map[string]any
+	dialect Dialect
+	table   string
+	sets    []string
+	wheres  []cond
+	arg     map[string]any
 }
 
 func newUpdateBuilder() *updateBuilder {
 
 	return &updateBuilder{
-		sets:   make([]string, 0),
-		wheres: make([]string, 0),
-		arg:    make(map[string]any),
+		dialect: mysqlDialect{},
+		sets:    make([]string, 0),
+		wheres:  make([]cond, 0),
+		arg:     make(map[string]any),
 	}
 }
 
@@ -32,6 +34,10 @@ func (b *updateBuilder) Table(t string) {
 	b.table = t
 }
 
+func (b *updateBuilder) Dialect(d Dialect) {
+	b.dialect = d
+}
+
 func (b *updateBuilder) AddCond(k string, op string, v any) {
 
 	if len(k) > 0 {
@@ -44,10 +50,10 @@ func (b *updateBuilder) AddCond(k string, op string, v any) {
 		}
 
 		if op == constant.IN {
-			b.wheres = append(b.wheres, fmt.Sprintf("`%s` %s (%v)", k, op, v))
+			b.wheres = append(b.wheres, cond{key: k, op: op, value: v})
 		} else {
 			b.arg[k] = v
-			b.wheres = append(b.wheres, fmt.Sprintf("`%s`%s:%s", k, op, k))
+			b.wheres = append(b.wheres, cond{key: k, op: op, param: k})
 		}
 	}
 }
@@ -58,7 +64,7 @@ func (b *updateBuilder) AddSet(k string, v any) {
 		nk := fmt.Sprintf("set_%s", k)
 
 		b.arg[nk] = v
-		b.sets = append(b.sets, fmt.Sprintf("`%s`=:%s", k, nk))
+		b.sets = append(b.sets, k)
 	}
 }
 
@@ -66,6 +72,10 @@ func (b *updateBuilder) AddUpdate(k string, v any) {
 
 }
 
+func (b *updateBuilder) AddConflict(k string) {
+
+}
+
 func (b *updateBuilder) Build() (string, map[string]any) {
 
 	if len(b.wheres) == 0 {
@@ -74,10 +84,15 @@ func (b *updateBuilder) Build() (string, map[string]any) {
 
 	var buf bytes.Buffer
 
-	buf.WriteString(fmt.Sprintf("UPDATE `%s` ", b.table))
-	buf.WriteString(fmt.Sprintf("SET %s ", strings.Join(b.sets, ", ")))
+	sets := make([]string, 0, len(b.sets))
+	for _, k := range b.sets {
+		sets = append(sets, fmt.Sprintf("%s=:set_%s", b.dialect.Quote(k), k))
+	}
+
+	buf.WriteString(fmt.Sprintf("UPDATE %s ", b.dialect.Quote(b.table)))
+	buf.WriteString(fmt.Sprintf("SET %s ", strings.Join(sets, ", ")))
 
-	buf.WriteString(fmt.Sprintf("WHERE %s ", strings.Join(b.wheres, " AND ")))
+	buf.WriteString(fmt.Sprintf("WHERE %s ", strings.Join(renderConds(b.dialect, b.wheres), " AND ")))
 
 	return buf.String(), b.arg
 }
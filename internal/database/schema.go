@@ -0,0 +1,441 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type DBIndex struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Type    string
+}
+
+type DBForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnUpdate   string
+	OnDelete   string
+}
+
+type DBTableOptions struct {
+	Engine    string
+	Charset   string
+	Collation string
+	RowFormat string
+	Comment   string
+}
+
+type TableSchema struct {
+	Name        string
+	Columns     []DBColumn
+	Indexes     []DBIndex
+	ForeignKeys []DBForeignKey
+	Options     DBTableOptions
+}
+
+func (s *TableSchema) Column(name string) (DBColumn, bool) {
+
+	for _, c := range s.Columns {
+		if strings.EqualFold(c.Field, name) {
+			return c, true
+		}
+	}
+	return DBColumn{}, false
+}
+
+func (s *TableSchema) Index(name string) (DBIndex, bool) {
+
+	for _, i := range s.Indexes {
+		if strings.EqualFold(i.Name, name) {
+			return i, true
+		}
+	}
+	return DBIndex{}, false
+}
+
+func (s *TableSchema) ForeignKey(name string) (DBForeignKey, bool) {
+
+	for _, fk := range s.ForeignKeys {
+		if strings.EqualFold(fk.Name, name) {
+			return fk, true
+		}
+	}
+	return DBForeignKey{}, false
+}
+
+// PrimaryKey returns the primary key columns (empty if there is none)
+func (s *TableSchema) PrimaryKey() []string {
+
+	if pk, ok := s.Index("PRIMARY"); ok {
+		return pk.Columns
+	}
+	return []string{}
+}
+
+type describeIndexRow struct {
+	Name      string        `db:"INDEX_NAME"`
+	NonUnique int           `db:"NON_UNIQUE"`
+	Column    string        `db:"COLUMN_NAME"`
+	SubPart   sql.NullInt64 `db:"SUB_PART"`
+	Type      string        `db:"INDEX_TYPE"`
+}
+
+type describeForeignKeyRow struct {
+	Name      string `db:"CONSTRAINT_NAME"`
+	Column    string `db:"COLUMN_NAME"`
+	RefTable  string `db:"REFERENCED_TABLE_NAME"`
+	RefColumn string `db:"REFERENCED_COLUMN_NAME"`
+	OnUpdate  string `db:"UPDATE_RULE"`
+	OnDelete  string `db:"DELETE_RULE"`
+}
+
+type describeTableRow struct {
+	Engine    sql.NullString `db:"ENGINE"`
+	Collation sql.NullString `db:"TABLE_COLLATION"`
+	RowFormat sql.NullString `db:"ROW_FORMAT"`
+	Comment   sql.NullString `db:"TABLE_COMMENT"`
+}
+
+// Describe reads columns, indexes, foreign keys and table options of t from INFORMATION_SCHEMA
+func (db *DB) Describe(t string) (*TableSchema, error) {
+
+	schema := &TableSchema{
+		Name:        t,
+		Columns:     make([]DBColumn, 0),
+		Indexes:     make([]DBIndex, 0),
+		ForeignKeys: make([]DBForeignKey, 0),
+	}
+
+	q := "SELECT COLUMN_NAME AS `Field`, COLUMN_TYPE AS `Type`, IS_NULLABLE AS `Null`, COLUMN_KEY AS `Key`, " +
+		"COLUMN_DEFAULT AS `Default`, EXTRA AS `Extra` FROM INFORMATION_SCHEMA.COLUMNS " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+
+	if err := db.Select(&schema.Columns, q, t); err != nil {
+		return nil, err
+	}
+
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("table not found : %s", t)
+	}
+
+	for i, c := range schema.Columns {
+		schema.Columns[i].Type = normalizeType(c.Type)
+		schema.Columns[i].Extra = normalizeExtra(c.Extra)
+	}
+
+	q = "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, INDEX_TYPE FROM INFORMATION_SCHEMA.STATISTICS " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+
+	irs := make([]describeIndexRow, 0)
+	if err := db.Select(&irs, q, t); err != nil {
+		return nil, err
+	}
+
+	for _, r := range irs {
+
+		column := r.Column
+		if r.SubPart.Valid {
+			column = fmt.Sprintf("%s(%d)", column, r.SubPart.Int64)
+		}
+
+		n := len(schema.Indexes)
+		if n > 0 && schema.Indexes[n-1].Name == r.Name {
+			schema.Indexes[n-1].Columns = append(schema.Indexes[n-1].Columns, column)
+			continue
+		}
+
+		schema.Indexes = append(schema.Indexes, DBIndex{
+			Name:    r.Name,
+			Columns: []string{column},
+			Unique:  r.NonUnique == 0,
+			Type:    normalizeIndexType(r.Type),
+		})
+	}
+
+	q = "SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, " +
+		"r.UPDATE_RULE, r.DELETE_RULE FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE k " +
+		"JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS r " +
+		"ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME " +
+		"WHERE k.TABLE_SCHEMA = DATABASE() AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL " +
+		"ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION"
+
+	frs := make([]describeForeignKeyRow, 0)
+	if err := db.Select(&frs, q, t); err != nil {
+		return nil, err
+	}
+
+	for _, r := range frs {
+
+		n := len(schema.ForeignKeys)
+		if n > 0 && schema.ForeignKeys[n-1].Name == r.Name {
+			schema.ForeignKeys[n-1].Columns = append(schema.ForeignKeys[n-1].Columns, r.Column)
+			schema.ForeignKeys[n-1].RefColumns = append(schema.ForeignKeys[n-1].RefColumns, r.RefColumn)
+			continue
+		}
+
+		schema.ForeignKeys = append(schema.ForeignKeys, DBForeignKey{
+			Name:       r.Name,
+			Columns:    []string{r.Column},
+			RefTable:   r.RefTable,
+			RefColumns: []string{r.RefColumn},
+			OnUpdate:   normalizeRule(r.OnUpdate),
+			OnDelete:   normalizeRule(r.OnDelete),
+		})
+	}
+
+	q = "SELECT ENGINE, TABLE_COLLATION, ROW_FORMAT, TABLE_COMMENT FROM INFORMATION_SCHEMA.TABLES " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+
+	tr := describeTableRow{}
+	if err := db.Get(&tr, q, t); err != nil {
+		return nil, err
+	}
+
+	schema.Options = DBTableOptions{
+		Engine:    tr.Engine.String,
+		Collation: tr.Collation.String,
+		Charset:   charsetOf(tr.Collation.String),
+		RowFormat: tr.RowFormat.String,
+		Comment:   tr.Comment.String,
+	}
+
+	return schema, nil
+}
+
+// Schema describes every table starting with p
+func (db *DB) Schema(p string) (map[string]*TableSchema, error) {
+
+	schemas := make(map[string]*TableSchema)
+
+	for _, t := range db.Tables(p) {
+
+		s, err := db.Describe(t)
+		if err != nil {
+			return nil, err
+		}
+		schemas[t] = s
+	}
+
+	return schemas, nil
+}
+
+func charsetOf(collation string) string {
+
+	cs, _, _ := strings.Cut(collation, "_")
+	return cs
+}
+
+func normalizeExtra(e string) string {
+
+	e = strings.ReplaceAll(e, "DEFAULT_GENERATED", "")
+	return strings.ToLower(strings.TrimSpace(e))
+}
+
+func normalizeIndexType(t string) string {
+
+	t = strings.ToUpper(t)
+	if t == "BTREE" || t == "HASH" {
+		return ""
+	}
+	return t
+}
+
+func normalizeRule(r string) string {
+
+	r = strings.ToUpper(strings.TrimSpace(r))
+	if r == "RESTRICT" || r == "NO ACTION" {
+		return ""
+	}
+	return r
+}
+
+var intDisplayWidth = regexp.MustCompile(`^(smallint|mediumint|int|integer|bigint)\(\d+\)`)
+
+// normalizeType makes column types of different server versions comparable
+// (ex. int(11) on 5.7 and int on 8.0)
+func normalizeType(t string) string {
+
+	t = strings.ToLower(strings.TrimSpace(t))
+	return intDisplayWidth.ReplaceAllString(t, "$1")
+}
+
+var defaultFunction = regexp.MustCompile(`(?i)^(current_timestamp|now|localtime|localtimestamp|curdate|uuid)(\(\d*\))?$`)
+
+func quoteDefault(v string) string {
+
+	if strings.EqualFold(v, "NULL") || defaultFunction.MatchString(v) || strings.HasPrefix(v, "(") {
+		return v
+	}
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
+}
+
+// Definition renders the column definition used by CREATE / ALTER TABLE
+func (c DBColumn) Definition() string {
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s %s", mysqlDialect{}.Quote(c.Field), c.Type)
+
+	if c.Null == "NO" {
+		buf.WriteString(" NOT NULL")
+	} else {
+		buf.WriteString(" NULL")
+	}
+
+	if c.Default != nil {
+		fmt.Fprintf(&buf, " DEFAULT %s", quoteDefault(*c.Default))
+	} else if c.Null != "NO" {
+		buf.WriteString(" DEFAULT NULL")
+	}
+
+	if len(c.Extra) > 0 {
+		fmt.Fprintf(&buf, " %s", strings.ToUpper(c.Extra))
+	}
+
+	return buf.String()
+}
+
+func quoteColumns(cs []string) string {
+
+	qs := make([]string, 0, len(cs))
+	for _, c := range cs {
+		// prefix index columns (`name(10)`)
+		name, sub, ok := strings.Cut(c, "(")
+		if ok {
+			qs = append(qs, fmt.Sprintf("%s(%s", mysqlDialect{}.Quote(name), sub))
+		} else {
+			qs = append(qs, mysqlDialect{}.Quote(c))
+		}
+	}
+	return strings.Join(qs, ", ")
+}
+
+// Definition renders the index definition used by CREATE / ALTER TABLE
+func (i DBIndex) Definition() string {
+
+	if i.Name == "PRIMARY" {
+		return fmt.Sprintf("PRIMARY KEY (%s)", quoteColumns(i.Columns))
+	}
+
+	kind := "KEY"
+	if i.Unique {
+		kind = "UNIQUE KEY"
+	} else if len(i.Type) > 0 {
+		kind = i.Type + " KEY"
+	}
+
+	return fmt.Sprintf("%s %s (%s)", kind, mysqlDialect{}.Quote(i.Name), quoteColumns(i.Columns))
+}
+
+// Definition renders the constraint definition used by CREATE / ALTER TABLE
+func (fk DBForeignKey) Definition() string {
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		mysqlDialect{}.Quote(fk.Name), quoteColumns(fk.Columns),
+		mysqlDialect{}.Quote(fk.RefTable), quoteColumns(fk.RefColumns))
+
+	if len(fk.OnDelete) > 0 {
+		fmt.Fprintf(&buf, " ON DELETE %s", fk.OnDelete)
+	}
+	if len(fk.OnUpdate) > 0 {
+		fmt.Fprintf(&buf, " ON UPDATE %s", fk.OnUpdate)
+	}
+
+	return buf.String()
+}
+
+func (o DBTableOptions) Definition() string {
+
+	options := make([]string, 0)
+
+	if len(o.Engine) > 0 {
+		options = append(options, fmt.Sprintf("ENGINE=%s", o.Engine))
+	}
+	if len(o.Charset) > 0 {
+		options = append(options, fmt.Sprintf("DEFAULT CHARSET=%s", o.Charset))
+	}
+	if len(o.Collation) > 0 {
+		options = append(options, fmt.Sprintf("COLLATE=%s", o.Collation))
+	}
+	if len(o.Comment) > 0 {
+		options = append(options, fmt.Sprintf("COMMENT='%s'", strings.ReplaceAll(o.Comment, "'", "''")))
+	}
+
+	return strings.Join(options, " ")
+}
+
+// CreateStatement renders the schema as a CREATE TABLE statement
+func (s *TableSchema) CreateStatement() string {
+
+	defs := make([]string, 0, len(s.Columns)+len(s.Indexes)+len(s.ForeignKeys))
+
+	for _, c := range s.Columns {
+		defs = append(defs, c.Definition())
+	}
+
+	indexes := make([]DBIndex, len(s.Indexes))
+	copy(indexes, s.Indexes)
+
+	// primary key first, like SHOW CREATE TABLE
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return indexes[i].Name == "PRIMARY" && indexes[j].Name != "PRIMARY"
+	})
+
+	for _, i := range indexes {
+		defs = append(defs, i.Definition())
+	}
+	for _, fk := range s.ForeignKeys {
+		defs = append(defs, fk.Definition())
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n  %s\n) %s;",
+		mysqlDialect{}.Quote(s.Name), strings.Join(defs, ",\n  "), s.Options.Definition())
+}
+
+func columnTypes(cts []*sql.ColumnType) map[string]string {
+
+	types := make(map[string]string, len(cts))
+	for _, ct := range cts {
+		types[ct.Name()] = strings.ToUpper(ct.DatabaseTypeName())
+	}
+	return types
+}
+
+// ColumnTypes returns the database type names of the columns of t (VARCHAR, BLOB ...)
+// as the driver reports them, on every dialect
+func (db *DB) ColumnTypes(t string) (map[string]string, error) {
+
+	rows, err := db.Queryx(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", db.dialect.Quote(t)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	return columnTypes(cts), nil
+}
+
+// IsBinaryType tells if a database type name holds bytes rather than text
+// (BINARY, VARBINARY, BLOB, BYTEA)
+func IsBinaryType(t string) bool {
+
+	t = strings.ToUpper(t)
+	return strings.Contains(t, "BINARY") || strings.Contains(t, "BLOB") || t == "BYTEA"
+}
@@ -0,0 +1,484 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// splitTopLevel splits s by sep, ignoring separators inside quotes and parentheses
+func splitTopLevel(s string, sep rune) []string {
+
+	parts := make([]string, 0)
+
+	depth := 0
+	var quote rune
+	start := 0
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		if quote != 0 {
+			if r == '\\' {
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch r {
+		case '\'', '"', '`':
+			quote = r
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, string(runes[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	parts = append(parts, string(runes[start:]))
+	return parts
+}
+
+// tokenize splits a definition by spaces, keeping quoted strings and parenthesized groups together
+func tokenize(s string) []string {
+
+	tokens := make([]string, 0)
+
+	var buf []rune
+	depth := 0
+	var quote rune
+
+	flush := func() {
+		if len(buf) > 0 {
+			tokens = append(tokens, string(buf))
+			buf = buf[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		if quote != 0 {
+			buf = append(buf, r)
+			if r == '\\' && i+1 < len(runes) {
+				i++
+				buf = append(buf, runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			buf = append(buf, r)
+		case r == '(':
+			depth++
+			buf = append(buf, r)
+		case r == ')':
+			depth--
+			buf = append(buf, r)
+		case (r == ' ' || r == '\t' || r == '\n' || r == '\r') && depth == 0:
+			flush()
+		default:
+			buf = append(buf, r)
+		}
+	}
+
+	flush()
+	return tokens
+}
+
+func unquote(s string) string {
+
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		f, l := s[0], s[len(s)-1]
+		if (f == '`' || f == '"' || f == '\'') && f == l {
+			s = s[1 : len(s)-1]
+			if f == '\'' {
+				s = strings.ReplaceAll(s, "''", "'")
+			}
+		}
+	}
+	return s
+}
+
+// parseColumns parses "(`a`,`b`(10))"
+func parseColumns(s string) []string {
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "(")
+	s = strings.TrimSuffix(s, ")")
+
+	cs := make([]string, 0)
+	for _, c := range splitTopLevel(s, ',') {
+
+		c = strings.TrimSpace(c)
+		if strings.HasSuffix(c, " ASC") || strings.HasSuffix(c, " DESC") {
+			c = c[:strings.LastIndex(c, " ")]
+		}
+
+		name, sub, ok := strings.Cut(c, "(")
+		if ok {
+			cs = append(cs, unquote(name)+"("+sub)
+		} else {
+			cs = append(cs, unquote(c))
+		}
+	}
+	return cs
+}
+
+func upper(ts []string, i int) string {
+	return strings.ToUpper(token(ts, i))
+}
+
+// token returns the token i, empty past the end of a truncated definition
+func token(ts []string, i int) string {
+
+	if i < len(ts) {
+		return ts[i]
+	}
+	return ""
+}
+
+func parseColumn(ts []string) DBColumn {
+
+	c := DBColumn{Field: unquote(ts[0]), Null: "YES"}
+
+	i := 1
+	typ := []string{}
+	if i < len(ts) {
+		typ = append(typ, ts[i])
+		i++
+	}
+	for i < len(ts) && (upper(ts, i) == "UNSIGNED" || upper(ts, i) == "ZEROFILL") {
+		typ = append(typ, ts[i])
+		i++
+	}
+	c.Type = normalizeType(strings.Join(typ, " "))
+
+	extras := make([]string, 0)
+
+	for i < len(ts) {
+
+		switch upper(ts, i) {
+		case "NOT":
+			if upper(ts, i+1) == "NULL" {
+				c.Null = "NO"
+				i++
+			}
+		case "NULL":
+			c.Null = "YES"
+		case "DEFAULT":
+			// a truncated definition has no default value
+			if i+1 >= len(ts) {
+				break
+			}
+			v := ts[i+1]
+			if strings.EqualFold(v, "NULL") {
+				c.Default = nil
+			} else {
+				d := unquote(v)
+				c.Default = &d
+			}
+			i++
+		case "AUTO_INCREMENT":
+			extras = append(extras, "auto_increment")
+		case "ON":
+			if upper(ts, i+1) == "UPDATE" && i+2 < len(ts) {
+				extras = append(extras, "on update "+strings.ToLower(ts[i+2]))
+				i += 2
+			}
+		case "PRIMARY":
+			c.Key = "PRI"
+			i++
+		case "COMMENT", "COLLATE", "CHARSET":
+			i++
+		case "CHARACTER":
+			i += 2
+		}
+		i++
+	}
+
+	c.Extra = strings.Join(extras, " ")
+	return c
+}
+
+func parseForeignKey(ts []string) DBForeignKey {
+
+	fk := DBForeignKey{}
+
+	for i := 0; i < len(ts); i++ {
+
+		switch upper(ts, i) {
+		case "CONSTRAINT":
+			fk.Name = unquote(token(ts, i+1))
+			i++
+		case "FOREIGN":
+			// FOREIGN KEY [name] (cols)
+			i += 2
+			if i < len(ts) && !strings.HasPrefix(ts[i], "(") {
+				if len(fk.Name) == 0 {
+					fk.Name = unquote(ts[i])
+				}
+				i++
+			}
+			fk.Columns = parseColumns(token(ts, i))
+		case "REFERENCES":
+			ref := token(ts, i+1)
+			if name, cols, ok := strings.Cut(ref, "("); ok {
+				fk.RefTable = unquote(name)
+				fk.RefColumns = parseColumns("(" + cols)
+				i++
+			} else {
+				fk.RefTable = unquote(ref)
+				fk.RefColumns = parseColumns(token(ts, i+2))
+				i += 2
+			}
+		case "ON":
+			rule := upper(ts, i+2)
+			n := 2
+			if rule == "SET" || rule == "NO" {
+				rule = rule + " " + upper(ts, i+3)
+				n = 3
+			}
+			if upper(ts, i+1) == "DELETE" {
+				fk.OnDelete = normalizeRule(rule)
+			} else {
+				fk.OnUpdate = normalizeRule(rule)
+			}
+			i += n
+		}
+	}
+
+	return fk
+}
+
+func parseIndex(ts []string) DBIndex {
+
+	idx := DBIndex{}
+
+	i := 0
+	switch upper(ts, 0) {
+	case "PRIMARY":
+		return DBIndex{Name: "PRIMARY", Unique: true, Columns: parseColumns(ts[len(ts)-1])}
+	case "UNIQUE":
+		idx.Unique = true
+		i++
+	case "FULLTEXT", "SPATIAL":
+		idx.Type = upper(ts, 0)
+		i++
+	}
+
+	// KEY | INDEX
+	if upper(ts, i) == "KEY" || upper(ts, i) == "INDEX" {
+		i++
+	}
+
+	for ; i < len(ts); i++ {
+		if strings.HasPrefix(ts[i], "(") {
+			idx.Columns = parseColumns(ts[i])
+			break
+		}
+		if name, cols, ok := strings.Cut(ts[i], "("); ok && !strings.HasPrefix(ts[i], "`") {
+			idx.Name = unquote(name)
+			idx.Columns = parseColumns("(" + cols)
+			break
+		}
+		idx.Name = unquote(ts[i])
+	}
+
+	return idx
+}
+
+func parseTableOptions(s string) DBTableOptions {
+
+	o := DBTableOptions{}
+
+	s = strings.ReplaceAll(s, " = ", "=")
+	ts := tokenize(s)
+
+	for i := 0; i < len(ts); i++ {
+
+		k, v, ok := strings.Cut(ts[i], "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(k) {
+		case "ENGINE":
+			o.Engine = v
+		case "CHARSET":
+			o.Charset = strings.ToLower(v)
+		case "COLLATE":
+			o.Collation = strings.ToLower(v)
+		case "ROW_FORMAT":
+			o.RowFormat = strings.ToUpper(v)
+		case "COMMENT":
+			o.Comment = unquote(v)
+		}
+	}
+
+	if len(o.Charset) == 0 {
+		o.Charset = charsetOf(o.Collation)
+	}
+
+	return o
+}
+
+// ParseCreateTable parses a CREATE TABLE statement in the SHOW CREATE TABLE format
+func ParseCreateTable(ddl string) (*TableSchema, error) {
+
+	ddl = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(ddl), ";"))
+
+	open := strings.Index(ddl, "(")
+	close := strings.LastIndex(ddl, ")")
+	if open == -1 || close < open {
+		return nil, fmt.Errorf("invalid create table statement : %s", ddl)
+	}
+
+	head := tokenize(ddl[:open])
+	if len(head) < 3 || !strings.EqualFold(head[0], "CREATE") {
+		return nil, fmt.Errorf("invalid create table statement : %s", ddl)
+	}
+
+	schema := &TableSchema{
+		Name:        unquote(head[len(head)-1]),
+		Columns:     make([]DBColumn, 0),
+		Indexes:     make([]DBIndex, 0),
+		ForeignKeys: make([]DBForeignKey, 0),
+		Options:     parseTableOptions(ddl[close+1:]),
+	}
+
+	for _, def := range splitTopLevel(ddl[open+1:close], ',') {
+
+		ts := tokenize(strings.TrimSpace(def))
+		if len(ts) == 0 {
+			continue
+		}
+
+		switch upper(ts, 0) {
+		case "CONSTRAINT", "FOREIGN":
+			if upper(ts, 0) == "CONSTRAINT" && upper(ts, 2) != "FOREIGN" {
+				continue
+			}
+			schema.ForeignKeys = append(schema.ForeignKeys, parseForeignKey(ts))
+		case "PRIMARY", "UNIQUE", "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+			schema.Indexes = append(schema.Indexes, parseIndex(ts))
+		case "CHECK":
+			continue
+		default:
+			c := parseColumn(ts)
+			if c.Key == "PRI" {
+				schema.Indexes = append(schema.Indexes, DBIndex{Name: "PRIMARY", Unique: true, Columns: []string{c.Field}})
+				c.Key = ""
+			}
+			schema.Columns = append(schema.Columns, c)
+		}
+	}
+
+	// column keys come from the indexes like INFORMATION_SCHEMA.COLUMNS.COLUMN_KEY
+	for _, idx := range schema.Indexes {
+		if len(idx.Columns) == 0 {
+			continue
+		}
+		for i, c := range schema.Columns {
+			if c.Field != idx.Columns[0] || len(c.Key) > 0 {
+				continue
+			}
+			if idx.Name == "PRIMARY" {
+				schema.Columns[i].Key = "PRI"
+			} else if idx.Unique && len(idx.Columns) == 1 {
+				schema.Columns[i].Key = "UNI"
+			} else {
+				schema.Columns[i].Key = "MUL"
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// LoadSchemaDir parses every CREATE TABLE statement of the *.sql files in dir
+func LoadSchemaDir(dir string) (map[string]*TableSchema, error) {
+
+	schemas := make(map[string]*TableSchema)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stmt := range SplitStatements(string(b)) {
+
+			if !strings.HasPrefix(strings.ToUpper(stmt), "CREATE TABLE") {
+				continue
+			}
+
+			s, err := ParseCreateTable(stmt)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %v", file, err)
+			}
+			schemas[s.Name] = s
+		}
+	}
+
+	return schemas, nil
+}
+
+func stripComments(s string) string {
+
+	lines := strings.Split(s, "\n")
+
+	r := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "--") {
+			continue
+		}
+		r = append(r, l)
+	}
+
+	return strings.Join(r, "\n")
+}
+
+// SplitStatements splits a sql script into statements, dropping comment lines
+func SplitStatements(s string) []string {
+
+	stmts := make([]string, 0)
+
+	for _, stmt := range splitTopLevel(stripComments(s), ';') {
+		stmt = strings.TrimSpace(stmt)
+		if len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	return stmts
+}
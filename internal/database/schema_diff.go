@@ -0,0 +1,197 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SchemaDiffOptions struct {
+	// AllowDrop emits DROP TABLE / DROP COLUMN for tables and columns missing in the desired schema
+	AllowDrop bool
+}
+
+func sameColumn(a, b DBColumn) bool {
+
+	if normalizeType(a.Type) != normalizeType(b.Type) || a.Null != b.Null {
+		return false
+	}
+
+	if normalizeExtra(a.Extra) != normalizeExtra(b.Extra) {
+		return false
+	}
+
+	if (a.Default == nil) != (b.Default == nil) {
+		return false
+	}
+
+	return a.Default == nil || strings.EqualFold(*a.Default, *b.Default)
+}
+
+func sameIndex(a, b DBIndex) bool {
+
+	return a.Unique == b.Unique && a.Type == b.Type &&
+		strings.EqualFold(strings.Join(a.Columns, ","), strings.Join(b.Columns, ","))
+}
+
+func sameForeignKey(a, b DBForeignKey) bool {
+
+	return strings.EqualFold(strings.Join(a.Columns, ","), strings.Join(b.Columns, ",")) &&
+		strings.EqualFold(a.RefTable, b.RefTable) &&
+		strings.EqualFold(strings.Join(a.RefColumns, ","), strings.Join(b.RefColumns, ",")) &&
+		a.OnUpdate == b.OnUpdate && a.OnDelete == b.OnDelete
+}
+
+// DiffTable returns the ALTER statements converting current into desired
+func DiffTable(current, desired *TableSchema, opts SchemaDiffOptions) []string {
+
+	stmts := make([]string, 0)
+
+	table := mysqlDialect{}.Quote(desired.Name)
+	alter := func(spec string, args ...any) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s;", table, fmt.Sprintf(spec, args...)))
+	}
+
+	// foreign keys and indexes are dropped first, they may depend on changed columns
+	for _, fk := range current.ForeignKeys {
+		if dfk, ok := desired.ForeignKey(fk.Name); !ok || !sameForeignKey(fk, dfk) {
+			alter("DROP FOREIGN KEY %s", mysqlDialect{}.Quote(fk.Name))
+		}
+	}
+
+	for _, idx := range current.Indexes {
+		if didx, ok := desired.Index(idx.Name); !ok || !sameIndex(idx, didx) {
+			if idx.Name == "PRIMARY" {
+				alter("DROP PRIMARY KEY")
+			} else {
+				alter("DROP INDEX %s", mysqlDialect{}.Quote(idx.Name))
+			}
+		}
+	}
+
+	prev := ""
+	for _, c := range desired.Columns {
+
+		position := "FIRST"
+		if len(prev) > 0 {
+			position = fmt.Sprintf("AFTER %s", mysqlDialect{}.Quote(prev))
+		}
+		prev = c.Field
+
+		cc, ok := current.Column(c.Field)
+		if !ok {
+			alter("ADD COLUMN %s %s", c.Definition(), position)
+		} else if !sameColumn(cc, c) {
+			alter("MODIFY COLUMN %s", c.Definition())
+		}
+	}
+
+	if opts.AllowDrop {
+		for _, c := range current.Columns {
+			if _, ok := desired.Column(c.Field); !ok {
+				alter("DROP COLUMN %s", mysqlDialect{}.Quote(c.Field))
+			}
+		}
+	}
+
+	for _, idx := range desired.Indexes {
+		if cidx, ok := current.Index(idx.Name); !ok || !sameIndex(cidx, idx) {
+			alter("ADD %s", idx.Definition())
+		}
+	}
+
+	for _, fk := range desired.ForeignKeys {
+		if cfk, ok := current.ForeignKey(fk.Name); !ok || !sameForeignKey(cfk, fk) {
+			alter("ADD %s", fk.Definition())
+		}
+	}
+
+	co, do := current.Options, desired.Options
+
+	if len(do.Engine) > 0 && !strings.EqualFold(co.Engine, do.Engine) {
+		alter("ENGINE=%s", do.Engine)
+	}
+	if len(do.Collation) > 0 && !strings.EqualFold(co.Collation, do.Collation) {
+		alter("CONVERT TO CHARACTER SET %s COLLATE %s", do.Charset, do.Collation)
+	}
+	if co.Comment != do.Comment {
+		alter("COMMENT='%s'", strings.ReplaceAll(do.Comment, "'", "''"))
+	}
+
+	return stmts
+}
+
+// DiffSchemas returns the statements converting the current tables into the desired ones
+func DiffSchemas(current, desired map[string]*TableSchema, opts SchemaDiffOptions) []string {
+
+	stmts := make([]string, 0)
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		cs, ok := current[name]
+		if !ok {
+			stmts = append(stmts, desired[name].CreateStatement())
+			continue
+		}
+
+		stmts = append(stmts, DiffTable(cs, desired[name], opts)...)
+	}
+
+	if opts.AllowDrop {
+
+		drops := make([]string, 0)
+		for name := range current {
+			if _, ok := desired[name]; !ok {
+				drops = append(drops, name)
+			}
+		}
+		sort.Strings(drops)
+
+		for _, name := range drops {
+			stmts = append(stmts, fmt.Sprintf("DROP TABLE %s;", mysqlDialect{}.Quote(name)))
+		}
+	}
+
+	return stmts
+}
+
+// Incompatibilities returns why the rows of source can not be copied into target,
+// nil when every source column exists in target with the same type and the
+// primary keys are the same
+func Incompatibilities(source, target *TableSchema) []string {
+
+	r := make([]string, 0)
+
+	for _, c := range source.Columns {
+
+		tc, ok := target.Column(c.Field)
+		if !ok {
+			r = append(r, fmt.Sprintf("column %s is missing in %s", c.Field, target.Name))
+			continue
+		}
+
+		if !strings.EqualFold(normalizeType(c.Type), normalizeType(tc.Type)) {
+			r = append(r, fmt.Sprintf("column %s type %s differs from %s", c.Field, c.Type, tc.Type))
+		}
+	}
+
+	sk, tk := strings.Join(source.PrimaryKey(), ","), strings.Join(target.PrimaryKey(), ",")
+	if !strings.EqualFold(sk, tk) {
+		r = append(r, fmt.Sprintf("primary key (%s) differs from (%s)", sk, tk))
+	}
+
+	if len(r) == 0 {
+		return nil
+	}
+	return r
+}
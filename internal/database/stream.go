@@ -0,0 +1,305 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"github.com/jmoiron/sqlx"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultStreamSize = 1000
+
+	streamParam = "stream_last_key"
+)
+
+// Stream iterates the rows of a builder in key order with keyset pagination
+// (WHERE key > :last ORDER BY key LIMIT n), so every batch costs the same
+// however deep the stream is.
+//
+//	s := db.Stream(ctx, builder, "id", 1000)
+//	for s.Next() {
+//		row := s.Row()
+//		...
+//	}
+//	if s.Err() != nil { ... }
+//	// s.LastKey() can be saved and given to From() to resume later
+type Stream struct {
+	ctx     context.Context
+	db      *DB
+	builder *DqlBuilder
+	key     string
+	size    int
+
+	last    any
+	hasLast bool
+
+	rows    *sqlx.Rows
+	columns []string
+	types   map[string]string
+	row     map[string]any
+	fetched int
+	done    bool
+	count   int64
+	err     error
+}
+
+// Stream creates a stream over the rows of b ordered by the key column.
+// The key must be unique and selected by b, the orders and limit of b are not used.
+func (db *DB) Stream(ctx context.Context, b *DqlBuilder, key string, size int) *Stream {
+
+	if size <= 0 {
+		size = DefaultStreamSize
+	}
+
+	return &Stream{ctx: ctx, db: db, builder: b, key: key, size: size}
+}
+
+// From resumes the stream after the given key
+func (s *Stream) From(last any) *Stream {
+
+	s.last = last
+	s.hasLast = last != nil
+	return s
+}
+
+// LastKey returns the key of the current row
+func (s *Stream) LastKey() any {
+	return s.last
+}
+
+// Count returns the number of rows read so far
+func (s *Stream) Count() int64 {
+	return s.count
+}
+
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close stops the stream, it is needed only when the stream is not read to the end
+func (s *Stream) Close() error {
+
+	s.done = true
+
+	if s.rows != nil {
+		err := s.rows.Close()
+		s.rows = nil
+		return err
+	}
+	return nil
+}
+
+// column returns the result column name of the key (t.id -> id)
+func (s *Stream) column() string {
+
+	k := s.key
+	if i := strings.LastIndex(k, "."); i != -1 {
+		k = k[i+1:]
+	}
+	return strings.Trim(k, "`\"")
+}
+
+func (s *Stream) query() (string, []any, error) {
+
+	b := s.builder.clone()
+	b.Dialect(s.db.dialect)
+
+	if s.hasLast {
+		if b.named {
+			b.arg.arg[streamParam] = s.last
+			b.wheres = append(b.wheres, cond{key: s.key, op: constant.GT, param: streamParam})
+		} else {
+			b.arg.args = append(b.arg.args, s.last)
+			b.wheres = append(b.wheres, cond{key: s.key, op: constant.GT})
+		}
+	}
+
+	b.orders = []string{fmt.Sprintf("%s %s", s.key, constant.DBOrderAsc)}
+	b.limit = s.size
+	b.offset = -1
+
+	return s.db.bindDql(b)
+}
+
+func (s *Stream) fetch() bool {
+
+	q, args, err := s.query()
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	s.rows, err = s.db.QueryxContext(s.ctx, q, args...)
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	if s.columns == nil {
+		if s.columns, err = s.rows.Columns(); err != nil {
+			s.err = err
+			return false
+		}
+
+		cts, err := s.rows.ColumnTypes()
+		if err != nil {
+			s.err = err
+			return false
+		}
+		s.types = columnTypes(cts)
+	}
+
+	s.fetched = 0
+	return true
+}
+
+// Columns returns the column names in select order, available after the first Next
+func (s *Stream) Columns() []string {
+	return s.columns
+}
+
+// ColumnTypes returns the database type names of the columns (VARCHAR, BLOB ...),
+// available after the first Next
+func (s *Stream) ColumnTypes() map[string]string {
+	return s.types
+}
+
+// Next advances the stream to the next row, querying the next batch when needed
+func (s *Stream) Next() bool {
+
+	for !s.done {
+
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			s.Close()
+			return false
+		}
+
+		if s.rows == nil && !s.fetch() {
+			s.Close()
+			return false
+		}
+
+		if s.rows.Next() {
+
+			row := make(map[string]any)
+			if err := s.rows.MapScan(row); err != nil {
+				s.err = err
+				s.Close()
+				return false
+			}
+
+			last, ok := row[s.column()]
+			if !ok {
+				s.err = fmt.Errorf("stream key column is not selected : %s", s.key)
+				s.Close()
+				return false
+			}
+
+			if b, ok := last.([]byte); ok {
+				last = string(b)
+			}
+
+			s.row = row
+			s.last = last
+			s.hasLast = true
+			s.fetched++
+			s.count++
+			return true
+		}
+
+		if err := s.rows.Err(); err != nil {
+			s.err = err
+			s.Close()
+			return false
+		}
+
+		s.rows.Close()
+		s.rows = nil
+
+		// a short batch is the last one
+		if s.fetched < s.size {
+			s.done = true
+		}
+	}
+
+	return false
+}
+
+// Row returns the current row, []byte values are returned as strings
+func (s *Stream) Row() util.Interface {
+
+	var r util.Interface
+	r.New()
+
+	for k, v := range s.row {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		r.Set(k, v)
+	}
+
+	return r
+}
+
+// Scan decodes the current row into a struct (db tags) or scalars
+func (s *Stream) Scan(dest ...any) error {
+
+	if s.rows == nil {
+		return fmt.Errorf("stream has no current row")
+	}
+
+	if len(dest) == 1 && isStruct(dest[0]) {
+		return s.rows.StructScan(dest[0])
+	}
+
+	return s.rows.Scan(dest...)
+}
+
+func isStruct(dest any) bool {
+
+	if _, ok := dest.(sql.Scanner); ok {
+		return false
+	}
+
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false
+	}
+
+	return t.Elem().Kind() == reflect.Struct && t.Elem() != reflect.TypeOf(time.Time{})
+}
+
+// Rows streams the rows into a channel closed at the end of the stream,
+// check Err() after the channel is drained
+func (s *Stream) Rows(buffer int) <-chan util.Interface {
+
+	ch := make(chan util.Interface, buffer)
+
+	go func() {
+
+		defer close(ch)
+
+		for s.Next() {
+			select {
+			case ch <- s.Row():
+			case <-s.ctx.Done():
+				s.err = s.ctx.Err()
+				s.Close()
+				return
+			}
+		}
+	}()
+
+	return ch
+}
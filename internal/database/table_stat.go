@@ -0,0 +1,60 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+)
+
+// TableStat is the storage usage of a table (bytes) from INFORMATION_SCHEMA.TABLES,
+// the values are estimates cached by mysql until ANALYZE TABLE
+type TableStat struct {
+	Name        string `db:"TABLE_NAME"`
+	Engine      string `db:"ENGINE"`
+	Rows        int64  `db:"TABLE_ROWS"`
+	DataLength  int64  `db:"DATA_LENGTH"`
+	IndexLength int64  `db:"INDEX_LENGTH"`
+	DataFree    int64  `db:"DATA_FREE"`
+}
+
+// Size returns the allocated size, free space included
+func (s TableStat) Size() int64 {
+	return s.DataLength + s.IndexLength + s.DataFree
+}
+
+// Fragmentation returns the ratio (0~1) of the free space to the allocated size
+func (s TableStat) Fragmentation() float64 {
+
+	if s.Size() == 0 {
+		return 0
+	}
+	return float64(s.DataFree) / float64(s.Size())
+}
+
+const tableStatQuery = "SELECT TABLE_NAME, COALESCE(ENGINE, '') AS ENGINE, COALESCE(TABLE_ROWS, 0) AS TABLE_ROWS, " +
+	"COALESCE(DATA_LENGTH, 0) AS DATA_LENGTH, COALESCE(INDEX_LENGTH, 0) AS INDEX_LENGTH, " +
+	"COALESCE(DATA_FREE, 0) AS DATA_FREE FROM INFORMATION_SCHEMA.TABLES " +
+	"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' "
+
+// TableStats returns the storage usage of the tables starting with p
+func (db *DB) TableStats(p string) ([]TableStat, error) {
+
+	stats := make([]TableStat, 0)
+	err := db.Select(&stats, tableStatQuery+"AND TABLE_NAME LIKE ? ORDER BY TABLE_NAME", p+"%")
+	return stats, err
+}
+
+// TableStat analyzes the table first, so the usage is up to date
+func (db *DB) TableStat(t string) (TableStat, error) {
+
+	stat := TableStat{}
+
+	if _, err := db.Exec(fmt.Sprintf("ANALYZE TABLE %s", db.dialect.Quote(t))); err != nil {
+		return stat, err
+	}
+
+	err := db.Get(&stat, tableStatQuery+"AND TABLE_NAME = ?", t)
+	return stat, err
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tx is a transaction of a DB, its statements go through the hooks of the db
+type Tx struct {
+	*sqlx.Tx
+	db *DB
+}
+
+// the begin methods of the embedded *sqlx.DB are wrapped, so that every
+// transaction is hooked
+
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTxx(context.Background(), nil)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	return db.BeginTxx(ctx, opts)
+}
+
+func (db *DB) Beginx() (*Tx, error) {
+	return db.BeginTxx(context.Background(), nil)
+}
+
+func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+
+	tx, err := db.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+func (db *DB) MustBegin() *Tx {
+	return db.MustBeginTx(context.Background(), nil)
+}
+
+func (db *DB) MustBeginTx(ctx context.Context, opts *sql.TxOptions) *Tx {
+
+	tx, err := db.BeginTxx(ctx, opts)
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+// DB returns the db of the transaction
+func (tx *Tx) DB() *DB {
+	return tx.db
+}
+
+func (tx *Tx) Exec(q string, args ...any) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	return tx.db.exec(ctx, tx.Tx, q, args)
+}
+
+func (tx *Tx) MustExec(q string, args ...any) sql.Result {
+	return tx.MustExecContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) MustExecContext(ctx context.Context, q string, args ...any) sql.Result {
+
+	r, err := tx.ExecContext(ctx, q, args...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (tx *Tx) Query(q string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	return tx.db.query(ctx, tx.Tx, q, args)
+}
+
+func (tx *Tx) Queryx(q string, args ...any) (*sqlx.Rows, error) {
+	return tx.QueryxContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) QueryxContext(ctx context.Context, q string, args ...any) (*sqlx.Rows, error) {
+	return tx.db.queryx(ctx, tx.Tx, q, args)
+}
+
+func (tx *Tx) QueryRow(q string, args ...any) *sql.Row {
+	return tx.QueryRowContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, q string, args ...any) *sql.Row {
+	return tx.db.queryRow(ctx, tx.Tx, q, args)
+}
+
+func (tx *Tx) QueryRowx(q string, args ...any) *sqlx.Row {
+	return tx.QueryRowxContext(context.Background(), q, args...)
+}
+
+func (tx *Tx) QueryRowxContext(ctx context.Context, q string, args ...any) *sqlx.Row {
+	return tx.db.queryRowx(ctx, tx.Tx, q, args)
+}
+
+func (tx *Tx) Select(dest any, q string, args ...any) error {
+	return tx.SelectContext(context.Background(), dest, q, args...)
+}
+
+func (tx *Tx) SelectContext(ctx context.Context, dest any, q string, args ...any) error {
+	return tx.db.selectRows(ctx, tx.Tx, dest, q, args)
+}
+
+func (tx *Tx) Get(dest any, q string, args ...any) error {
+	return tx.GetContext(context.Background(), dest, q, args...)
+}
+
+func (tx *Tx) GetContext(ctx context.Context, dest any, q string, args ...any) error {
+	return tx.db.get(ctx, tx.Tx, dest, q, args)
+}
+
+func (tx *Tx) NamedExec(q string, arg any) (sql.Result, error) {
+	return tx.NamedExecContext(context.Background(), q, arg)
+}
+
+func (tx *Tx) NamedExecContext(ctx context.Context, q string, arg any) (sql.Result, error) {
+	return tx.db.namedExec(ctx, tx.Tx, q, arg)
+}
+
+func (tx *Tx) NamedQuery(q string, arg any) (*sqlx.Rows, error) {
+	return tx.db.namedQuery(context.Background(), tx.Tx, q, arg)
+}
@@ -1,11 +1,17 @@
 package es
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/alcomist/go-portfolio/internal/config"
 	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +22,7 @@ type ElasticInstance struct {
 	cluster string
 
 	MajorVersion int
+	MinorVersion int
 }
 
 type Header struct {
@@ -39,66 +46,197 @@ type Request struct {
 	Scroll time.Duration
 }
 
-func mustGetConfig(s string) elasticsearch7.Config {
+// getConfig reads the client config of the cluster section
+//
+// host = http://10.0.0.1:9200 (repeatable)
+// username = elastic, password = ... or api_key = base64(id:key)
+// ca_cert = /path/ca.pem
+// dial_timeout = 5s, response_timeout = 60s, max_idle_conns = 10
+// compress = true, max_retries = 3
+func getConfig(s string) (elasticsearch7.Config, error) {
 
 	var cfg elasticsearch7.Config
 
-	section := config.MustGet(s)
+	section, ok := config.Get(s)
+	if !ok {
+		return cfg, fmt.Errorf("ini file has no section : %v", s)
+	}
 
 	hosts := section.Key("host").ValueWithShadows()
 	if len(hosts) == 0 {
-		log.Fatal(fmt.Errorf("section has no hosts : %v", section))
+		return cfg, fmt.Errorf("section has no hosts : %v", section.Name())
 	}
 
 	cfg.Addresses = hosts
 	cfg.RetryOnStatus = []int{502, 503, 504, 429}
-	return cfg
+
+	cfg.Username = section.Key("username").String()
+	cfg.Password = section.Key("password").String()
+	cfg.APIKey = section.Key("api_key").String()
+
+	if f := section.Key("ca_cert").String(); len(f) > 0 {
+		ca, err := os.ReadFile(f)
+		if err != nil {
+			return cfg, fmt.Errorf("error reading the ca cert of %s : %w", section.Name(), err)
+		}
+		cfg.CACert = ca
+	}
+
+	cfg.CompressRequestBody = section.Key("compress").MustBool(false)
+	cfg.MaxRetries = section.Key("max_retries").MustInt(3)
+
+	dialer := &net.Dialer{
+		Timeout:   section.Key("dial_timeout").MustDuration(5 * time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+
+	cfg.Transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ResponseHeaderTimeout: section.Key("response_timeout").MustDuration(0),
+		MaxIdleConnsPerHost:   section.Key("max_idle_conns").MustInt(http.DefaultMaxIdleConnsPerHost),
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+	}
+
+	return cfg, nil
 }
 
-func MustGet(cluster string) ElasticInstance {
+// newInstance creates the client of the cluster section, without the server version
+func newInstance(cluster string) (ElasticInstance, error) {
 
-	cfg := mustGetConfig(cluster)
+	cfg, err := getConfig(cluster)
+	if err != nil {
+		return ElasticInstance{}, err
+	}
 
-	es, err := elasticsearch7.NewClient(cfg)
+	return newConfigInstance(cluster, cfg)
+}
+
+func newConfigInstance(cluster string, cfg elasticsearch7.Config) (ElasticInstance, error) {
+
+	client, err := elasticsearch7.NewClient(cfg)
 	if err != nil {
-		log.Fatalf("Error creating the client: %s", err)
+		return ElasticInstance{}, fmt.Errorf("error creating the client: %w", err)
 	}
 
-	res, err := es.Info()
+	return ElasticInstance{client: client, cluster: cluster}, nil
+}
+
+// readVersion reads the server version of the instance
+func (e *ElasticInstance) readVersion(ctx context.Context) error {
+
+	var r map[string]any
+	if err := e.perform(ctx, esapi.InfoRequest{}, "", &r); err != nil {
+		return err
+	}
+
+	val, err := NestedMapLookup(r, "version", "number")
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Fatalf("Error: %s", res.String())
+	version, _ := val.(string)
+
+	vs := strings.Split(version, ".")
+	major, err := strconv.Atoi(vs[0])
+	if err != nil {
+		return fmt.Errorf("invalid server version %s : %w", version, err)
 	}
 
-	var r map[string]any
+	minor := 0
+	if len(vs) > 1 {
+		minor, _ = strconv.Atoi(vs[1])
+	}
 
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
+	if e.MajorVersion != major || e.MinorVersion != minor {
+		log.Printf("[%s] elasticsearch server : %s", e.cluster, version)
 	}
 
-	ks := []string{"version", "number"}
-	val, err := NestedMapLookup(r, ks...)
+	e.MajorVersion, e.MinorVersion = major, minor
+	return nil
+}
+
+// Connect creates a new client of the cluster section and reads the server
+// version, GetContext returns the cached one
+func Connect(ctx context.Context, cluster string) (ElasticInstance, error) {
+
+	e, err := newInstance(cluster)
+	if err != nil {
+		return ElasticInstance{}, err
+	}
+
+	if err := e.readVersion(ctx); err != nil {
+		return ElasticInstance{}, err
+	}
+
+	return e, nil
+}
+
+// ConnectConfig creates a new client of cfg, a cluster out of the ini file,
+// and reads the server version. The instance is not cached.
+func ConnectConfig(ctx context.Context, cluster string, cfg elasticsearch7.Config) (ElasticInstance, error) {
+
+	e, err := newConfigInstance(cluster, cfg)
+	if err != nil {
+		return ElasticInstance{}, err
+	}
+
+	if err := e.readVersion(ctx); err != nil {
+		return ElasticInstance{}, err
+	}
+
+	return e, nil
+}
+
+// MustGet returns the cached instance of the cluster section
+func MustGet(cluster string) ElasticInstance {
+
+	e, err := GetContext(context.Background(), cluster)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	return e
+}
 
-	version := val.(string)
+// perform runs the request and decodes the response body into v (when not nil),
+// an API error is returned as *Error
+func (e *ElasticInstance) perform(ctx context.Context, req esapi.Request, index string, v any) error {
 
-	vs := strings.Split(version, ".")
-	vn, err := strconv.Atoi(vs[0])
+	res, err := req.Do(ctx, e.client)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return fmt.Errorf("[%s] error getting response: %w", e.cluster, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newError(res, index)
 	}
-	log.Printf("[%s] elasticsearch server : %s", cluster, version)
-	return ElasticInstance{es, cluster, vn}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil && err != io.EOF {
+		return fmt.Errorf("error parsing the response body: %w", err)
+	}
+
+	return nil
 }
 
+// NewGenerator returns the pages of the search, with point in time and search_after
+// when the server supports it (7.10+), with scroll otherwise
 func NewGenerator(e ElasticInstance, req *Request) func() *Response {
 
+	if e.SupportsPIT() {
+		return NewPaginator(e, req).Generator()
+	}
+
+	return newScrollGenerator(e, req)
+}
+
+func newScrollGenerator(e ElasticInstance, req *Request) func() *Response {
+
 	var response *Response
 
 	return func() *Response {
@@ -127,6 +265,20 @@ func (e *ElasticInstance) IsMajorVersion(n int) bool {
 	return false
 }
 
+// IsVersionAtLeast tells if the server version is major.minor or later
+func (e *ElasticInstance) IsVersionAtLeast(major, minor int) bool {
+
+	if e.MajorVersion != major {
+		return e.MajorVersion > major
+	}
+	return e.MinorVersion >= minor
+}
+
+// SupportsPIT tells if the server has the point in time API
+func (e *ElasticInstance) SupportsPIT() bool {
+	return e.IsVersionAtLeast(7, 10)
+}
+
 func (e *ElasticInstance) TotalCount(m map[string]any) int64 {
 
 	ks := []string{"hits", "total"}
@@ -0,0 +1,384 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"context"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"strings"
+)
+
+// Agg is an aggregation of the search body with its sub aggregations
+type Agg struct {
+	kind   string
+	params map[string]any
+	subs   map[string]*Agg
+}
+
+func newAgg(kind string, params map[string]any) *Agg {
+	return &Agg{kind: kind, params: params, subs: map[string]*Agg{}}
+}
+
+func NewTermsAgg(field string, size int) *Agg {
+	return newAgg("terms", map[string]any{"field": field, "size": size})
+}
+
+// the interval keys of a date histogram
+const (
+	IntervalCalendar = "calendar_interval" // 1m, 1h, 1d, 1w, 1M, 1q, 1y, 7.2+
+	IntervalFixed    = "fixed_interval"    // a multiple of ms, s, m, h or d, 7.2+
+	IntervalLegacy   = "interval"          // both before 7.2
+)
+
+var calendarIntervals = map[string]bool{
+	"1m": true, "1h": true, "1d": true, "1w": true, "1M": true, "1q": true, "1y": true,
+	"minute": true, "hour": true, "day": true, "week": true, "month": true, "quarter": true, "year": true,
+}
+
+// DateIntervalKey returns the key of the date histogram interval on the server,
+// a single calendar unit is a calendar interval, a multiple (7d, 30m ...) a fixed one
+func (e *ElasticInstance) DateIntervalKey(interval string) string {
+
+	if !e.IsVersionAtLeast(7, 2) {
+		return IntervalLegacy
+	}
+
+	if calendarIntervals[interval] {
+		return IntervalCalendar
+	}
+	return IntervalFixed
+}
+
+// NewDateHistogramAgg buckets by the interval of the key, see DateIntervalKey
+func NewDateHistogramAgg(field, key, interval string) *Agg {
+	return newAgg("date_histogram", map[string]any{"field": field, key: interval})
+}
+
+func NewHistogramAgg(field string, interval float64) *Agg {
+	return newAgg("histogram", map[string]any{"field": field, "interval": interval})
+}
+
+// NewRangeAgg buckets by the ranges added with Range
+func NewRangeAgg(field string) *Agg {
+	return newAgg("range", map[string]any{"field": field, "ranges": []any{}})
+}
+
+// NewFiltersAgg buckets by the queries added with Filter
+func NewFiltersAgg() *Agg {
+	return newAgg("filters", map[string]any{"filters": map[string]any{}})
+}
+
+func NewCardinalityAgg(field string) *Agg {
+	return newAgg("cardinality", map[string]any{"field": field})
+}
+
+func NewStatsAgg(field string) *Agg {
+	return newAgg("stats", map[string]any{"field": field})
+}
+
+// NewPercentilesAgg returns the percentiles of the field, 1 5 25 50 75 95 99 when none
+func NewPercentilesAgg(field string, percents ...float64) *Agg {
+
+	a := newAgg("percentiles", map[string]any{"field": field})
+	if len(percents) > 0 {
+		a.params["percents"] = percents
+	}
+	return a
+}
+
+func NewTopHitsAgg(size int) *Agg {
+	return newAgg("top_hits", map[string]any{"size": size})
+}
+
+// NewNestedAgg runs the sub aggregations on the nested docs of the path
+func NewNestedAgg(path string) *Agg {
+	return newAgg("nested", map[string]any{"path": path})
+}
+
+// Param sets a parameter of the aggregation, e.g. min_doc_count, format, missing
+func (a *Agg) Param(k string, v any) *Agg {
+	a.params[k] = v
+	return a
+}
+
+// Order sorts the buckets, k is _key, _count or a sub aggregation
+func (a *Agg) Order(k, order string) *Agg {
+	return a.Param("order", map[string]any{k: order})
+}
+
+// Range adds a range bucket, a bound left nil is open
+func (a *Agg) Range(key string, from, to any) *Agg {
+
+	r := map[string]any{}
+	if len(key) > 0 {
+		r["key"] = key
+	}
+	if from != nil {
+		r["from"] = from
+	}
+	if to != nil {
+		r["to"] = to
+	}
+
+	ranges, _ := a.params["ranges"].([]any)
+	a.params["ranges"] = append(ranges, r)
+	return a
+}
+
+// Filter adds a filter bucket
+func (a *Agg) Filter(name string, q Query) *Agg {
+
+	filters, _ := a.params["filters"].(map[string]any)
+	if filters == nil {
+		filters = map[string]any{}
+		a.params["filters"] = filters
+	}
+	filters[name] = q.Source()
+	return a
+}
+
+// Sort sorts the top hits, order is asc or desc
+func (a *Agg) Sort(field, order string) *Agg {
+
+	s, _ := a.params["sort"].([]any)
+	a.params["sort"] = append(s, map[string]any{field: map[string]any{"order": order}})
+	return a
+}
+
+// SourceFields filters the _source of the top hits
+func (a *Agg) SourceFields(includes ...string) *Agg {
+	return a.Param("_source", map[string]any{"includes": includes})
+}
+
+// Sub adds a sub aggregation, run in each bucket
+func (a *Agg) Sub(name string, sub *Agg) *Agg {
+	a.subs[name] = sub
+	return a
+}
+
+func (a *Agg) Source() map[string]any {
+
+	m := map[string]any{a.kind: a.params}
+	if len(a.subs) > 0 {
+		m["aggs"] = aggSources(a.subs)
+	}
+	return m
+}
+
+func aggSources(as map[string]*Agg) map[string]any {
+
+	m := make(map[string]any, len(as))
+	for name, a := range as {
+		m[name] = a.Source()
+	}
+	return m
+}
+
+// Agg adds a named aggregation to the search body
+func (s *SearchSource) Agg(name string, a *Agg) *SearchSource {
+
+	if s.aggs == nil {
+		s.aggs = map[string]any{}
+	}
+	s.aggs[name] = a.Source()
+	return s
+}
+
+// AggResult is a node of the aggregations of a search response: the root, an
+// aggregation or a bucket. The methods of a nil result return zero values, so
+// a path can be walked without checking each step.
+type AggResult struct {
+	e   ElasticInstance
+	key string
+	raw map[string]any
+}
+
+type Stats struct {
+	Count int64
+	Min   float64
+	Max   float64
+	Avg   float64
+	Sum   float64
+}
+
+func (r *AggResult) float(k string) float64 {
+
+	f, _ := r.raw[k].(float64)
+	return f
+}
+
+// Agg returns the sub aggregation, nil when it does not exist
+func (r *AggResult) Agg(name string) *AggResult {
+
+	if r == nil {
+		return nil
+	}
+
+	m, ok := r.raw[name].(map[string]any)
+	if !ok {
+		return nil
+	}
+	return &AggResult{e: r.e, key: name, raw: m}
+}
+
+// Buckets returns the buckets, in the order of the response for an array and
+// sorted by key for keyed buckets (filters)
+func (r *AggResult) Buckets() []*AggResult {
+
+	buckets := make([]*AggResult, 0)
+	if r == nil {
+		return buckets
+	}
+
+	switch bs := r.raw["buckets"].(type) {
+	case []any:
+		for _, b := range bs {
+			m, _ := b.(map[string]any)
+			buckets = append(buckets, &AggResult{e: r.e, raw: m})
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(bs) {
+			m, _ := bs[k].(map[string]any)
+			buckets = append(buckets, &AggResult{e: r.e, key: k, raw: m})
+		}
+	}
+
+	return buckets
+}
+
+// Key returns the key of a bucket, a number for the histograms and a map
+// of the sources for a composite aggregation
+func (r *AggResult) Key() any {
+
+	if r == nil {
+		return nil
+	}
+	if k, ok := r.raw["key"]; ok {
+		return k
+	}
+	return r.key
+}
+
+// KeyString returns the key_as_string of a bucket, or its key formatted
+func (r *AggResult) KeyString() string {
+
+	if r == nil {
+		return ""
+	}
+	if s, ok := r.raw["key_as_string"].(string); ok {
+		return s
+	}
+
+	switch k := r.Key().(type) {
+	case string:
+		return k
+	case float64:
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%f", k), "0"), ".")
+	default:
+		return fmt.Sprintf("%v", k)
+	}
+}
+
+// DocCount returns the doc_count of a bucket or of a single bucket aggregation (nested)
+func (r *AggResult) DocCount() int64 {
+
+	if r == nil {
+		return 0
+	}
+	return int64(r.float("doc_count"))
+}
+
+// Value returns the value of a single value metric (cardinality, avg ...)
+func (r *AggResult) Value() float64 {
+
+	if r == nil {
+		return 0
+	}
+	return r.float("value")
+}
+
+func (r *AggResult) Stats() Stats {
+
+	if r == nil {
+		return Stats{}
+	}
+	return Stats{
+		Count: int64(r.float("count")),
+		Min:   r.float("min"),
+		Max:   r.float("max"),
+		Avg:   r.float("avg"),
+		Sum:   r.float("sum"),
+	}
+}
+
+// Percentiles returns the values by percent, e.g. "99.0"
+func (r *AggResult) Percentiles() map[string]float64 {
+
+	ps := map[string]float64{}
+	if r == nil {
+		return ps
+	}
+
+	values, _ := r.raw["values"].(map[string]any)
+	for k, v := range values {
+		if f, ok := v.(float64); ok {
+			ps[k] = f
+		}
+	}
+	return ps
+}
+
+// Hits returns the docs of a top_hits aggregation
+func (r *AggResult) Hits() Documents {
+
+	docs := Documents{}
+	if r == nil {
+		return docs
+	}
+
+	val, err := NestedMapLookup(r.raw, "hits", "hits")
+	if err != nil {
+		return docs
+	}
+
+	hits, _ := val.([]any)
+	for _, hit := range hits {
+		docs = append(docs, r.e.doc(hit))
+	}
+	return docs
+}
+
+// AggregationsContext runs the search without hits and returns the root of its aggregations
+func (e *ElasticInstance) AggregationsContext(ctx context.Context, req *Request) (*AggResult, error) {
+
+	size := 0
+
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.SearchRequest{
+		Index: []string{req.Index},
+		Size:  &size,
+		Body:  strings.NewReader(req.Query)}, req.Index, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		return nil, fmt.Errorf("[%s] empty search response", req.Index)
+	}
+
+	return e.AggregationResult(r), nil
+}
+
+// AggregationResult returns the root of the aggregations of a search response
+func (e *ElasticInstance) AggregationResult(r map[string]any) *AggResult {
+
+	aggs, _ := r["aggregations"].(map[string]any)
+	if aggs == nil {
+		aggs = map[string]any{}
+	}
+	return &AggResult{e: *e, raw: aggs}
+}
@@ -1,24 +1,50 @@
 package es
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/alcomist/go-portfolio/internal/util"
-	"github.com/dustin/go-humanize"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"github.com/elastic/go-elasticsearch/v7/esutil"
-	"io"
 	"log"
-	"runtime"
-	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 )
 
-func (e *ElasticInstance) Search(req *Request) *Response {
+func (e *ElasticInstance) doc(hit any) *Doc {
+
+	h := e.Header(hit)
+
+	var s util.Interface
+	if _source, ok := hit.(map[string]any)["_source"].(map[string]any); ok {
+		s.From(_source)
+	}
+
+	routing, _ := hit.(map[string]any)["_routing"].(string)
+
+	return &Doc{Id: h.Id, Index: h.Index, Routing: routing, Source: s}
+}
+
+// addHits adds the hits.hits of r to the response, returns the hit count
+func (e *ElasticInstance) addHits(response *Response, r map[string]any) (int, error) {
+
+	val, err := NestedMapLookup(r, "hits", "hits")
+	if err != nil {
+		return 0, err
+	}
+
+	hits, _ := val.([]any)
+
+	response.Result.SetDocCount(len(hits))
+	response.Result.AddProcessedCount(int64(len(hits)))
+
+	for _, hit := range hits {
+		response.Result.Docs = append(response.Result.Docs, e.doc(hit))
+	}
+
+	return len(hits), nil
+}
+
+func (e *ElasticInstance) SearchContext(ctx context.Context, req *Request) (*Response, error) {
 
 	var sizePtr *int = nil
 	if req.Size > 0 {
@@ -29,442 +55,237 @@ func (e *ElasticInstance) Search(req *Request) *Response {
 	response.Reserve(req.Size)
 	response.Result.scroll = req.Scroll
 
-	res, err := esapi.SearchRequest{
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.SearchRequest{
 		Index:  []string{req.Index},
 		Scroll: req.Scroll,
 		Size:   sizePtr,
-		Body:   strings.NewReader(req.Query)}.Do(context.Background(), e.client)
+		Body:   strings.NewReader(req.Query)}, req.Index, &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		log.Printf("[%s] Error string search documents", res.String())
-		return nil
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return nil
+	if r == nil {
+		return nil, fmt.Errorf("[%s] empty search response", req.Index)
 	}
 
-	if n > 0 {
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return nil
-		}
-
-		response.Result.SetTotalCount(e.TotalCount(r))
-		response.Result.SetScrollId(e.ScrollId(r))
-
-		//log.Printf("total: %d\n", response.Total)
-
-		val, err := NestedMapLookup(r, "hits", "hits")
-		if err != nil {
-			log.Println(err)
-			return nil
-		}
-
-		hits := val.([]any)
-
-		response.Result.SetDocCount(len(hits))
-		response.Result.AddProcessedCount(int64(response.Result.DocCount()))
+	response.Result.SetTotalCount(e.TotalCount(r))
+	response.Result.SetScrollId(e.ScrollId(r))
 
-		for _, hit := range hits {
-
-			h := e.Header(hit)
-			_source := hit.(map[string]any)["_source"]
+	if _, err := e.addHits(response, r); err != nil {
+		return nil, err
+	}
 
-			var s util.Interface
-			s.From(_source.(map[string]interface{}))
+	return response, nil
+}
 
-			response.Result.Docs = append(response.Result.Docs, &Doc{Id: h.Id, Index: h.Index, Source: s})
-		}
+func (e *ElasticInstance) Search(req *Request) *Response {
 
-		return response
+	response, err := e.SearchContext(context.Background(), req)
+	if err != nil {
+		log.Printf("Error search documents : %s", err)
+		return nil
 	}
-
-	return nil
+	return response
 }
 
-func (e *ElasticInstance) SearchAggs(req *Request) *Response {
+// SearchAggsContext returns the top hits (aggs_top_hits) of the buckets of the
+// aggs_hashcode aggregation, see AggregationsContext for the other aggregations
+func (e *ElasticInstance) SearchAggsContext(ctx context.Context, req *Request) (*Response, error) {
 
 	response := NewResponse()
 	response.Reserve(req.Size)
 	response.Result.scroll = time.Duration(0)
 
-	res, err := esapi.SearchRequest{
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.SearchRequest{
 		Index:  []string{req.Index},
 		Scroll: response.Result.scroll,
 		Size:   &req.Size,
-		Body:   strings.NewReader(req.Query)}.Do(context.Background(), e.client)
+		Body:   strings.NewReader(req.Query)}, req.Index, &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		return nil
+	if r == nil {
+		return nil, fmt.Errorf("[%s] empty search response", req.Index)
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return nil
-	}
-
-	if n > 0 {
-
-		//os.WriteFile("./dat1", buf.Bytes(), 0644)
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return nil
-		}
-
-		response.Result.SetTotalCount(e.TotalCount(r))
-
-		ks := []string{"aggregations", "aggs_hashcode", "buckets"}
-
-		buckets, err := NestedMapLookup(r, ks...)
-		if err != nil {
-			log.Println(err)
-			return nil
-		}
-
-		hitCount := 0
+	response.Result.SetTotalCount(e.TotalCount(r))
 
-		for _, bucket := range buckets.([]any) {
-
-			ks = []string{"aggs_top_hits", "hits", "hits"}
+	aggs := e.AggregationResult(r).Agg("aggs_hashcode")
+	if aggs == nil {
+		return nil, fmt.Errorf("[%s] no aggs_hashcode aggregation", req.Index)
+	}
 
-			hits, err := NestedMapLookup(bucket.(map[string]any), ks...)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+	hitCount := 0
 
-			for _, hit := range hits.([]any) {
+	for _, bucket := range aggs.Buckets() {
 
-				hitCount++
+		hits := bucket.Agg("aggs_top_hits").Hits()
 
-				h := e.Header(hit)
-				_source := hit.(map[string]any)["_source"]
+		hitCount += len(hits)
+		response.Result.Docs = append(response.Result.Docs, hits...)
+	}
 
-				var s util.Interface
-				s.From(_source.(map[string]interface{}))
+	response.Result.SetDocCount(hitCount)
+	response.Result.AddProcessedCount(int64(hitCount))
 
-				response.Result.Docs = append(response.Result.Docs, &Doc{Id: h.Id, Index: h.Index, Source: s})
-			}
-		}
+	return response, nil
+}
 
-		response.Result.SetDocCount(hitCount)
-		response.Result.AddProcessedCount(int64(hitCount))
+func (e *ElasticInstance) SearchAggs(req *Request) *Response {
 
-		return response
+	response, err := e.SearchAggsContext(context.Background(), req)
+	if err != nil {
+		log.Printf("Error search documents : %s", err)
+		return nil
 	}
-
-	return nil
+	return response
 }
 
-func (e *ElasticInstance) Scroll(response *Response) bool {
+// ScrollContext replaces the docs of the response by the next page,
+// false when there is no more docs
+func (e *ElasticInstance) ScrollContext(ctx context.Context, response *Response) (bool, error) {
 
 	response.Result.Docs = Documents{}
 
-	res, err := esapi.ScrollRequest{
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.ScrollRequest{
 		ScrollID: response.Result.scrollID,
-		Scroll:   response.Result.scroll}.Do(context.Background(), e.client)
+		Scroll:   response.Result.scroll}, "", &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return false, err
 	}
-	defer res.Body.Close()
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return false
+	if r == nil {
+		return false, nil
 	}
 
-	if n > 0 {
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-		}
-
-		sid := e.ScrollId(r)
-		if response.Result.scrollID != sid {
-			response.Result.scrollID = sid
-			log.Printf("scroll id has been changed: (%s => %s)\n", response.Result.scrollID, sid)
-		}
-
-		response.Result.SetTotalCount(e.TotalCount(r))
-
-		val, err := NestedMapLookup(r, "hits", "hits")
-		if err != nil {
-			log.Println(err)
-			return false
-		}
-
-		hits := val.([]any)
-
-		hitCount := len(hits)
-
-		response.Result.SetDocCount(hitCount)
-		if response.Result.DocCount() == 0 {
-			return false
-		}
-
-		response.Result.AddProcessedCount(int64(hitCount))
-
-		for _, hit := range hits {
-
-			h := e.Header(hit)
-			_source := hit.(map[string]any)["_source"]
-
-			var s util.Interface
-			s.From(_source.(map[string]interface{}))
+	sid := e.ScrollId(r)
+	if response.Result.scrollID != sid {
+		log.Printf("scroll id has been changed: (%s => %s)\n", response.Result.scrollID, sid)
+		response.Result.scrollID = sid
+	}
 
-			response.Result.Docs = append(response.Result.Docs, &Doc{Id: h.Id, Index: h.Index, Source: s})
-		}
+	response.Result.SetTotalCount(e.TotalCount(r))
 
-		return true
+	n, err := e.addHits(response, r)
+	if err != nil {
+		return false, err
 	}
 
-	return false
+	return n > 0, nil
 }
 
-func (e *ElasticInstance) BulkInsert(index string, docs []*Doc) bool {
-
-	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Index:      index,
-		Client:     e.client,
-		NumWorkers: runtime.NumCPU(),
-	})
+func (e *ElasticInstance) Scroll(response *Response) bool {
 
+	ok, err := e.ScrollContext(context.Background(), response)
 	if err != nil {
-		log.Fatalf("new bulk indexer error %s\n", err)
+		log.Printf("Error scroll documents : %s", err)
+		return false
 	}
+	return ok
+}
 
-	var countSuccessful uint64
-	start := time.Now().UTC()
-
-	retryOnConflict := 1
-
-	ctx := context.Background()
-
-	for _, doc := range docs {
-
-		data, err := json.Marshal(doc.Source)
-		if err != nil {
-			log.Fatalf("Cannot encode item %s: %s\n", doc.Index, doc.Id)
-		}
-
-		id := strconv.FormatInt(doc.Int64("mall_product_id"), 10)
-
-		err = indexer.Add(
-
-			ctx,
-			esutil.BulkIndexerItem{
-
-				Index:           index,
-				RetryOnConflict: &retryOnConflict,
-
-				// Action field configures the operation to perform (index, create, delete, update)
-				Action: "create",
-
-				// DocumentID is the (optional) document ID
-				DocumentID: id,
-
-				// Body is an `io.Reader` with the payload
-				///Body: bytes.NewReader(b),
-				Body: bytes.NewReader(data),
-
-				// OnSuccess is called for each successful operation
-				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					atomic.AddUint64(&countSuccessful, 1)
-				},
+// BulkContext sends the NDJSON bulk body through a BulkWriter with the refresh
+// policy, the first failed item is returned as *Error
+func (e *ElasticInstance) BulkContext(ctx context.Context, b []byte, refresh string) error {
 
-				// OnFailure is called for each failed operation
-				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						fmt.Printf("ERROR: %s", err)
-					} else {
-						fmt.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
-					}
-				},
-			},
-		)
+	w := NewBulkWriter(ctx, *e)
+	w.Refresh(refresh)
 
-		if err != nil {
-			log.Fatalf("Unexpected error: %s", err)
-		}
+	if _, err := w.Write(b); err != nil {
+		return err
 	}
 
-	if err := indexer.Close(ctx); err != nil {
-		log.Fatalf("Unexpected error: %s", err)
-	}
-
-	biStats := indexer.Stats()
-
-	dur := time.Since(start)
-
-	if biStats.NumFailed > 0 {
-		log.Fatalf(
-			"Indexed [%s] documents with [%s] errors in %s (%s docs/sec)",
-			humanize.Comma(int64(biStats.NumFlushed)),
-			humanize.Comma(int64(biStats.NumFailed)),
-			dur.Truncate(time.Millisecond),
-			humanize.Comma(int64(1000.0/float64(dur/time.Millisecond)*float64(biStats.NumFlushed))),
-		)
-	} else {
-		log.Printf(
-			"Sucessfuly indexed [%s] documents in %s (%s docs/sec)",
-			humanize.Comma(int64(biStats.NumFlushed)),
-			dur.Truncate(time.Millisecond),
-			humanize.Comma(int64(1000.0/float64(dur/time.Millisecond)*float64(biStats.NumFlushed))),
-		)
+	if err := w.Close(); err != nil {
+		return err
 	}
 
-	return true
+	return w.Report().Err()
 }
 
+// Bulk sends the NDJSON bulk body and refreshes the indices
 func (e *ElasticInstance) Bulk(b []byte) bool {
 
-	res, err := esapi.BulkRequest{
-		Body: bytes.NewReader(b), Refresh: "true"}.Do(context.Background(), e.client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("[%s] Error bulk insert", res.Status())
+	if err := e.BulkContext(context.Background(), b, "true"); err != nil {
+		log.Printf("Error bulk insert : %s", err)
 		return false
 	}
-
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return false
-	}
-
-	if n > 0 {
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return false
-		}
-
-	}
-
 	return true
 }
 
-func (e *ElasticInstance) Aggregate(index, field, query string) []any {
+// AggregateContext returns the bucket keys of the field terms aggregation
+func (e *ElasticInstance) AggregateContext(ctx context.Context, index, field, query string) ([]any, error) {
 
 	rs := make([]any, 0)
 
 	size := 0
 
-	res, err := esapi.SearchRequest{
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.SearchRequest{
 		Index:  []string{index},
 		Scroll: time.Duration(0),
 		Size:   &size,
-		Body:   strings.NewReader(query)}.Do(context.Background(), e.client)
+		Body:   strings.NewReader(query)}, index, &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return rs, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		return rs
+	if r == nil {
+		return rs, nil
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return rs
+	aggs := e.AggregationResult(r).Agg(field)
+	if aggs == nil {
+		return rs, fmt.Errorf("error getting buckets: no %s aggregation", field)
 	}
 
-	if n > 0 {
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-		}
-
-		buckets, err := NestedMapLookup(r, "aggregations", field, "buckets")
-		if err != nil {
-			log.Printf("Error getting buckets: %s\n", err)
-			return rs
-		}
-
-		for _, bucket := range buckets.([]any) {
-			key := bucket.(map[string]any)["key"]
-			rs = append(rs, key)
-		}
-
-		return rs
+	for _, bucket := range aggs.Buckets() {
+		rs = append(rs, bucket.Key())
 	}
 
-	return rs
+	return rs, nil
 }
 
-func (e *ElasticInstance) ClearScroll(scrollID string) bool {
+func (e *ElasticInstance) Aggregate(index, field, query string) []any {
 
-	res, err := esapi.ClearScrollRequest{ScrollID: []string{scrollID}}.Do(context.Background(), e.client)
+	rs, err := e.AggregateContext(context.Background(), index, field, query)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		log.Printf("Error aggregate : %s", err)
 	}
-	defer res.Body.Close()
+	return rs
+}
 
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		return false
-	}
+func (e *ElasticInstance) ClearScrollContext(ctx context.Context, scrollID string) (bool, error) {
+
+	var r map[string]any
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
+	err := e.perform(ctx, esapi.ClearScrollRequest{ScrollID: []string{scrollID}}, "", &r)
 	if err != nil {
-		log.Println(err)
-		return false
+		return false, err
 	}
 
-	if n > 0 {
-
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return false
-		}
+	val, ok := r["succeeded"].(bool)
+	return ok && val, nil
+}
 
-		val, ok := r["succeeded"]
-		if ok {
-			return val.(bool)
-		}
+func (e *ElasticInstance) ClearScroll(scrollID string) bool {
 
+	ok, err := e.ClearScrollContext(context.Background(), scrollID)
+	if err != nil {
+		log.Printf("Error clear scroll : %s", err)
 		return false
 	}
-
-	return false
+	return ok
 }
 
-func (e *ElasticInstance) Cardinality(index, field string, builder *QueryStringQueryBuilder) int {
+func (e *ElasticInstance) CardinalityContext(ctx context.Context, index, field string, builder *QueryStringQueryBuilder) (int, error) {
 
 	term := CardinalityAggregationTerm(field)
 	builder.AddAggregation(term)
@@ -472,125 +293,104 @@ func (e *ElasticInstance) Cardinality(index, field string, builder *QueryStringQ
 
 	size := 0
 
-	res, err := esapi.SearchRequest{
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.SearchRequest{
 		Index:  []string{index},
 		Scroll: time.Duration(0),
 		Size:   &size,
-		Body:   strings.NewReader(query)}.Do(context.Background(), e.client)
+		Body:   strings.NewReader(query)}, index, &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return 0, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		return 0
+	if r == nil {
+		return 0, nil
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
+	ks := []string{"aggregations", field, "value"}
+	val, err := NestedMapLookup(r, ks...)
 	if err != nil {
-		log.Println(err)
-		return 0
+		return -1, err
 	}
 
-	if n > 0 {
+	count, ok := val.(float64)
+	if !ok {
+		return -1, fmt.Errorf("[%s] invalid cardinality of %s : %v", index, field, val)
+	}
 
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return 0
-		}
+	return int(count), nil
+}
 
-		ks := []string{"aggregations", field, "value"}
-		val, err := NestedMapLookup(r, ks...)
-		if err != nil {
-			log.Println(err)
-			return -1
-		}
+func (e *ElasticInstance) Cardinality(index, field string, builder *QueryStringQueryBuilder) int {
 
-		return int(val.(float64))
+	c, err := e.CardinalityContext(context.Background(), index, field, builder)
+	if err != nil {
+		log.Printf("Error cardinality : %s", err)
 	}
-
-	return 0
+	return c
 }
 
-func (e *ElasticInstance) DeleteByQuery(index, query string) int {
+// DeleteByQueryContext returns the count of the deleted documents
+func (e *ElasticInstance) DeleteByQueryContext(ctx context.Context, index, query string) (int, error) {
+
+	var r map[string]any
 
-	res, err := esapi.DeleteByQueryRequest{
+	err := e.perform(ctx, esapi.DeleteByQueryRequest{
 		Index: []string{index},
-		Body:  strings.NewReader(query)}.Do(context.Background(), e.client)
+		Body:  strings.NewReader(query)}, index, &r)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return -1, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Printf("[%s] Error search documents", res.Status())
-		return -1
+	val, ok := r["deleted"].(float64)
+	if ok {
+		return int(val), nil
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return -1
-	}
-
-	if n > 0 {
+	return 0, nil
+}
 
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return 0
-		}
+func (e *ElasticInstance) DeleteByQuery(index, query string) int {
 
-		val, ok := r["deleted"]
-		if ok {
-			return int(val.(float64))
-		}
+	c, err := e.DeleteByQueryContext(context.Background(), index, query)
+	if err != nil {
+		log.Printf("Error delete by query : %s", err)
 	}
-
-	return 0
+	return c
 }
 
-func (e *ElasticInstance) Delete(index, id string) bool {
+func (e *ElasticInstance) DeleteContext(ctx context.Context, index, id string) error {
 
-	res, err := esapi.DeleteRequest{
+	return e.perform(ctx, esapi.DeleteRequest{
 		Index:        index,
 		DocumentType: index,
-		DocumentID:   id}.Do(context.Background(), e.client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
+		DocumentID:   id}, index, nil)
+}
 
-	if res.IsError() {
-		log.Printf("[%s] Error delete document", res.Status())
-		log.Println(res.String())
-		return false
-	}
+func (e *ElasticInstance) Delete(index, id string) bool {
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
+	if err := e.DeleteContext(context.Background(), index, id); err != nil {
+		log.Printf("Error delete document : %s", err)
 		return false
 	}
+	return true
+}
 
-	if n > 0 {
+// CountContext returns the count of the docs matching the query, all the docs when empty
+func (e *ElasticInstance) CountContext(ctx context.Context, index, query string) (int64, error) {
 
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return false
-		}
+	req := esapi.CountRequest{Index: []string{index}}
+	if len(strings.TrimSpace(query)) > 0 {
+		req.Body = strings.NewReader(query)
+	}
 
-		_, ok := r["deleted"]
-		if ok {
-			return true
-		}
+	var r struct {
+		Count int64 `json:"count"`
+	}
+	if err := e.perform(ctx, req, index, &r); err != nil {
+		return -1, err
 	}
 
-	return false
+	return r.Count, nil
 }
@@ -6,13 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
-	"io"
 	"log"
 	"sort"
 	"strings"
 )
 
-func (e *ElasticInstance) Indices(p string) []string {
+func (e *ElasticInstance) IndicesContext(ctx context.Context, p string) ([]string, error) {
 
 	indices := make([]string, 0)
 
@@ -22,20 +21,9 @@ func (e *ElasticInstance) Indices(p string) []string {
 		pattern = fmt.Sprintf("%s*", p)
 	}
 
-	res, err := esapi.CatIndicesRequest{Index: []string{pattern}, Format: "json"}.Do(context.Background(), e.client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("[%s] Error getting indices", res.Status())
-		return indices
-	}
-
 	var r []map[string]any
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Printf("Error parsing the response body: %s", err)
+	if err := e.perform(ctx, esapi.CatIndicesRequest{Index: []string{pattern}, Format: "json"}, pattern, &r); err != nil {
+		return indices, err
 	}
 
 	for _, info := range r {
@@ -49,34 +37,45 @@ func (e *ElasticInstance) Indices(p string) []string {
 	}
 
 	sort.Strings(indices)
-	return indices
+	return indices, nil
 }
 
-func (e *ElasticInstance) IndexExist(p string) bool {
+func (e *ElasticInstance) Indices(p string) []string {
 
-	res, err := esapi.IndicesExistsRequest{Index: []string{p}}.Do(context.Background(), e.client)
+	indices, err := e.IndicesContext(context.Background(), p)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		log.Printf("Error getting indices : %s", err)
 	}
-	defer res.Body.Close()
+	return indices
+}
 
-	if res.IsError() {
-		log.Printf("indices exists response status: %s\n", res.Status())
-		return false
+func (e *ElasticInstance) IndexExistContext(ctx context.Context, p string) (bool, error) {
+
+	err := e.perform(ctx, esapi.IndicesExistsRequest{Index: []string{p}}, p, nil)
+	if IsNotFound(err) {
+		return false, nil
 	}
 
-	return true
+	return err == nil, err
 }
 
-func (e *ElasticInstance) CreateIndex(p string, t map[string]any) bool {
+func (e *ElasticInstance) IndexExist(p string) bool {
 
-	body := make(map[string]any)
-	body["settings"] = map[string]any{
-		"number_of_shards":   3,
-		"number_of_replicas": 1,
+	ok, err := e.IndexExistContext(context.Background(), p)
+	if err != nil {
+		log.Printf("Error indices exists : %s", err)
 	}
+	return ok
+}
+
+// CreateIndexSettingsContext creates the index with the settings and the mapping properties,
+// nil settings leave them to the matching index templates
+func (e *ElasticInstance) CreateIndexSettingsContext(ctx context.Context, p string, settings, t map[string]any) error {
 
-	var includeTypeNamePtr *bool = nil
+	body := make(map[string]any)
+	if settings != nil {
+		body["settings"] = settings
+	}
 
 	if t != nil {
 		if e.IsMajorVersion(6) {
@@ -89,148 +88,216 @@ func (e *ElasticInstance) CreateIndex(p string, t map[string]any) bool {
 			body["mappings"] = map[string]any{
 				"properties": t,
 			}
-			includeTypeName := true
-			includeTypeNamePtr = &includeTypeName
 		}
 	}
 
 	buf, err := json.Marshal(body)
 	if err != nil {
-		log.Println(err)
-		return false
+		return err
 	}
 
-	res, err := esapi.IndicesCreateRequest{Index: p, Body: bytes.NewReader(buf), IncludeTypeName: includeTypeNamePtr}.Do(context.Background(), e.client)
-	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
+	return e.perform(ctx, esapi.IndicesCreateRequest{Index: p, Body: bytes.NewReader(buf)}, p, nil)
+}
+
+// CreateIndexContext creates the index with the mapping properties, the settings
+// (shards, replicas, ...) come from the matching index templates
+func (e *ElasticInstance) CreateIndexContext(ctx context.Context, p string, t map[string]any) error {
+
+	return e.CreateIndexSettingsContext(ctx, p, nil, t)
+}
+
+func (e *ElasticInstance) CreateIndex(p string, t map[string]any) bool {
 
-	if res.IsError() {
-		log.Printf("[%s] Error create index", res.Status())
-		fmt.Println(res.String())
+	if err := e.CreateIndexContext(context.Background(), p, t); err != nil {
+		log.Printf("Error create index : %s", err)
 		return false
 	}
+	return true
+}
 
-	var b bytes.Buffer
-	n, err := io.Copy(&b, res.Body)
-	if err != nil {
-		log.Println(err)
-	}
+func (e *ElasticInstance) DeleteIndexContext(ctx context.Context, p string) error {
 
-	if n > 0 {
-		var r map[string]any
-		if err := json.NewDecoder(&b).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-		}
+	if strings.Contains(p, "*") {
+		return fmt.Errorf("'*' character not allowed in deleting index")
 	}
 
-	return true
+	return e.perform(ctx, esapi.IndicesDeleteRequest{Index: []string{p}}, p, nil)
 }
 
 func (e *ElasticInstance) DeleteIndex(p string) bool {
 
-	if strings.Contains(p, "*") {
-		log.Println("'*' character not allowed in deleting index")
+	if err := e.DeleteIndexContext(context.Background(), p); err != nil {
+		log.Printf("Error delete index : %s", err)
 		return false
 	}
+	return true
+}
+
+// MappingContext returns the properties of the index mapping
+func (e *ElasticInstance) MappingContext(ctx context.Context, p string) (map[string]any, error) {
+
+	return e.PropertiesContext(ctx, p)
+}
+
+func (e *ElasticInstance) Mapping(p string) map[string]any {
+
+	properties, err := e.MappingContext(context.Background(), p)
+	if err != nil {
+		log.Printf("Error get mapping : %s", err)
+	}
+	return properties
+}
+
+// TemplateContext returns the properties of the index template mapping
+func (e *ElasticInstance) TemplateContext(ctx context.Context, p string) (map[string]any, error) {
+
+	var r map[string]any
+	if err := e.perform(ctx, esapi.IndicesGetTemplateRequest{Name: []string{p}}, p, &r); err != nil {
+		return nil, err
+	}
 
-	res, err := esapi.IndicesDeleteRequest{Index: []string{p}}.Do(context.Background(), e.client)
+	if r == nil {
+		return nil, nil
+	}
+
+	ks := []string{p, "mappings", "properties"}
+	if e.IsMajorVersion(6) {
+		ks = []string{p, "mappings", p, "properties"}
+	}
+
+	properties, err := NestedMapLookup(r, ks...)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		return nil, fmt.Errorf("error getting nested map lookup: %w", err)
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		log.Printf("[%s] Error delete index", res.Status())
-		return false
+	m, ok := properties.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("[%s] invalid template properties : %v", p, properties)
 	}
 
-	return true
+	return m, nil
 }
 
-func (e *ElasticInstance) Mapping(p string) map[string]any {
+func (e *ElasticInstance) Template(p string) map[string]any {
 
-	res, err := esapi.IndicesGetMappingRequest{Index: []string{p}}.Do(context.Background(), e.client)
+	properties, err := e.TemplateContext(context.Background(), p)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		log.Printf("Error get indices template : %s", err)
 	}
-	defer res.Body.Close()
+	return properties
+}
 
-	log.Printf("indices exists response status: %s\n", res.Status())
-	if res.IsError() {
-		log.Printf("[%s] Error get mapping", res.Status())
-		return nil
+// AliasesContext returns the aliases of the indices matching p by index
+func (e *ElasticInstance) AliasesContext(ctx context.Context, p string) (map[string][]string, error) {
+
+	pattern := "*"
+	if len(strings.Trim(p, " ")) > 0 {
+		pattern = fmt.Sprintf("%s*", p)
 	}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return nil
+	var r map[string]struct {
+		Aliases map[string]any `json:"aliases"`
 	}
 
-	if n > 0 {
+	err := e.perform(ctx, esapi.IndicesGetAliasRequest{Index: []string{pattern}}, pattern, &r)
 
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s\n", err)
-		}
+	// 404 : no index matches the pattern
+	if IsNotFound(err) {
+		return map[string][]string{}, nil
+	}
 
-		properties, err := NestedMapLookup(r, p, "mappings", "_doc", "properties")
-		if err != nil {
-			log.Printf("Error looking up nested map : %s\n", err)
-			return nil
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		return properties.(map[string]any)
+	aliases := make(map[string][]string)
+	for index, v := range r {
+		for alias := range v.Aliases {
+			aliases[index] = append(aliases[index], alias)
+		}
+		sort.Strings(aliases[index])
 	}
 
-	return nil
+	return aliases, nil
 }
 
-func (e *ElasticInstance) Template(p string) map[string]any {
+// Aliases returns the aliases of the indices matching p by index,
+// nil when the aliases can not be read
+func (e *ElasticInstance) Aliases(p string) map[string][]string {
 
-	res, err := esapi.IndicesGetTemplateRequest{Name: []string{p}}.Do(context.Background(), e.client)
+	aliases, err := e.AliasesContext(context.Background(), p)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
+		log.Printf("Error get aliases : %s", err)
 	}
-	defer res.Body.Close()
+	return aliases
+}
 
-	if res.IsError() {
-		log.Printf("[%s] Error get indices template", res.Status())
-		return nil
-	}
+// internalSettings are the index settings set by the server, not copied to a new index
+var internalSettings = []string{"uuid", "creation_date", "version", "provided_name", "resize", "routing", "blocks"}
 
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, res.Body)
-	if err != nil {
-		log.Println(err)
-		return nil
+// SettingsContext returns the index level settings of the index (number_of_shards,
+// analysis ...), without the ones set by the server
+func (e *ElasticInstance) SettingsContext(ctx context.Context, p string) (map[string]any, error) {
+
+	var r map[string]struct {
+		Settings struct {
+			Index map[string]any `json:"index"`
+		} `json:"settings"`
+	}
+	if err := e.perform(ctx, esapi.IndicesGetSettingsRequest{Index: []string{p}}, p, &r); err != nil {
+		return nil, err
 	}
 
-	if n > 0 {
+	for _, v := range r {
 
-		var r map[string]any
-		if err := json.NewDecoder(&buf).Decode(&r); err != nil {
-			log.Printf("Error parsing the response body: %s", err)
-			return nil
+		settings := v.Settings.Index
+		if settings == nil {
+			settings = map[string]any{}
 		}
 
-		ks := []string{p, "mappings", "properties"}
-		if e.IsMajorVersion(6) {
-			ks = []string{p, "mappings", p, "properties"}
+		for _, k := range internalSettings {
+			delete(settings, k)
 		}
+		return settings, nil
+	}
+
+	return nil, &Error{Status: 404, Type: "index_not_found_exception", Reason: "no settings", Index: p}
+}
 
-		properties, err := NestedMapLookup(r, ks...)
-		if err != nil {
-			log.Printf("error getting nested map lookup: %s\n", err)
-			return nil
+// RefreshContext makes the recent writes of the index searchable
+func (e *ElasticInstance) RefreshContext(ctx context.Context, p string) error {
+
+	return e.perform(ctx, esapi.IndicesRefreshRequest{Index: []string{p}}, p, nil)
+}
+
+// PropertiesContext returns the mapping properties of the index, with or without
+// a type level (6.x)
+func (e *ElasticInstance) PropertiesContext(ctx context.Context, p string) (map[string]any, error) {
+
+	var r map[string]struct {
+		Mappings map[string]any `json:"mappings"`
+	}
+	if err := e.perform(ctx, esapi.IndicesGetMappingRequest{Index: []string{p}}, p, &r); err != nil {
+		return nil, err
+	}
+
+	// an alias returns the mapping of the index behind it
+	for _, v := range r {
+
+		if properties, ok := v.Mappings["properties"].(map[string]any); ok {
+			return properties, nil
+		}
+
+		for _, t := range v.Mappings {
+			if m, ok := t.(map[string]any); ok {
+				if properties, ok := m["properties"].(map[string]any); ok {
+					return properties, nil
+				}
+			}
 		}
 
-		//fmt.Println(reflect.TypeOf(properties))
-		return properties.(map[string]any)
+		return map[string]any{}, nil
 	}
 
-	return nil
+	return nil, &Error{Status: 404, Type: "index_not_found_exception", Reason: "no mapping", Index: p}
 }
@@ -0,0 +1,279 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	BulkIndex  = "index"
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+type BulkOptions struct {
+	// Action is index by default
+	Action string
+
+	// IDFunc returns the id of the doc, IDField is the source field of the id
+	// when IDFunc is nil. Without both, Meta.Id then Id of the doc are used.
+	IDFunc  func(d *Doc) string
+	IDField string
+
+	// DocAsUpsert creates the missing docs of an update
+	DocAsUpsert     bool
+	RetryOnConflict int
+
+	Routing    string
+	Pipeline   string
+	FlushBytes int
+	Workers    int
+
+	// Refresh is true, false or wait_for
+	Refresh string
+
+	// DeadLetter is the JSONL file the failed items are appended to
+	DeadLetter string
+}
+
+type BulkFailure struct {
+	Index  string          `json:"index"`
+	Id     string          `json:"id"`
+	Action string          `json:"action"`
+	Status int             `json:"status"`
+	Type   string          `json:"type,omitempty"`
+	Reason string          `json:"reason"`
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+type BulkReport struct {
+	Added     uint64
+	Succeeded uint64
+	Failed    uint64
+	Duration  time.Duration
+	Failures  []BulkFailure
+}
+
+func (r *BulkReport) String() string {
+
+	rate := int64(0)
+	if ms := r.Duration.Milliseconds(); ms > 0 {
+		rate = int64(float64(r.Succeeded) * 1000 / float64(ms))
+	}
+
+	return fmt.Sprintf("indexed [%s] documents with [%s] errors in %s (%s docs/sec)",
+		humanize.Comma(int64(r.Succeeded)),
+		humanize.Comma(int64(r.Failed)),
+		r.Duration.Truncate(time.Millisecond),
+		humanize.Comma(rate))
+}
+
+// Err returns the first failure as *Error, nil when every item succeeded
+func (r *BulkReport) Err() error {
+
+	if len(r.Failures) == 0 {
+		return nil
+	}
+
+	f := r.Failures[0]
+	return &Error{Status: f.Status, Type: f.Type, Reason: f.Reason, Index: f.Index}
+}
+
+func (r *BulkReport) writeDeadLetter(f string) error {
+
+	file, err := os.OpenFile(f, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	for _, failure := range r.Failures {
+		if err := enc.Encode(failure); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (o BulkOptions) id(d *Doc) string {
+
+	switch {
+	case o.IDFunc != nil:
+		return o.IDFunc(d)
+	case len(o.IDField) > 0:
+		return d.Source.ID(o.IDField)
+	case len(d.Meta.Id) > 0:
+		return d.Meta.Id
+	}
+	return d.Id
+}
+
+func (o BulkOptions) body(d *Doc) ([]byte, error) {
+
+	switch o.Action {
+	case BulkDelete:
+		return nil, nil
+	case BulkUpdate:
+		return json.Marshal(map[string]any{"doc": d.Source.Map(), "doc_as_upsert": o.DocAsUpsert})
+	}
+	return json.Marshal(d.Source.Map())
+}
+
+// BulkInsertContext writes the docs with the bulk indexer, the failed items are
+// in the report. The error is for the indexer, not for the items.
+func (e *ElasticInstance) BulkInsertContext(ctx context.Context, index string, docs []*Doc, opts BulkOptions) (*BulkReport, error) {
+
+	if len(opts.Action) == 0 {
+		opts.Action = BulkIndex
+	}
+
+	switch opts.Action {
+	case BulkIndex, BulkCreate, BulkUpdate, BulkDelete:
+	default:
+		return nil, fmt.Errorf("not allowed bulk action %s", opts.Action)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:      index,
+		Client:     e.client,
+		NumWorkers: workers,
+		FlushBytes: opts.FlushBytes,
+		Pipeline:   opts.Pipeline,
+		Routing:    opts.Routing,
+		Refresh:    opts.Refresh,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new bulk indexer error : %w", err)
+	}
+
+	report := &BulkReport{}
+	start := time.Now()
+
+	var mu sync.Mutex
+
+	fail := func(f BulkFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Failures = append(report.Failures, f)
+	}
+
+	for _, doc := range docs {
+
+		item := esutil.BulkIndexerItem{
+			Index:      index,
+			Action:     opts.Action,
+			DocumentID: opts.id(doc),
+			Routing:    opts.Routing,
+		}
+
+		if len(doc.Meta.Index) > 0 {
+			item.Index = doc.Meta.Index
+		} else if len(item.Index) == 0 {
+			item.Index = doc.Index
+		}
+
+		if opts.Action == BulkUpdate {
+			roc := opts.RetryOnConflict
+			if doc.Meta.ROC > 0 {
+				roc = doc.Meta.ROC
+			}
+			item.RetryOnConflict = &roc
+		}
+
+		if (opts.Action == BulkUpdate || opts.Action == BulkDelete) && len(item.DocumentID) == 0 {
+			fail(BulkFailure{Index: item.Index, Action: opts.Action, Reason: "no document id"})
+			continue
+		}
+
+		data, err := opts.body(doc)
+		if err != nil {
+			fail(BulkFailure{Index: item.Index, Id: item.DocumentID, Action: opts.Action, Reason: fmt.Sprintf("cannot encode item : %s", err)})
+			continue
+		}
+
+		if data != nil {
+			item.Body = bytes.NewReader(data)
+		}
+
+		item.OnFailure = func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+
+			f := BulkFailure{Index: item.Index, Id: item.DocumentID, Action: item.Action, Status: res.Status, Source: data}
+			if err != nil {
+				f.Reason = err.Error()
+			} else {
+				f.Type, f.Reason = res.Error.Type, res.Error.Reason
+			}
+			fail(f)
+		}
+
+		if err := indexer.Add(ctx, item); err != nil {
+			indexer.Close(ctx)
+			return report, err
+		}
+	}
+
+	if err := indexer.Close(ctx); err != nil {
+		return report, err
+	}
+
+	stats := indexer.Stats()
+
+	report.Added = stats.NumAdded
+	report.Succeeded = stats.NumIndexed + stats.NumCreated + stats.NumUpdated + stats.NumDeleted
+	report.Failed = uint64(len(report.Failures))
+	report.Duration = time.Since(start)
+
+	if len(opts.DeadLetter) > 0 && len(report.Failures) > 0 {
+		if err := report.writeDeadLetter(opts.DeadLetter); err != nil {
+			return report, fmt.Errorf("error writing the dead letter file : %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (e *ElasticInstance) BulkInsert(index string, docs []*Doc) bool {
+
+	report, err := e.BulkInsertContext(context.Background(), index, docs, BulkOptions{Action: BulkCreate})
+	if err != nil {
+		log.Printf("Error bulk insert : %s", err)
+		return false
+	}
+
+	log.Println(report)
+
+	for _, f := range report.Failures {
+		log.Printf("[%s] %s %s : [%d] %s %s", f.Index, f.Action, f.Id, f.Status, f.Type, f.Reason)
+	}
+
+	return report.Failed == 0
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultBulkFlushBytes = 5 * 1024 * 1024
+	DefaultBulkFlushCount = 1000
+	DefaultBulkRetries    = 3
+	DefaultBulkBackoff    = 500 * time.Millisecond
+)
+
+// BulkWriter streams NDJSON bulk actions (an action line, then a source line but
+// for delete) and sends them by FlushBytes or FlushCount. The items rejected with
+// 429 are sent again with backoff, the other failed items are in the report.
+type BulkWriter struct {
+	e   ElasticInstance
+	ctx context.Context
+
+	flushBytes int
+	flushCount int
+	refresh    string
+	retries    int
+	backoff    time.Duration
+
+	buf     bytes.Buffer
+	items   []int // end offset of every complete item in buf
+	partial []byte
+	source  bool // the next line is the source of the last action
+
+	report BulkReport
+}
+
+// NewBulkWriter creates a writer without refresh
+func NewBulkWriter(ctx context.Context, e ElasticInstance) *BulkWriter {
+
+	return &BulkWriter{
+		e:          e,
+		ctx:        ctx,
+		flushBytes: DefaultBulkFlushBytes,
+		flushCount: DefaultBulkFlushCount,
+		retries:    DefaultBulkRetries,
+		backoff:    DefaultBulkBackoff,
+	}
+}
+
+func (w *BulkWriter) FlushBytes(n int) {
+	w.flushBytes = n
+}
+
+func (w *BulkWriter) FlushCount(n int) {
+	w.flushCount = n
+}
+
+// Refresh sets the refresh policy of the requests, true, false or wait_for
+func (w *BulkWriter) Refresh(policy string) {
+	w.refresh = policy
+}
+
+// Retry sets the retries of the 429 rejections, the backoff doubles every retry
+func (w *BulkWriter) Retry(n int, backoff time.Duration) {
+	w.retries, w.backoff = n, backoff
+}
+
+// Report returns the counts and the failed items sent so far
+func (w *BulkWriter) Report() *BulkReport {
+	return &w.report
+}
+
+// Write adds the NDJSON lines of p, a line may be split across calls
+func (w *BulkWriter) Write(p []byte) (int, error) {
+
+	w.partial = append(w.partial, p...)
+
+	for {
+
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := w.partial[:i+1]
+		w.partial = w.partial[i+1:]
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		// a rejected action line is dropped, it never starts an item
+		if w.source {
+			w.source = false
+			w.buf.Write(line)
+		} else {
+
+			var action map[string]json.RawMessage
+			if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+				return len(p), fmt.Errorf("invalid bulk action line : %s", bytes.TrimSpace(line))
+			}
+
+			w.buf.Write(line)
+
+			if _, ok := action[BulkDelete]; !ok {
+				w.source = true
+				continue
+			}
+		}
+
+		w.items = append(w.items, w.buf.Len())
+
+		// flush on complete items only, p may hold many requests worth of items
+		if w.buf.Len() >= w.flushBytes || len(w.items) >= w.flushCount {
+			if err := w.Flush(); err != nil {
+				return len(p), err
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Add writes the action of the doc with meta ({"_index", "_id", ...}) and its source
+func (w *BulkWriter) Add(action string, meta any, source any) error {
+
+	var b bytes.Buffer
+
+	h, err := json.Marshal(map[string]any{action: meta})
+	if err != nil {
+		return err
+	}
+	b.Write(h)
+	b.WriteByte('\n')
+
+	if action != BulkDelete {
+		d, err := json.Marshal(source)
+		if err != nil {
+			return err
+		}
+		b.Write(d)
+		b.WriteByte('\n')
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// failureOf reads the action, index and id of the action line of the item
+func failureOf(chunk []byte, status int, reason string) BulkFailure {
+
+	f := BulkFailure{Status: status, Reason: reason}
+
+	line := chunk
+	if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+		line = chunk[:i]
+	}
+
+	var action map[string]Header
+	if err := json.Unmarshal(line, &action); err == nil {
+		for a, h := range action {
+			f.Action, f.Index, f.Id = a, h.Index, h.Id
+		}
+	}
+
+	return f
+}
+
+// chunks returns the lines of every item in buf
+func (w *BulkWriter) chunks() [][]byte {
+
+	data := w.buf.Bytes()
+
+	chunks := make([][]byte, 0, len(w.items))
+	start := 0
+	for _, end := range w.items {
+		chunks = append(chunks, data[start:end])
+		start = end
+	}
+	return chunks
+}
+
+func (w *BulkWriter) send(chunks [][]byte) (rejected [][]byte, err error) {
+
+	body := bytes.Join(chunks, nil)
+
+	var r esutil.BulkIndexerResponse
+
+	err = w.e.perform(w.ctx, esapi.BulkRequest{Body: bytes.NewReader(body), Refresh: w.refresh}, "", &r)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) && e.Status == http.StatusTooManyRequests {
+			return chunks, nil
+		}
+		return nil, err
+	}
+
+	for i, item := range r.Items {
+		for action, res := range item {
+
+			switch {
+			case res.Status == http.StatusTooManyRequests && i < len(chunks):
+				rejected = append(rejected, chunks[i])
+			case res.Status > 299:
+				w.report.Failures = append(w.report.Failures, BulkFailure{
+					Index:  res.Index,
+					Id:     res.DocumentID,
+					Action: action,
+					Status: res.Status,
+					Type:   res.Error.Type,
+					Reason: res.Error.Reason,
+				})
+				w.report.Failed++
+			default:
+				w.report.Succeeded++
+			}
+		}
+	}
+
+	return rejected, nil
+}
+
+// Flush sends the complete items, a split item is kept for the next write
+func (w *BulkWriter) Flush() error {
+
+	if len(w.items) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+
+	chunks := w.chunks()
+	w.report.Added += uint64(len(chunks))
+
+	backoff := w.backoff
+
+	for attempt := 0; ; attempt++ {
+
+		rejected, err := w.send(chunks)
+		if err != nil {
+			w.reset()
+			return err
+		}
+
+		if len(rejected) == 0 {
+			break
+		}
+
+		if attempt >= w.retries {
+			for _, chunk := range rejected {
+				w.report.Failures = append(w.report.Failures,
+					failureOf(chunk, http.StatusTooManyRequests, fmt.Sprintf("rejected after %d retries", w.retries)))
+				w.report.Failed++
+			}
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			w.reset()
+			return w.ctx.Err()
+		}
+
+		backoff *= 2
+		chunks = rejected
+	}
+
+	w.reset()
+	w.report.Duration += time.Since(start)
+	return nil
+}
+
+func (w *BulkWriter) reset() {
+
+	// an action waiting for its source line stays
+	end := 0
+	if len(w.items) > 0 {
+		end = w.items[len(w.items)-1]
+	}
+
+	rest := append([]byte(nil), w.buf.Bytes()[end:]...)
+	w.buf.Reset()
+	w.buf.Write(rest)
+	w.items = w.items[:0]
+}
+
+// Close sends the remaining items, the report error is not returned
+func (w *BulkWriter) Close() error {
+
+	if w.source || len(bytes.TrimSpace(w.partial)) > 0 {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return fmt.Errorf("incomplete bulk item left")
+	}
+
+	return w.Flush()
+}
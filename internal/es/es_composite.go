@@ -0,0 +1,163 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"strings"
+)
+
+// NewCompositeAgg pages all the buckets of its sources, size buckets a page
+func NewCompositeAgg(size int) *Agg {
+	return newAgg("composite", map[string]any{"size": size, "sources": []any{}})
+}
+
+func (a *Agg) compositeSource(name, kind string, params map[string]any) *Agg {
+
+	sources, _ := a.params["sources"].([]any)
+	a.params["sources"] = append(sources, map[string]any{name: map[string]any{kind: params}})
+	return a
+}
+
+// TermsSource adds a terms source to the composite aggregation, the sources
+// are the key of a bucket in the order they are added
+func (a *Agg) TermsSource(name, field string) *Agg {
+	return a.compositeSource(name, "terms", map[string]any{"field": field})
+}
+
+func (a *Agg) HistogramSource(name, field string, interval float64) *Agg {
+	return a.compositeSource(name, "histogram", map[string]any{"field": field, "interval": interval})
+}
+
+// DateHistogramSource adds a source of the interval of the key, see DateIntervalKey
+func (a *Agg) DateHistogramSource(name, field, key, interval string) *Agg {
+	return a.compositeSource(name, "date_histogram", map[string]any{"field": field, key: interval})
+}
+
+// CompositeIterator walks the buckets of a composite aggregation, a page is
+// searched when the buckets of the previous one are consumed
+type CompositeIterator struct {
+	e    ElasticInstance
+	req  *Request
+	name string
+	agg  *Agg
+
+	after   map[string]any // the key the next page is searched after
+	last    map[string]any // the key of the last bucket returned
+	buckets []*AggResult
+	done    bool
+}
+
+// NewCompositeIterator creates an iterator of the composite aggregation named
+// name, run on the docs of req.Query (a search body without aggregations)
+func NewCompositeIterator(e ElasticInstance, req *Request, name string, agg *Agg) *CompositeIterator {
+	return &CompositeIterator{e: e, req: req, name: name, agg: agg}
+}
+
+// From resumes the iteration after the key, a saved AfterKey
+func (it *CompositeIterator) From(after map[string]any) {
+	it.after = after
+	it.last = after
+	it.buckets = nil
+	it.done = false
+}
+
+// AfterKey returns the key of the last bucket returned by Next, From resumes
+// with the bucket after it even in the middle of a page
+func (it *CompositeIterator) AfterKey() map[string]any {
+	return it.last
+}
+
+func (it *CompositeIterator) body() ([]byte, error) {
+
+	q := make(map[string]any)
+	if len(strings.TrimSpace(it.req.Query)) > 0 {
+		if err := json.Unmarshal([]byte(it.req.Query), &q); err != nil {
+			return nil, fmt.Errorf("invalid query : %w", err)
+		}
+	}
+
+	// the agg of the caller is left as it is
+	params := make(map[string]any, len(it.agg.params)+1)
+	for k, v := range it.agg.params {
+		params[k] = v
+	}
+	if it.after != nil {
+		params["after"] = it.after
+	}
+
+	source := it.agg.Source()
+	source["composite"] = params
+
+	q["size"] = 0
+	q["aggs"] = map[string]any{it.name: source}
+
+	return json.Marshal(q)
+}
+
+func (it *CompositeIterator) page(ctx context.Context) error {
+
+	body, err := it.body()
+	if err != nil {
+		return err
+	}
+
+	var r map[string]any
+
+	err = it.e.perform(ctx, esapi.SearchRequest{
+		Index: []string{it.req.Index},
+		Body:  bytes.NewReader(body)}, it.req.Index, &r)
+	if err != nil {
+		return err
+	}
+
+	agg := it.e.AggregationResult(r).Agg(it.name)
+	if agg == nil {
+		return fmt.Errorf("[%s] no %s aggregation", it.req.Index, it.name)
+	}
+
+	it.buckets = agg.Buckets()
+
+	after, _ := agg.raw["after_key"].(map[string]any)
+	if after == nil && len(it.buckets) > 0 {
+		// after_key is 6.3+, the key of the last bucket before
+		after, _ = it.buckets[len(it.buckets)-1].Key().(map[string]any)
+	}
+
+	// a page without after_key is the last one
+	if len(it.buckets) == 0 || after == nil {
+		it.done = true
+	}
+	it.after = after
+
+	return nil
+}
+
+// Next returns the next bucket, nil when there is no more buckets
+func (it *CompositeIterator) Next(ctx context.Context) (*AggResult, error) {
+
+	for len(it.buckets) == 0 {
+
+		if it.done {
+			return nil, nil
+		}
+
+		if err := it.page(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	b := it.buckets[0]
+	it.buckets = it.buckets[1:]
+
+	if key, ok := b.Key().(map[string]any); ok {
+		it.last = key
+	}
+	return b, nil
+}
@@ -14,8 +14,9 @@ import (
 )
 
 type Doc struct {
-	Index string
-	Id    string
+	Index   string
+	Id      string
+	Routing string
 
 	Meta   Header
 	Source util.Interface
@@ -160,7 +161,7 @@ func (d *Doc) AddCombinedHashcode(key string, ks []string) {
 func (d *Doc) AddNgram(k string) {
 
 	nk := fmt.Sprintf("%s_ngram", k)
-	d.SetValue(nk, seed.Ngram(d.String(k)))
+	d.SetValue(nk, hash.Ngram(d.String(k)))
 }
 
 func (d *Doc) AddCombinedNgram(key string, ks []string) {
@@ -174,7 +175,7 @@ func (d *Doc) AddCombinedNgram(key string, ks []string) {
 		ss = append(ss, d.String(k))
 	}
 
-	d.SetValue(key, seed.Ngram(strings.Join(ss, " ")))
+	d.SetValue(key, hash.Ngram(strings.Join(ss, " ")))
 }
 
 func (d *Doc) RemoveByKeys(ks []string) {
@@ -186,7 +187,7 @@ func (d *Doc) RemoveByKeys(ks []string) {
 
 func (d *Doc) DeepCopy() *Doc {
 
-	return &Doc{Index: d.Index, Id: d.Id, Meta: d.Meta, Source: d.Source.DeepCopy()}
+	return &Doc{Index: d.Index, Id: d.Id, Routing: d.Routing, Meta: d.Meta, Source: d.Source.DeepCopy()}
 }
 
 func (docs Documents) LastId(k string) int64 {
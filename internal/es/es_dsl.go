@@ -0,0 +1,469 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Query is a clause of the query DSL
+type Query interface {
+	Source() map[string]any
+}
+
+func querySources(qs []Query) []any {
+
+	sources := make([]any, 0, len(qs))
+	for _, q := range qs {
+		if q != nil {
+			sources = append(sources, q.Source())
+		}
+	}
+	return sources
+}
+
+type TermQuery struct {
+	field string
+	value any
+	boost float64
+}
+
+func NewTermQuery(field string, value any) *TermQuery {
+	return &TermQuery{field: field, value: value}
+}
+
+func (q *TermQuery) Boost(b float64) *TermQuery {
+	q.boost = b
+	return q
+}
+
+func (q *TermQuery) Source() map[string]any {
+
+	if q.boost == 0 {
+		return map[string]any{"term": map[string]any{q.field: q.value}}
+	}
+	return map[string]any{"term": map[string]any{q.field: map[string]any{"value": q.value, "boost": q.boost}}}
+}
+
+type TermsQuery struct {
+	field  string
+	values []any
+}
+
+func NewTermsQuery(field string, values ...any) *TermsQuery {
+	return &TermsQuery{field: field, values: values}
+}
+
+func (q *TermsQuery) Source() map[string]any {
+	return map[string]any{"terms": map[string]any{q.field: q.values}}
+}
+
+// RangeQuery is a numeric or a date range, a bound left nil is open
+type RangeQuery struct {
+	field            string
+	gt, gte, lt, lte any
+	format, timeZone string
+}
+
+func NewRangeQuery(field string) *RangeQuery {
+	return &RangeQuery{field: field}
+}
+
+func (q *RangeQuery) Gt(v any) *RangeQuery {
+	q.gt = v
+	return q
+}
+
+func (q *RangeQuery) Gte(v any) *RangeQuery {
+	q.gte = v
+	return q
+}
+
+func (q *RangeQuery) Lt(v any) *RangeQuery {
+	q.lt = v
+	return q
+}
+
+func (q *RangeQuery) Lte(v any) *RangeQuery {
+	q.lte = v
+	return q
+}
+
+// Format is the date format of the bounds, e.g. yyyy-MM-dd
+func (q *RangeQuery) Format(f string) *RangeQuery {
+	q.format = f
+	return q
+}
+
+func (q *RangeQuery) TimeZone(tz string) *RangeQuery {
+	q.timeZone = tz
+	return q
+}
+
+func (q *RangeQuery) Source() map[string]any {
+
+	r := map[string]any{}
+	for k, v := range map[string]any{"gt": q.gt, "gte": q.gte, "lt": q.lt, "lte": q.lte} {
+		if v != nil {
+			r[k] = v
+		}
+	}
+	if len(q.format) > 0 {
+		r["format"] = q.format
+	}
+	if len(q.timeZone) > 0 {
+		r["time_zone"] = q.timeZone
+	}
+	return map[string]any{"range": map[string]any{q.field: r}}
+}
+
+type MatchQuery struct {
+	field, text string
+	operator    string
+	fuzziness   string
+}
+
+func NewMatchQuery(field, text string) *MatchQuery {
+	return &MatchQuery{field: field, text: text}
+}
+
+// Operator is or (default) or and
+func (q *MatchQuery) Operator(op string) *MatchQuery {
+	q.operator = op
+	return q
+}
+
+func (q *MatchQuery) Fuzziness(f string) *MatchQuery {
+	q.fuzziness = f
+	return q
+}
+
+func (q *MatchQuery) Source() map[string]any {
+
+	m := map[string]any{"query": q.text}
+	if len(q.operator) > 0 {
+		m["operator"] = q.operator
+	}
+	if len(q.fuzziness) > 0 {
+		m["fuzziness"] = q.fuzziness
+	}
+	return map[string]any{"match": map[string]any{q.field: m}}
+}
+
+type MatchPhraseQuery struct {
+	field, text string
+	slop        int
+}
+
+func NewMatchPhraseQuery(field, text string) *MatchPhraseQuery {
+	return &MatchPhraseQuery{field: field, text: text}
+}
+
+func (q *MatchPhraseQuery) Slop(s int) *MatchPhraseQuery {
+	q.slop = s
+	return q
+}
+
+func (q *MatchPhraseQuery) Source() map[string]any {
+
+	m := map[string]any{"query": q.text}
+	if q.slop > 0 {
+		m["slop"] = q.slop
+	}
+	return map[string]any{"match_phrase": map[string]any{q.field: m}}
+}
+
+type ExistsQuery struct {
+	field string
+}
+
+func NewExistsQuery(field string) *ExistsQuery {
+	return &ExistsQuery{field: field}
+}
+
+func (q *ExistsQuery) Source() map[string]any {
+	return map[string]any{"exists": map[string]any{"field": q.field}}
+}
+
+type PrefixQuery struct {
+	field, prefix string
+}
+
+func NewPrefixQuery(field, prefix string) *PrefixQuery {
+	return &PrefixQuery{field: field, prefix: prefix}
+}
+
+func (q *PrefixQuery) Source() map[string]any {
+	return map[string]any{"prefix": map[string]any{q.field: map[string]any{"value": q.prefix}}}
+}
+
+type WildcardQuery struct {
+	field, pattern string
+}
+
+// NewWildcardQuery matches the pattern with * and ?, not escaped
+func NewWildcardQuery(field, pattern string) *WildcardQuery {
+	return &WildcardQuery{field: field, pattern: pattern}
+}
+
+func (q *WildcardQuery) Source() map[string]any {
+	return map[string]any{"wildcard": map[string]any{q.field: map[string]any{"value": q.pattern}}}
+}
+
+type NestedQuery struct {
+	path      string
+	query     Query
+	scoreMode string
+}
+
+func NewNestedQuery(path string, query Query) *NestedQuery {
+	return &NestedQuery{path: path, query: query}
+}
+
+// ScoreMode is avg (default), max, min, sum or none
+func (q *NestedQuery) ScoreMode(m string) *NestedQuery {
+	q.scoreMode = m
+	return q
+}
+
+func (q *NestedQuery) Source() map[string]any {
+
+	m := map[string]any{"path": q.path, "query": q.query.Source()}
+	if len(q.scoreMode) > 0 {
+		m["score_mode"] = q.scoreMode
+	}
+	return map[string]any{"nested": m}
+}
+
+type BoolQuery struct {
+	must, should, filter, mustNot []Query
+	minimumShouldMatch            string
+}
+
+func NewBoolQuery() *BoolQuery {
+	return &BoolQuery{}
+}
+
+func (q *BoolQuery) Must(qs ...Query) *BoolQuery {
+	q.must = append(q.must, qs...)
+	return q
+}
+
+func (q *BoolQuery) Should(qs ...Query) *BoolQuery {
+	q.should = append(q.should, qs...)
+	return q
+}
+
+// Filter adds the clauses not scored
+func (q *BoolQuery) Filter(qs ...Query) *BoolQuery {
+	q.filter = append(q.filter, qs...)
+	return q
+}
+
+func (q *BoolQuery) MustNot(qs ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, qs...)
+	return q
+}
+
+func (q *BoolQuery) MinimumShouldMatch(m string) *BoolQuery {
+	q.minimumShouldMatch = m
+	return q
+}
+
+func (q *BoolQuery) Source() map[string]any {
+
+	m := map[string]any{}
+	for k, qs := range map[string][]Query{"must": q.must, "should": q.should, "filter": q.filter, "must_not": q.mustNot} {
+		if len(qs) > 0 {
+			m[k] = querySources(qs)
+		}
+	}
+	if len(q.minimumShouldMatch) > 0 {
+		m["minimum_should_match"] = q.minimumShouldMatch
+	}
+	return map[string]any{"bool": m}
+}
+
+type FunctionScoreQuery struct {
+	query     Query
+	functions []any
+	scoreMode string
+	boostMode string
+}
+
+func NewFunctionScoreQuery(query Query) *FunctionScoreQuery {
+	return &FunctionScoreQuery{query: query}
+}
+
+// Weight adds a weight function, applied to the docs matching filter (all when nil)
+func (q *FunctionScoreQuery) Weight(filter Query, weight float64) *FunctionScoreQuery {
+
+	f := map[string]any{"weight": weight}
+	if filter != nil {
+		f["filter"] = filter.Source()
+	}
+	q.functions = append(q.functions, f)
+	return q
+}
+
+// FieldValueFactor adds a field_value_factor function, modifier is e.g. log1p
+func (q *FunctionScoreQuery) FieldValueFactor(field string, factor float64, modifier string, missing float64) *FunctionScoreQuery {
+
+	fvf := map[string]any{"field": field, "factor": factor, "missing": missing}
+	if len(modifier) > 0 {
+		fvf["modifier"] = modifier
+	}
+	q.functions = append(q.functions, map[string]any{"field_value_factor": fvf})
+	return q
+}
+
+// ScoreMode combines the functions, multiply (default), sum, avg, first, max or min
+func (q *FunctionScoreQuery) ScoreMode(m string) *FunctionScoreQuery {
+	q.scoreMode = m
+	return q
+}
+
+// BoostMode combines the functions with the query score, multiply (default),
+// replace, sum, avg, max or min
+func (q *FunctionScoreQuery) BoostMode(m string) *FunctionScoreQuery {
+	q.boostMode = m
+	return q
+}
+
+func (q *FunctionScoreQuery) Source() map[string]any {
+
+	m := map[string]any{}
+	if q.query != nil {
+		m["query"] = q.query.Source()
+	}
+	if len(q.functions) > 0 {
+		m["functions"] = q.functions
+	}
+	if len(q.scoreMode) > 0 {
+		m["score_mode"] = q.scoreMode
+	}
+	if len(q.boostMode) > 0 {
+		m["boost_mode"] = q.boostMode
+	}
+	return map[string]any{"function_score": m}
+}
+
+// SearchSource is a search body, the typed counterpart of QueryStringQueryBuilder
+type SearchSource struct {
+	query     Query
+	sort      []map[string]any
+	includes  []string
+	excludes  []string
+	highlight map[string]any
+	aggs      map[string]any
+	size      int
+	from      int
+}
+
+func NewSearchSource(query Query) *SearchSource {
+	return &SearchSource{query: query, size: -1}
+}
+
+func (s *SearchSource) Query(q Query) *SearchSource {
+	s.query = q
+	return s
+}
+
+// Sort adds a sort on the field, order is asc or desc
+func (s *SearchSource) Sort(field, order string) *SearchSource {
+	s.sort = append(s.sort, map[string]any{field: map[string]any{"order": order}})
+	return s
+}
+
+// SourceFields filters the _source of the hits, the fields may have wildcards
+func (s *SearchSource) SourceFields(includes, excludes []string) *SearchSource {
+	s.includes = includes
+	s.excludes = excludes
+	return s
+}
+
+// Highlight adds the highlighted fields with the pre and post tags (the default <em> when empty)
+func (s *SearchSource) Highlight(preTag, postTag string, fields ...string) *SearchSource {
+
+	fs := map[string]any{}
+	for _, f := range fields {
+		fs[f] = map[string]any{}
+	}
+
+	s.highlight = map[string]any{"fields": fs}
+	if len(preTag) > 0 {
+		s.highlight["pre_tags"] = []string{preTag}
+	}
+	if len(postTag) > 0 {
+		s.highlight["post_tags"] = []string{postTag}
+	}
+	return s
+}
+
+func (s *SearchSource) Aggregation(aggs map[string]any) *SearchSource {
+	s.aggs = aggs
+	return s
+}
+
+func (s *SearchSource) Size(n int) *SearchSource {
+	s.size = n
+	return s
+}
+
+func (s *SearchSource) From(n int) *SearchSource {
+	s.from = n
+	return s
+}
+
+func (s *SearchSource) Source() map[string]any {
+
+	body := map[string]any{}
+
+	if s.query != nil {
+		body["query"] = s.query.Source()
+	}
+	if len(s.sort) > 0 {
+		body["sort"] = s.sort
+	}
+	if s.includes != nil || s.excludes != nil {
+		source := map[string]any{}
+		if len(s.includes) > 0 {
+			source["includes"] = s.includes
+		}
+		if len(s.excludes) > 0 {
+			source["excludes"] = s.excludes
+		}
+		body["_source"] = source
+	}
+	if s.highlight != nil {
+		body["highlight"] = s.highlight
+	}
+	if s.aggs != nil {
+		body["aggs"] = s.aggs
+	}
+	if s.size >= 0 {
+		body["size"] = s.size
+	}
+	if s.from > 0 {
+		body["from"] = s.from
+	}
+	return body
+}
+
+func (s *SearchSource) String() string {
+
+	b, err := json.Marshal(s.Source())
+	if err != nil {
+		log.Printf("Error encoding query: %s\n", err)
+		return ""
+	}
+	return string(b)
+}
+
+// Request returns the search request of the index, the size is kept in the body
+func (s *SearchSource) Request(index string) *Request {
+	return &Request{Index: index, Query: s.String()}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"io"
+	"net/http"
+)
+
+// Error is an error returned by the elasticsearch API,
+// transport and decoding errors are returned as they are
+type Error struct {
+	Status int
+	Type   string
+	Reason string
+	Index  string
+}
+
+func (e *Error) Error() string {
+
+	s := fmt.Sprintf("[%d]", e.Status)
+	if len(e.Index) > 0 {
+		s += fmt.Sprintf(" %s :", e.Index)
+	}
+	if len(e.Type) > 0 {
+		s += " " + e.Type
+	}
+	if len(e.Reason) > 0 {
+		s += " : " + e.Reason
+	}
+	return s
+}
+
+// IsNotFound tells if err is an elasticsearch 404 error
+func IsNotFound(err error) bool {
+
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// newError reads the error of the response body, the body may be empty (HEAD)
+// or the error a plain string (some 404)
+func newError(res *esapi.Response, index string) *Error {
+
+	e := &Error{Status: res.StatusCode, Index: index, Reason: http.StatusText(res.StatusCode)}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, res.Body); err != nil || buf.Len() == 0 {
+		return e
+	}
+
+	var r struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil || len(r.Error) == 0 {
+		e.Reason = buf.String()
+		return e
+	}
+
+	var cause struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+		Index  string `json:"index"`
+	}
+	if err := json.Unmarshal(r.Error, &cause); err != nil {
+
+		var reason string
+		if err := json.Unmarshal(r.Error, &reason); err == nil {
+			e.Reason = reason
+		}
+		return e
+	}
+
+	e.Type, e.Reason = cause.Type, cause.Reason
+	if len(cause.Index) > 0 {
+		e.Index = cause.Index
+	}
+
+	return e
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+)
+
+// updatableParams are the mapping parameters a put mapping changes in place,
+// a change of any other parameter needs a reindex
+var updatableParams = map[string]bool{
+	"ignore_above":          true,
+	"search_analyzer":       true,
+	"search_quote_analyzer": true,
+	"ignore_malformed":      true,
+	"copy_to":               true,
+	"meta":                  true,
+	"dynamic":               true,
+	"boost":                 true,
+}
+
+// FieldChange is a difference of a field, Path is dotted with the
+// multi-fields under their field (title.keyword)
+type FieldChange struct {
+	Path    string
+	Change  string
+	From    string
+	To      string
+	Reindex bool
+}
+
+func (c FieldChange) String() string {
+
+	s := fmt.Sprintf("%-8s %s", c.Change, c.Path)
+	switch c.Change {
+	case FieldAdded:
+		s += " (" + c.To + ")"
+	case FieldRemoved:
+		s += " (" + c.From + ")"
+	default:
+		s += fmt.Sprintf(" : %s -> %s", c.From, c.To)
+	}
+
+	if c.Reindex {
+		s += " [reindex]"
+	}
+	return s
+}
+
+func fieldType(f map[string]any) string {
+
+	if t, ok := f["type"].(string); ok {
+		return t
+	}
+	// an object field has properties and no type
+	return "object"
+}
+
+func paramString(v any) string {
+
+	if v == nil {
+		return "(none)"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func sortedKeys(ms ...map[string]any) []string {
+
+	set := make(map[string]bool)
+	for _, m := range ms {
+		for k := range m {
+			set[k] = true
+		}
+	}
+
+	ks := make([]string, 0, len(set))
+	for k := range set {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func asMap(v any) map[string]any {
+
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func diffFields(prefix string, from, to map[string]any, changes []FieldChange) []FieldChange {
+
+	for _, name := range sortedKeys(from, to) {
+
+		path := name
+		if len(prefix) > 0 {
+			path = prefix + "." + name
+		}
+
+		f, inFrom := from[name].(map[string]any)
+		t, inTo := to[name].(map[string]any)
+
+		switch {
+		case inFrom && !inTo:
+			// a field can not be removed from a mapping
+			changes = append(changes, FieldChange{Path: path, Change: FieldRemoved, From: fieldType(f), Reindex: true})
+			continue
+		case !inFrom && inTo:
+			changes = append(changes, FieldChange{Path: path, Change: FieldAdded, To: fieldType(t)})
+			continue
+		case !inFrom && !inTo:
+			continue
+		}
+
+		if fieldType(f) != fieldType(t) {
+			changes = append(changes, FieldChange{Path: path, Change: FieldChanged, From: fieldType(f), To: fieldType(t), Reindex: true})
+			continue
+		}
+
+		for _, param := range sortedKeys(f, t) {
+
+			if param == "type" || param == "properties" || param == "fields" {
+				continue
+			}
+
+			if reflect.DeepEqual(f[param], t[param]) {
+				continue
+			}
+
+			changes = append(changes, FieldChange{
+				Path:    path + " " + param,
+				Change:  FieldChanged,
+				From:    paramString(f[param]),
+				To:      paramString(t[param]),
+				Reindex: !updatableParams[param],
+			})
+		}
+
+		changes = diffFields(path, asMap(f["properties"]), asMap(t["properties"]), changes)
+		changes = diffFields(path, asMap(f["fields"]), asMap(t["fields"]), changes)
+	}
+
+	return changes
+}
+
+// DiffMappings returns the changes of the mapping properties from to to,
+// inside the object, nested and multi-fields
+func DiffMappings(from, to map[string]any) []FieldChange {
+
+	return diffFields("", from, to, make([]FieldChange, 0))
+}
+
+// NeedsReindex tells if a change can not be applied with a put mapping
+func NeedsReindex(changes []FieldChange) bool {
+
+	for _, c := range changes {
+		if c.Reindex {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"log"
+	"strings"
+	"time"
+)
+
+// shardDoc is the tiebreaker of a point in time, its values are valid in that
+// point in time only
+const shardDoc = "_shard_doc"
+
+// Cursor is the position of a paginated search, it can be saved to resume the search.
+// A cursor outliving its point in time is resumed in a new one, which needs a stable
+// tiebreaker (_id or a unique field, see Paginator.Tiebreaker), not _shard_doc.
+type Cursor struct {
+	PitID       string            `json:"pit_id,omitempty"`
+	SearchAfter []json.RawMessage `json:"search_after,omitempty"`
+	Tiebreaker  string            `json:"tiebreaker,omitempty"`
+}
+
+// Paginator pages a search with search_after, in a point in time when the server
+// supports it. The sort of the query gets a tiebreaker so that the pages do not
+// overlap.
+type Paginator struct {
+	e   ElasticInstance
+	req *Request
+
+	pit        bool
+	keepAlive  string
+	tiebreaker string
+	slice      map[string]any
+
+	// shared is a point in time opened by the caller for several paginators,
+	// it is not reopened when it expires
+	shared bool
+
+	cursor   Cursor
+	response *Response
+	done     bool
+}
+
+func keepAlive(d time.Duration) string {
+
+	if d <= 0 {
+		d = time.Minute
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// NewPaginator creates a paginator of the request, req.Scroll is the keep alive of the point in time
+func NewPaginator(e ElasticInstance, req *Request) *Paginator {
+
+	p := &Paginator{e: e, req: req, pit: e.SupportsPIT(), keepAlive: keepAlive(req.Scroll)}
+
+	// _shard_doc is the cheapest tiebreaker but exists only in a point in time (7.12+),
+	// a search resumed in another point in time needs Tiebreaker("_id")
+	p.tiebreaker = "_id"
+	if p.pit && e.IsVersionAtLeast(7, 12) {
+		p.tiebreaker = shardDoc
+	}
+
+	return p
+}
+
+// Tiebreaker replaces the default tiebreaker, a field with a unique value per doc.
+// A stable one (not _shard_doc) is needed to resume a saved Cursor.
+func (p *Paginator) Tiebreaker(field string) {
+	p.tiebreaker = field
+}
+
+// From resumes the search after the cursor, which must be sorted by the stable
+// tiebreaker of the paginator
+func (p *Paginator) From(c Cursor) error {
+
+	if len(c.SearchAfter) > 0 {
+
+		if c.Tiebreaker == shardDoc || p.tiebreaker == shardDoc {
+			return fmt.Errorf("[%s] a cursor sorted by %s can not be resumed, set a stable tiebreaker (_id or a unique field)", p.req.Index, shardDoc)
+		}
+
+		if c.Tiebreaker != p.tiebreaker {
+			return fmt.Errorf("[%s] the cursor is sorted by %q, not by the tiebreaker %q", p.req.Index, c.Tiebreaker, p.tiebreaker)
+		}
+	}
+
+	p.cursor = c
+	p.done = false
+	return nil
+}
+
+// Cursor returns the position after the last page
+func (p *Paginator) Cursor() Cursor {
+	return p.cursor
+}
+
+func hasSortField(sort []any, field string) bool {
+
+	for _, s := range sort {
+		switch v := s.(type) {
+		case string:
+			if v == field {
+				return true
+			}
+		case map[string]any:
+			if _, ok := v[field]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Paginator) body() ([]byte, error) {
+
+	q := make(map[string]any)
+	if len(strings.TrimSpace(p.req.Query)) > 0 {
+		if err := json.Unmarshal([]byte(p.req.Query), &q); err != nil {
+			return nil, fmt.Errorf("invalid query : %w", err)
+		}
+	}
+
+	var sort []any
+	switch v := q["sort"].(type) {
+	case nil:
+	case []any:
+		sort = v
+	default:
+		sort = []any{v}
+	}
+
+	if !hasSortField(sort, p.tiebreaker) {
+		sort = append(sort, map[string]any{p.tiebreaker: "asc"})
+	}
+	q["sort"] = sort
+
+	if p.pit {
+		q["pit"] = map[string]any{"id": p.cursor.PitID, "keep_alive": p.keepAlive}
+	}
+
+	if len(p.cursor.SearchAfter) > 0 {
+		q["search_after"] = p.cursor.SearchAfter
+	}
+
+	if p.slice != nil {
+		q["slice"] = p.slice
+	}
+
+	return json.Marshal(q)
+}
+
+func (p *Paginator) open(ctx context.Context) error {
+
+	var r struct {
+		Id string `json:"id"`
+	}
+
+	err := p.e.perform(ctx, esapi.OpenPointInTimeRequest{
+		Index:     []string{p.req.Index},
+		KeepAlive: p.keepAlive}, p.req.Index, &r)
+	if err != nil {
+		return err
+	}
+
+	p.cursor.PitID = r.Id
+	return nil
+}
+
+func (p *Paginator) search(ctx context.Context) (json.RawMessage, error) {
+
+	body, err := p.body()
+	if err != nil {
+		return nil, err
+	}
+
+	var sizePtr *int = nil
+	if p.req.Size > 0 {
+		sizePtr = &p.req.Size
+	}
+
+	req := esapi.SearchRequest{Size: sizePtr, Body: bytes.NewReader(body)}
+
+	// a point in time search has no index
+	if !p.pit {
+		req.Index = []string{p.req.Index}
+	}
+
+	var raw json.RawMessage
+	err = p.e.perform(ctx, req, p.req.Index, &raw)
+	return raw, err
+}
+
+// Next returns the next page, nil when there is no more docs
+func (p *Paginator) Next(ctx context.Context) (*Response, error) {
+
+	if p.done {
+		return nil, nil
+	}
+
+	if p.pit && len(p.cursor.PitID) == 0 {
+		if err := p.open(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := p.search(ctx)
+
+	// the point in time expired (or a resumed cursor is too old), the search
+	// goes on after the cursor in a new one. _shard_doc values are valid in
+	// their point in time only, they would skip or repeat docs in a new one.
+	if p.pit && IsNotFound(err) && len(p.cursor.SearchAfter) > 0 && p.tiebreaker == shardDoc {
+		return nil, fmt.Errorf("[%s] point in time expired, the search sorted by %s can not go on in a new one : %w", p.req.Index, shardDoc, err)
+	}
+
+	if p.pit && IsNotFound(err) && p.shared {
+		return nil, fmt.Errorf("[%s] shared point in time expired : %w", p.req.Index, err)
+	}
+
+	if p.pit && IsNotFound(err) {
+
+		log.Printf("[%s] point in time expired, reopened", p.req.Index)
+
+		if err := p.open(ctx); err != nil {
+			return nil, err
+		}
+		raw, err = p.search(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var r map[string]any
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("error parsing the response body: %w", err)
+	}
+
+	// the sort values are kept raw, a float64 loses the precision of the long ones
+	var page struct {
+		PitID string `json:"pit_id"`
+		Hits  struct {
+			Hits []struct {
+				Sort []json.RawMessage `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return nil, fmt.Errorf("error parsing the response body: %w", err)
+	}
+
+	if p.response == nil {
+		p.response = NewResponse()
+		p.response.Reserve(p.req.Size)
+	} else {
+		p.response.Result.Docs = Documents{}
+	}
+
+	p.response.Result.SetTotalCount(p.e.TotalCount(r))
+
+	n, err := p.e.addHits(p.response, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.PitID) > 0 {
+		p.cursor.PitID = page.PitID
+	}
+
+	if n == 0 {
+		p.done = true
+		return nil, nil
+	}
+
+	p.cursor.SearchAfter = page.Hits.Hits[n-1].Sort
+	p.cursor.Tiebreaker = p.tiebreaker
+
+	return p.response, nil
+}
+
+// Close releases the point in time, the cursor can not be resumed in it any more
+func (p *Paginator) Close(ctx context.Context) error {
+
+	if !p.pit || len(p.cursor.PitID) == 0 {
+		return nil
+	}
+
+	id := p.cursor.PitID
+	p.cursor.PitID = ""
+
+	return closePointInTime(ctx, p.e, p.req.Index, id)
+}
+
+// closePointInTime releases the point in time id, an expired one is not an error
+func closePointInTime(ctx context.Context, e ElasticInstance, index, id string) error {
+
+	body, err := json.Marshal(map[string]any{"id": id})
+	if err != nil {
+		return err
+	}
+
+	err = e.perform(ctx, esapi.ClosePointInTimeRequest{Body: bytes.NewReader(body)}, index, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Generator returns the pages like NewGenerator, the point in time is closed after the last one
+func (p *Paginator) Generator() func() *Response {
+
+	return func() *Response {
+
+		ctx := context.Background()
+
+		response, err := p.Next(ctx)
+		if err != nil {
+			log.Printf("Error search documents : %s", err)
+		}
+
+		if response == nil {
+			if err := p.Close(ctx); err != nil {
+				log.Printf("Error close point in time : %s", err)
+			}
+			return nil
+		}
+
+		return response
+	}
+}
@@ -10,13 +10,23 @@ import (
 	"fmt"
 	"github.com/alcomist/go-portfolio/internal/constant"
 	"log"
+	"strconv"
 	"strings"
 )
 
 var esReserved = []string{"\\", "+", "=", "&&", "||", "!", "(", ")", "{", "}", "[", "]", "^", "\"", "~", "*", "?", ":", "/"}
 
+// QueryRange is a [from TO to] range, a bound of -1 is open (*)
 type QueryRange [2]int64
 
+func rangeBound(v int64) string {
+
+	if v == -1 {
+		return "*"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
 type QueryItem struct {
 	negate bool
 	field  string
@@ -99,8 +109,7 @@ func quoteString(x any) string {
 		}
 		return fmt.Sprintf("(%s)", strings.Join(items, sep))
 	case QueryRange:
-		q := fmt.Sprintf("[%d TO %d]", x[0], x[1])
-		return strings.ReplaceAll(q, "-1", "*")
+		return fmt.Sprintf("[%s TO %s]", rangeBound(x[0]), rangeBound(x[1]))
 	default:
 		log.Panicf("unnexpected type %T: %v", x, x)
 		return ""
@@ -0,0 +1,294 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// GenerationLayout is the time suffix of the generations of an alias, <alias>_YYYYMMDD_HHMM
+const GenerationLayout = "20060102_1504"
+
+// Populate fills the new generation index before the alias is swapped
+type Populate func(ctx context.Context, index string) error
+
+type RebuildOptions struct {
+	// Template is put (unless the cluster has the same one) before the new
+	// generation is created, its index patterns should match <alias>_*
+	Template *IndexTemplate
+
+	// Properties is the mapping of the new generation, added to the mapping of
+	// the matching templates
+	Properties map[string]any
+
+	// MinDocs and MinRatio (of the docs behind the alias) are the thresholds
+	// of the new generation, the alias is not swapped below them
+	MinDocs  int64
+	MinRatio float64
+
+	// Keep is the count of the previous generations kept for rollback
+	Keep int
+
+	// ReplaceIndex deletes an index named as the alias in the swap,
+	// the first rebuild of an index rebuilt in place before
+	ReplaceIndex bool
+}
+
+// Generation returns the generation index of the alias created at t
+func Generation(alias string, t time.Time) string {
+	return alias + "_" + t.Format(GenerationLayout)
+}
+
+// AliasIndicesContext returns the indices behind the alias, empty when it does not exist
+func (e *ElasticInstance) AliasIndicesContext(ctx context.Context, alias string) ([]string, error) {
+
+	var r map[string]any
+
+	err := e.perform(ctx, esapi.IndicesGetAliasRequest{Name: []string{alias}}, alias, &r)
+	if IsNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(r))
+	for index := range r {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	return indices, nil
+}
+
+// GenerationsContext returns the generation indices of the alias, newest first
+func (e *ElasticInstance) GenerationsContext(ctx context.Context, alias string) ([]string, error) {
+
+	indices, err := e.IndicesContext(ctx, alias+"_")
+	if err != nil {
+		return nil, err
+	}
+
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(alias) + `_\d{8}_\d{4}$`)
+
+	generations := make([]string, 0)
+	for _, index := range indices {
+		if re.MatchString(index) {
+			generations = append(generations, index)
+		}
+	}
+
+	// the suffix sorts by time
+	sort.Sort(sort.Reverse(sort.StringSlice(generations)))
+	return generations, nil
+}
+
+// UpdateAliasesContext runs the add / remove / remove_index actions atomically
+func (e *ElasticInstance) UpdateAliasesContext(ctx context.Context, actions []map[string]any) error {
+
+	b, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	return e.perform(ctx, esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(b)}, "", nil)
+}
+
+// swap points the alias to index only
+func (e *ElasticInstance) swap(ctx context.Context, alias, index string, current []string, replaceIndex bool) error {
+
+	actions := make([]map[string]any, 0)
+
+	for _, c := range current {
+		actions = append(actions, map[string]any{"remove": map[string]any{"index": c, "alias": alias}})
+	}
+
+	if replaceIndex {
+		actions = append(actions, map[string]any{"remove_index": map[string]any{"index": alias}})
+	}
+
+	actions = append(actions, map[string]any{"add": map[string]any{"index": index, "alias": alias}})
+
+	return e.UpdateAliasesContext(ctx, actions)
+}
+
+func (e *ElasticInstance) count(ctx context.Context, indices []string) (int64, error) {
+
+	total := int64(0)
+	for _, index := range indices {
+		c, err := e.CountContext(ctx, index, "")
+		if err != nil {
+			return -1, err
+		}
+		total += c
+	}
+	return total, nil
+}
+
+// Rebuild creates a new generation of the alias from the templates, populates it,
+// checks its count and swaps the alias to it. The previous generations but opts.Keep
+// are deleted. It returns the new generation.
+func (e *ElasticInstance) Rebuild(ctx context.Context, alias string, populate Populate, opts RebuildOptions) (string, error) {
+
+	index := Generation(alias, time.Now())
+
+	exist, err := e.IndexExistContext(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+
+	current, err := e.AliasIndicesContext(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+
+	// an index named as the alias is not behind an alias
+	if exist && len(current) == 0 && !opts.ReplaceIndex {
+		return "", fmt.Errorf("%s is an index, not an alias, rebuild it with ReplaceIndex", alias)
+	}
+
+	if exist, err := e.IndexExistContext(ctx, index); err != nil {
+		return "", err
+	} else if exist {
+		return "", fmt.Errorf("%s already exists", index)
+	}
+
+	if opts.Template != nil {
+		if err := e.EnsureTemplateContext(ctx, *opts.Template); err != nil {
+			return "", err
+		}
+	}
+
+	// the settings come from the matching templates
+	if err := e.CreateIndexSettingsContext(ctx, index, nil, opts.Properties); err != nil {
+		return "", err
+	}
+
+	// a failed generation is never left behind
+	discard := func(cause error) (string, error) {
+		if err := e.DeleteIndexContext(context.Background(), index); err != nil {
+			log.Printf("[%s] error delete index : %s", index, err)
+		}
+		return "", cause
+	}
+
+	if err := populate(ctx, index); err != nil {
+		return discard(fmt.Errorf("[%s] populate failed : %w", index, err))
+	}
+
+	if err := e.RefreshContext(ctx, index); err != nil {
+		return discard(err)
+	}
+
+	c, err := e.CountContext(ctx, index, "")
+	if err != nil {
+		return discard(err)
+	}
+
+	if c < opts.MinDocs {
+		return discard(fmt.Errorf("[%s] %d docs, less than %d", index, c, opts.MinDocs))
+	}
+
+	previous := current
+	if len(previous) == 0 && exist {
+		previous = []string{alias}
+	}
+
+	if opts.MinRatio > 0 && len(previous) > 0 {
+
+		pc, err := e.count(ctx, previous)
+		if err != nil {
+			return discard(err)
+		}
+
+		if float64(c) < float64(pc)*opts.MinRatio {
+			return discard(fmt.Errorf("[%s] %d docs, less than %.0f%% of %d", index, c, opts.MinRatio*100, pc))
+		}
+	}
+
+	if err := e.swap(ctx, alias, index, current, exist && len(current) == 0); err != nil {
+		return discard(err)
+	}
+
+	log.Printf("[%s] %s -> %s (%d docs)", alias, current, index, c)
+
+	if err := e.prune(ctx, alias, index, opts.Keep); err != nil {
+		log.Printf("[%s] error prune generations : %s", alias, err)
+	}
+
+	return index, nil
+}
+
+// prune deletes the generations older than the keep previous ones of index
+func (e *ElasticInstance) prune(ctx context.Context, alias, index string, keep int) error {
+
+	generations, err := e.GenerationsContext(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, g := range generations {
+
+		if g >= index {
+			continue
+		}
+
+		if kept < keep {
+			kept++
+			continue
+		}
+
+		if err := e.DeleteIndexContext(ctx, g); err != nil {
+			return err
+		}
+		log.Printf("[%s] %s deleted", alias, g)
+	}
+
+	return nil
+}
+
+// Rollback points the alias back to the generation before the current one,
+// it returns that generation
+func (e *ElasticInstance) Rollback(ctx context.Context, alias string) (string, error) {
+
+	current, err := e.AliasIndicesContext(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+
+	if len(current) != 1 {
+		return "", fmt.Errorf("%s points to %d indices, rollback needs one", alias, len(current))
+	}
+
+	generations, err := e.GenerationsContext(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+
+	for _, g := range generations {
+
+		if g >= current[0] {
+			continue
+		}
+
+		if err := e.swap(ctx, alias, g, current, false); err != nil {
+			return "", err
+		}
+
+		log.Printf("[%s] rolled back %s -> %s", alias, current[0], g)
+		return g, nil
+	}
+
+	return "", fmt.Errorf("%s has no generation before %s", alias, current[0])
+}
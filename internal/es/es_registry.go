@@ -0,0 +1,118 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// VersionTTL is how long a cached server version is used before it is read again,
+// a cluster upgraded in place is seen after it
+var VersionTTL = 10 * time.Minute
+
+// registered is the instance of a cluster, its mutex serializes the creation
+// so that the registry lock is never held during a network call
+type registered struct {
+	mu      sync.Mutex
+	e       ElasticInstance
+	ready   bool
+	checked time.Time
+}
+
+// ESRegistry caches an instance per cluster section, the client (and its
+// connection pool) is created once
+type ESRegistry struct {
+	mu        sync.Mutex
+	instances map[string]*registered
+}
+
+var esRegistry ESRegistry
+
+func init() {
+	esRegistry.instances = make(map[string]*registered)
+}
+
+func (r *ESRegistry) entry(cluster string) *registered {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.instances[cluster]
+	if !ok {
+		c = &registered{}
+		r.instances[cluster] = c
+	}
+	return c
+}
+
+func (r *ESRegistry) get(ctx context.Context, cluster string) (ElasticInstance, error) {
+
+	c := r.entry(cluster)
+
+	c.mu.Lock()
+
+	if !c.ready {
+
+		defer c.mu.Unlock()
+
+		e, err := newInstance(cluster)
+		if err != nil {
+			return ElasticInstance{}, err
+		}
+
+		// an instance is cached only once its version is known
+		if err := e.readVersion(ctx); err != nil {
+			return ElasticInstance{}, err
+		}
+
+		c.e, c.ready, c.checked = e, true, time.Now()
+		return e, nil
+	}
+
+	if time.Since(c.checked) < VersionTTL {
+		defer c.mu.Unlock()
+		return c.e, nil
+	}
+
+	// one caller refreshes the version, the others go on with the cached one
+	// until the next TTL, even when the server can not be reached
+	c.checked = time.Now()
+	e := c.e
+	c.mu.Unlock()
+
+	if err := e.readVersion(ctx); err != nil {
+		log.Printf("[%s] error refreshing the server version : %s", cluster, err)
+		return e, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.e.MajorVersion, c.e.MinorVersion = e.MajorVersion, e.MinorVersion
+
+	return e, nil
+}
+
+func (r *ESRegistry) invalidate(cluster string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.instances, cluster)
+}
+
+// GetContext returns the cached instance of the cluster section, created
+// (and its server version read) on the first call
+func GetContext(ctx context.Context, cluster string) (ElasticInstance, error) {
+	return esRegistry.get(ctx, cluster)
+}
+
+// Invalidate drops the cached instance of the cluster, the next call creates a
+// new client with the current ini section
+func Invalidate(cluster string) {
+	esRegistry.invalidate(cluster)
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cleanupTimeout bounds the release of the scroll / point in time contexts,
+// which runs even when the read is cancelled
+const cleanupTimeout = 30 * time.Second
+
+// SliceReader reads a whole index in parallel, one worker per slice of a sliced
+// scroll, or of a point in time when the server supports it
+type SliceReader struct {
+	e      ElasticInstance
+	req    *Request
+	slices int
+	buffer int
+
+	mu       sync.Mutex
+	progress []*util.ProgressInfo
+	err      error
+
+	// pits are the point in time ids returned to the slices, all closed at the end
+	pits map[string]bool
+}
+
+// NewSliceReader creates a reader of the request with n slices
+func NewSliceReader(e ElasticInstance, req *Request, n int) *SliceReader {
+
+	if n < 1 {
+		n = 1
+	}
+
+	return &SliceReader{e: e, req: req, slices: n, buffer: n}
+}
+
+// Buffer sets the number of batches waiting in the channel, the slice count by default
+func (r *SliceReader) Buffer(n int) {
+	r.buffer = n
+}
+
+// Err returns the first error of the slices, once the channel is closed
+func (r *SliceReader) Err() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *SliceReader) fail(err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Progress returns the progress of every slice, NODE is 1 / 1 when the slice is done
+func (r *SliceReader) Progress() []string {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ps := make([]string, 0, len(r.progress))
+	for i, p := range r.progress {
+		ps = append(ps, fmt.Sprintf("slice %d/%d %s", i, r.slices, p))
+	}
+	return ps
+}
+
+func (r *SliceReader) update(i, total, docs int, done bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.progress[i]
+	if total > 0 {
+		p.SetDataCount(total)
+	}
+	p.AddDataProcessedCount(docs)
+	if done {
+		p.AddNodeProcessedCount(1)
+	}
+}
+
+func (r *SliceReader) keepPIT(id string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(id) > 0 {
+		r.pits[id] = true
+	}
+}
+
+// closePITs releases every point in time id seen by the slices, even when ctx is cancelled
+func (r *SliceReader) closePITs() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range r.pits {
+		if err := closePointInTime(ctx, r.e, r.req.Index, id); err != nil {
+			log.Printf("[%s] error close point in time : %s", r.req.Index, err)
+		}
+	}
+	r.pits = nil
+}
+
+func (r *SliceReader) slice(i int) map[string]any {
+
+	// max must be greater than 1
+	if r.slices < 2 {
+		return nil
+	}
+	return map[string]any{"id": i, "max": r.slices}
+}
+
+func (r *SliceReader) slicedQuery(i int) (string, error) {
+
+	q := make(map[string]any)
+	if len(strings.TrimSpace(r.req.Query)) > 0 {
+		if err := json.Unmarshal([]byte(r.req.Query), &q); err != nil {
+			return "", fmt.Errorf("invalid query : %w", err)
+		}
+	}
+
+	if s := r.slice(i); s != nil {
+		q["slice"] = s
+	}
+
+	b, err := json.Marshal(q)
+	return string(b), err
+}
+
+func send(ctx context.Context, out chan<- Documents, docs Documents) bool {
+
+	select {
+	case out <- docs:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *SliceReader) scroll(ctx context.Context, i int, out chan<- Documents) error {
+
+	query, err := r.slicedQuery(i)
+	if err != nil {
+		return err
+	}
+
+	scroll := r.req.Scroll
+	if scroll <= 0 {
+		scroll = time.Minute
+	}
+
+	req := *r.req
+	req.Query, req.Scroll = query, scroll
+
+	response, err := r.e.SearchContext(ctx, &req)
+	if err != nil {
+		return err
+	}
+
+	// the scroll context is cleared even when ctx is cancelled
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if _, err := r.e.ClearScrollContext(cctx, response.Result.ScrollId()); err != nil {
+			log.Printf("[%s] slice %d : error clear scroll : %s", r.req.Index, i, err)
+		}
+	}()
+
+	total := int(response.Result.TotalCount())
+
+	for {
+
+		docs := response.Result.Docs
+		if len(docs) == 0 {
+			r.update(i, total, 0, true)
+			return nil
+		}
+
+		if !send(ctx, out, docs) {
+			return ctx.Err()
+		}
+		r.update(i, total, len(docs), false)
+
+		if _, err := r.e.ScrollContext(ctx, response); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *SliceReader) page(ctx context.Context, i int, pit string, out chan<- Documents) error {
+
+	p := NewPaginator(r.e, r.req)
+	p.slice = r.slice(i)
+	p.shared = true
+	if err := p.From(Cursor{PitID: pit}); err != nil {
+		return err
+	}
+
+	// the point in time is shared, its ids are closed once by Read
+	defer func() {
+		r.keepPIT(p.Cursor().PitID)
+	}()
+
+	for {
+
+		response, err := p.Next(ctx)
+		if err != nil {
+			return err
+		}
+
+		if response == nil {
+			r.update(i, 0, 0, true)
+			return nil
+		}
+
+		docs := response.Result.Docs
+		if !send(ctx, out, docs) {
+			return ctx.Err()
+		}
+		r.update(i, int(response.Result.TotalCount()), len(docs), false)
+	}
+}
+
+// Read starts the workers and returns the batches of docs, the channel is closed
+// when every slice is read, a slice failed or ctx is cancelled. Check Err after.
+func (r *SliceReader) Read(ctx context.Context) <-chan Documents {
+
+	out := make(chan Documents, r.buffer)
+
+	r.progress = make([]*util.ProgressInfo, r.slices)
+	for i := range r.progress {
+		r.progress[i] = util.NewProgressInfo()
+		r.progress[i].SetNodeCount(1)
+	}
+
+	r.pits = make(map[string]bool)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// the slices of a point in time share it
+	var pit *Paginator
+	if r.e.SupportsPIT() {
+		pit = NewPaginator(r.e, r.req)
+		if err := pit.open(ctx); err != nil {
+			r.fail(err)
+			cancel()
+			close(out)
+			return out
+		}
+		r.keepPIT(pit.Cursor().PitID)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < r.slices; i++ {
+
+		wg.Add(1)
+		go func(i int) {
+
+			defer wg.Done()
+
+			var err error
+			if pit != nil {
+				err = r.page(ctx, i, pit.Cursor().PitID, out)
+			} else {
+				err = r.scroll(ctx, i, out)
+			}
+
+			if err != nil {
+				r.fail(fmt.Errorf("slice %d : %w", i, err))
+				// the other slices stop too
+				cancel()
+			}
+		}(i)
+	}
+
+	go func() {
+
+		wg.Wait()
+		cancel()
+
+		r.closePITs()
+
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,468 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package es
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// template kinds, the directories of the template files
+const (
+	TemplateLegacy     = "legacy"
+	TemplateComposable = "composable"
+	TemplateComponent  = "component"
+)
+
+// TemplateKinds is the order the kinds are put in, components first as the
+// composable templates use them
+var TemplateKinds = []string{TemplateComponent, TemplateComposable, TemplateLegacy}
+
+type IndexTemplate struct {
+	Kind    string
+	Name    string
+	Version int
+	Body    map[string]any
+}
+
+func (t IndexTemplate) String() string {
+	return fmt.Sprintf("%s/%s (version %d)", t.Kind, t.Name, t.Version)
+}
+
+// Properties returns the mapping properties of the template, of its type (6.x)
+// for a typed legacy template. The properties of the component templates of a
+// composable template are not included, see TemplatePropertiesContext.
+func (t IndexTemplate) Properties() map[string]any {
+
+	mappings := asMap(t.Body["mappings"])
+	if t.Kind != TemplateLegacy {
+		mappings = asMap(asMap(t.Body["template"])["mappings"])
+	}
+
+	if properties := asMap(mappings["properties"]); properties != nil {
+		return properties
+	}
+
+	for _, v := range mappings {
+		if properties := asMap(asMap(v)["properties"]); properties != nil {
+			return properties
+		}
+	}
+
+	return map[string]any{}
+}
+
+// ComposedOf returns the component templates of a composable template in order
+func (t IndexTemplate) ComposedOf() []string {
+
+	components := make([]string, 0)
+	if t.Kind != TemplateComposable {
+		return components
+	}
+
+	names, _ := t.Body["composed_of"].([]any)
+	for _, name := range names {
+		if s, ok := name.(string); ok {
+			components = append(components, s)
+		}
+	}
+	return components
+}
+
+// mergeProperties puts the fields of src into dst, the fields of an object field
+// defined in both are merged as elasticsearch composes the mappings
+func mergeProperties(dst, src map[string]any) {
+
+	for k, v := range src {
+
+		from, to := asMap(asMap(v)["properties"]), asMap(asMap(dst[k])["properties"])
+		if from == nil || to == nil {
+			dst[k] = v
+			continue
+		}
+
+		field := make(map[string]any)
+		for fk, fv := range asMap(dst[k]) {
+			field[fk] = fv
+		}
+		for fk, fv := range asMap(v) {
+			field[fk] = fv
+		}
+
+		properties := make(map[string]any)
+		mergeProperties(properties, to)
+		mergeProperties(properties, from)
+		field["properties"] = properties
+
+		dst[k] = field
+	}
+}
+
+// TemplatePropertiesContext returns the mapping properties an index created by
+// the template gets, those of the component templates of a composable template
+// in the composed_of order and then its own
+func (e *ElasticInstance) TemplatePropertiesContext(ctx context.Context, t IndexTemplate) (map[string]any, error) {
+
+	properties := make(map[string]any)
+
+	for _, name := range t.ComposedOf() {
+
+		c, err := e.GetTemplateContext(ctx, TemplateComponent, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if c == nil {
+			return nil, fmt.Errorf("component template %s of %s does not exist", name, t.Name)
+		}
+
+		mergeProperties(properties, c.Properties())
+	}
+
+	mergeProperties(properties, t.Properties())
+	return properties, nil
+}
+
+// Managed is true for the templates installed by elasticsearch or a stack
+// component (logs, metrics, synthetics, ilm-history ...), _meta.managed
+func (t IndexTemplate) Managed() bool {
+
+	managed, _ := asMap(t.Body["_meta"])["managed"].(bool)
+	return managed
+}
+
+// flattenSettings returns the settings as the cluster returns them, the keys
+// dotted under index. and the values as strings
+func flattenSettings(prefix string, settings map[string]any, flat map[string]any) {
+
+	for k, v := range settings {
+
+		key := k
+		if len(prefix) > 0 {
+			key = prefix + "." + k
+		}
+
+		switch value := v.(type) {
+		case map[string]any:
+			flattenSettings(key, value, flat)
+			continue
+		case []any:
+			values := make([]any, 0, len(value))
+			for _, e := range value {
+				values = append(values, fmt.Sprint(e))
+			}
+			v = values
+		case nil:
+		default:
+			v = fmt.Sprint(value)
+		}
+
+		if !strings.HasPrefix(key, "index.") {
+			key = "index." + key
+		}
+		flat[key] = v
+	}
+}
+
+// normalizeBody drops the empty values and flattens the settings of the body
+// (or of its template), so that a template file compares equal to the template
+// returned by the cluster
+func normalizeBody(body map[string]any, top bool) map[string]any {
+
+	n := make(map[string]any, len(body))
+
+	for k, v := range body {
+
+		switch value := v.(type) {
+		case map[string]any:
+			if top && k == "settings" {
+				flat := make(map[string]any)
+				flattenSettings("", value, flat)
+				value = flat
+			} else {
+				value = normalizeBody(value, top && k == "template")
+			}
+			if len(value) == 0 {
+				continue
+			}
+			v = value
+		case []any:
+			if len(value) == 0 {
+				continue
+			}
+		case nil:
+			continue
+		}
+
+		n[k] = v
+	}
+
+	return n
+}
+
+// SameBody compares the normalized bodies of the templates, the version included
+func (t IndexTemplate) SameBody(o IndexTemplate) bool {
+
+	a, b := normalizeBody(t.Body, true), normalizeBody(o.Body, true)
+
+	// the order of a legacy template is 0 by default
+	for _, m := range []map[string]any{a, b} {
+		if order, ok := m["order"].(float64); ok && order == 0 {
+			delete(m, "order")
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func newIndexTemplate(kind, name string, body map[string]any) IndexTemplate {
+
+	t := IndexTemplate{Kind: kind, Name: name, Body: body}
+	if v, ok := body["version"].(float64); ok {
+		t.Version = int(v)
+	}
+	return t
+}
+
+// LoadTemplates reads the <kind>/<name>.json files of dir, the version of a
+// template is the version field of its body
+func LoadTemplates(dir string) ([]IndexTemplate, error) {
+
+	templates := make([]IndexTemplate, 0)
+
+	for _, kind := range TemplateKinds {
+
+		files, err := filepath.Glob(filepath.Join(dir, kind, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+
+			t, err := LoadTemplate(f)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, t)
+		}
+	}
+
+	return templates, nil
+}
+
+// LoadTemplate reads a <kind>/<name>.json file, the kind is its directory
+func LoadTemplate(f string) (IndexTemplate, error) {
+
+	kind := filepath.Base(filepath.Dir(f))
+	switch kind {
+	case TemplateLegacy, TemplateComposable, TemplateComponent:
+	default:
+		return IndexTemplate{}, fmt.Errorf("%s : unknown template kind %s", f, kind)
+	}
+
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return IndexTemplate{}, err
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		return IndexTemplate{}, fmt.Errorf("%s : %w", f, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(f), ".json")
+	return newIndexTemplate(kind, name, body), nil
+}
+
+func (e *ElasticInstance) supportsTemplate(kind string) error {
+
+	switch kind {
+	case TemplateLegacy:
+		return nil
+	case TemplateComposable, TemplateComponent:
+		if !e.IsVersionAtLeast(7, 8) {
+			return fmt.Errorf("%s templates need elasticsearch 7.8+", kind)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown template kind %s", kind)
+}
+
+func (e *ElasticInstance) PutTemplateContext(ctx context.Context, t IndexTemplate) error {
+
+	if err := e.supportsTemplate(t.Kind); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(t.Body)
+	if err != nil {
+		return err
+	}
+
+	var req esapi.Request
+	switch t.Kind {
+	case TemplateLegacy:
+		req = esapi.IndicesPutTemplateRequest{Name: t.Name, Body: bytes.NewReader(b)}
+	case TemplateComposable:
+		req = esapi.IndicesPutIndexTemplateRequest{Name: t.Name, Body: bytes.NewReader(b)}
+	case TemplateComponent:
+		req = esapi.ClusterPutComponentTemplateRequest{Name: t.Name, Body: bytes.NewReader(b)}
+	}
+
+	return e.perform(ctx, req, t.Name, nil)
+}
+
+// ListTemplatesContext returns the templates of the kind matching the pattern (* when empty)
+func (e *ElasticInstance) ListTemplatesContext(ctx context.Context, kind, pattern string) ([]IndexTemplate, error) {
+
+	if err := e.supportsTemplate(kind); err != nil {
+		return nil, err
+	}
+
+	if len(pattern) == 0 {
+		pattern = "*"
+	}
+
+	templates := make([]IndexTemplate, 0)
+
+	switch kind {
+	case TemplateLegacy:
+
+		var r map[string]map[string]any
+		err := e.perform(ctx, esapi.IndicesGetTemplateRequest{Name: []string{pattern}}, pattern, &r)
+		if IsNotFound(err) {
+			return templates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for name, body := range r {
+			templates = append(templates, newIndexTemplate(kind, name, body))
+		}
+
+	case TemplateComposable:
+
+		var r struct {
+			IndexTemplates []struct {
+				Name          string         `json:"name"`
+				IndexTemplate map[string]any `json:"index_template"`
+			} `json:"index_templates"`
+		}
+		err := e.perform(ctx, esapi.IndicesGetIndexTemplateRequest{Name: pattern}, pattern, &r)
+		if IsNotFound(err) {
+			return templates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range r.IndexTemplates {
+			templates = append(templates, newIndexTemplate(kind, t.Name, t.IndexTemplate))
+		}
+
+	case TemplateComponent:
+
+		var r struct {
+			ComponentTemplates []struct {
+				Name              string         `json:"name"`
+				ComponentTemplate map[string]any `json:"component_template"`
+			} `json:"component_templates"`
+		}
+		err := e.perform(ctx, esapi.ClusterGetComponentTemplateRequest{Name: []string{pattern}}, pattern, &r)
+		if IsNotFound(err) {
+			return templates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range r.ComponentTemplates {
+			templates = append(templates, newIndexTemplate(kind, t.Name, t.ComponentTemplate))
+		}
+	}
+
+	// the system templates start with a dot
+	filtered := templates[:0]
+	for _, t := range templates {
+		if !strings.HasPrefix(t.Name, ".") {
+			filtered = append(filtered, t)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Name < filtered[j].Name
+	})
+
+	return filtered, nil
+}
+
+// GetTemplateContext returns the template, nil when it does not exist
+func (e *ElasticInstance) GetTemplateContext(ctx context.Context, kind, name string) (*IndexTemplate, error) {
+
+	if strings.Contains(name, "*") {
+		return nil, fmt.Errorf("'*' character not allowed in getting template")
+	}
+
+	templates, err := e.ListTemplatesContext(ctx, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range templates {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// EnsureTemplateContext puts the template unless the cluster has the same body
+func (e *ElasticInstance) EnsureTemplateContext(ctx context.Context, t IndexTemplate) error {
+
+	current, err := e.GetTemplateContext(ctx, t.Kind, t.Name)
+	if err != nil {
+		return err
+	}
+
+	if current != nil && current.SameBody(t) {
+		return nil
+	}
+
+	return e.PutTemplateContext(ctx, t)
+}
+
+func (e *ElasticInstance) DeleteTemplateContext(ctx context.Context, kind, name string) error {
+
+	if err := e.supportsTemplate(kind); err != nil {
+		return err
+	}
+
+	if strings.Contains(name, "*") {
+		return fmt.Errorf("'*' character not allowed in deleting template")
+	}
+
+	var req esapi.Request
+	switch kind {
+	case TemplateLegacy:
+		req = esapi.IndicesDeleteTemplateRequest{Name: name}
+	case TemplateComposable:
+		req = esapi.IndicesDeleteIndexTemplateRequest{Name: name}
+	case TemplateComponent:
+		req = esapi.ClusterDeleteComponentTemplateRequest{Name: name}
+	}
+
+	return e.perform(ctx, req, name, nil)
+}
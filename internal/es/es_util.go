@@ -95,6 +95,8 @@ func Aggs(f string, size int) map[string]any {
 	return aggs
 }
 
+// Deprecated: a partition count guessed from a cardinality may miss buckets,
+// use NewCompositeAgg with a CompositeIterator
 func PartitionAggs(p, np, size int) map[string]any {
 
 	saggs := make(map[string]any)
@@ -131,10 +133,16 @@ func PartitionAggs(p, np, size int) map[string]any {
 	return aggs
 }
 
+// PartitionCount returns the partitions of size buckets (with a 20% margin) for the cardinality
+//
+// Deprecated: use NewCompositeAgg with a CompositeIterator
 func PartitionCount(cdr, size int) int {
 
 	size = int(float64(size) * 1.2)
-	return int(math.Ceil(float64(cdr / size)))
+	if size <= 0 {
+		return 1
+	}
+	return int(math.Ceil(float64(cdr) / float64(size)))
 }
 
 func CardinalityAggregationTerm(field string) map[string]any {
@@ -0,0 +1,172 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/hash"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// migration files
+//
+// 0001_create_item.up.sql
+// 0001_create_item.down.sql
+// 0002_add_item_price.up.sql
+// ...
+
+type MigrationFunc func(tx *database.Tx) error
+
+type Migration struct {
+	Version int64
+	Name    string
+
+	Up   []string
+	Down []string
+
+	UpFunc   MigrationFunc
+	DownFunc MigrationFunc
+
+	Checksum string
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+func (m *Migration) HasDown() bool {
+	return len(m.Down) > 0 || m.DownFunc != nil
+}
+
+type registry struct {
+	mu         sync.Mutex
+	migrations map[int64]*Migration
+}
+
+var goMigrations registry
+
+func init() {
+	goMigrations.migrations = make(map[int64]*Migration)
+}
+
+// Register adds a go function migration, call it from an init() of the package holding the migration
+func Register(version int64, name string, up, down MigrationFunc) {
+
+	goMigrations.mu.Lock()
+	defer goMigrations.mu.Unlock()
+
+	if _, ok := goMigrations.migrations[version]; ok {
+		panic(fmt.Sprintf("migration version already registered : %d", version))
+	}
+
+	goMigrations.migrations[version] = &Migration{
+		Version:  version,
+		Name:     name,
+		UpFunc:   up,
+		DownFunc: down,
+		Checksum: hash.CalculateHash("go:" + name),
+	}
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migration files of dir and merges them with the registered go migrations
+func Load(dir string) ([]*Migration, error) {
+
+	migrations := make(map[int64]*Migration)
+
+	goMigrations.mu.Lock()
+	for v, m := range goMigrations.migrations {
+		migrations[v] = m
+	}
+	goMigrations.mu.Unlock()
+
+	if len(dir) > 0 {
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+
+			ms := fileName.FindStringSubmatch(filepath.Base(file))
+			if ms == nil {
+				continue
+			}
+
+			version, err := strconv.ParseInt(ms[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := migrations[version]
+			if !ok {
+				m = &Migration{Version: version, Name: ms[2]}
+				migrations[version] = m
+			}
+
+			if m.Name != ms[2] || m.UpFunc != nil {
+				return nil, fmt.Errorf("duplicated migration version %d : %s", version, file)
+			}
+
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			if ms[3] == "up" {
+				m.Up = database.SplitStatements(string(b))
+				m.Checksum = hash.CalculateHash(string(b))
+			} else {
+				m.Down = database.SplitStatements(string(b))
+			}
+		}
+	}
+
+	r := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if len(m.Up) == 0 && m.UpFunc == nil {
+			return nil, fmt.Errorf("migration has no up : %s", m)
+		}
+		r = append(r, m)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Version < r[j].Version
+	})
+
+	return r, nil
+}
+
+func statements(m *Migration, up bool) []string {
+
+	ss := m.Down
+	f := m.DownFunc
+	if up {
+		ss = m.Up
+		f = m.UpFunc
+	}
+
+	if f != nil {
+		return []string{fmt.Sprintf("-- go function migration %s", m)}
+	}
+
+	r := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if !strings.HasSuffix(s, ";") {
+			s += ";"
+		}
+		r = append(r, s)
+	}
+	return r
+}
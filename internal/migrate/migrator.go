@@ -0,0 +1,446 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultTable       = "schema_migrations"
+	DefaultLockTimeout = 10 * time.Second
+
+	lockPoll = 500 * time.Millisecond
+)
+
+type record struct {
+	Version   int64  `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt string `db:"applied_at"`
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt string
+
+	// Dirty is set when the migration file changed after it was applied
+	Dirty bool
+
+	// Missing is set when an applied migration has no file anymore
+	Missing bool
+}
+
+type Migrator struct {
+	db         *database.DB
+	migrations []*Migration
+
+	table       string
+	lockTimeout time.Duration
+	dryRun      bool
+	out         io.Writer
+}
+
+func New(db *database.DB, migrations []*Migration) *Migrator {
+
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		table:       DefaultTable,
+		lockTimeout: DefaultLockTimeout,
+		out:         os.Stdout,
+	}
+}
+
+// DryRun prints the statements to be executed instead of running them
+func (m *Migrator) DryRun(d bool) {
+	m.dryRun = d
+}
+
+func (m *Migrator) Output(w io.Writer) {
+	m.out = w
+}
+
+func (m *Migrator) Table(t string) {
+	m.table = t
+}
+
+func (m *Migrator) LockTimeout(d time.Duration) {
+	m.lockTimeout = d
+}
+
+// lock takes an advisory lock so that concurrent runners are serialized.
+// The lock belongs to the session, so it is held on a dedicated connection.
+func (m *Migrator) lock() (func(), error) {
+
+	ctx := context.Background()
+	name := fmt.Sprintf("migrate:%s", m.table)
+
+	var query string
+	var args []any
+
+	switch m.db.Dialect().Name() {
+	case database.AdapterMySQL:
+		query = "SELECT GET_LOCK(?, ?)"
+		args = []any{name, int(m.lockTimeout.Seconds())}
+	case database.AdapterPostgres:
+		query = "SELECT CASE WHEN pg_try_advisory_lock(hashtext($1)) THEN 1 ELSE 0 END"
+		args = []any{name}
+	default:
+		return func() {}, nil
+	}
+
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(m.lockTimeout)
+
+	for {
+
+		var got sql.NullInt64
+		if err := conn.GetContext(ctx, &got, query, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if got.Valid && got.Int64 == 1 {
+			break
+		}
+
+		// GET_LOCK waits by itself, pg_try_advisory_lock is polled until the timeout
+		if m.db.Dialect().Name() != database.AdapterPostgres || !time.Now().Before(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("could not get migration lock (%s), another migration is running", name)
+		}
+
+		time.Sleep(lockPoll)
+	}
+
+	return func() {
+
+		release := "SELECT RELEASE_LOCK(?)"
+		if m.db.Dialect().Name() == database.AdapterPostgres {
+			release = "SELECT pg_advisory_unlock(hashtext($1))"
+		}
+
+		if _, err := conn.ExecContext(ctx, release, name); err != nil {
+			log.Println(err)
+		}
+		conn.Close()
+	}, nil
+}
+
+func (m *Migrator) ensureTable() error {
+
+	if m.db.Exist(m.table) {
+		return nil
+	}
+
+	builder := m.db.QueryBuilder(constant.QueryTypeCreate)
+	builder.Table(m.table)
+	builder.AddSet("version", "bigint NOT NULL PRIMARY KEY")
+	builder.AddSet("name", "string NOT NULL")
+	builder.AddSet("checksum", "string NOT NULL")
+	builder.AddSet("applied_at", "string NOT NULL")
+
+	q, _ := builder.Build()
+
+	if m.dryRun {
+		fmt.Fprintln(m.out, q)
+		return nil
+	}
+
+	_, err := m.db.Exec(q)
+	return err
+}
+
+func (m *Migrator) applied() (map[int64]record, error) {
+
+	records := make(map[int64]record)
+
+	if !m.db.Exist(m.table) {
+		return records, nil
+	}
+
+	builder := m.db.DqlBuilder()
+	builder.Table(m.db.Dialect().Quote(m.table))
+	builder.AddOrder("version", constant.DBOrderAsc)
+
+	rs := make([]record, 0)
+	if err := m.db.SelectBy(&rs, builder); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rs {
+		records[r.Version] = r
+	}
+
+	return records, nil
+}
+
+func (m *Migrator) find(v int64) *Migration {
+
+	for _, mg := range m.migrations {
+		if mg.Version == v {
+			return mg
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) verify(records map[int64]record) error {
+
+	for v, r := range records {
+		mg := m.find(v)
+		if mg != nil && mg.Checksum != r.Checksum {
+			return fmt.Errorf("checksum mismatch, migration changed after it was applied : %s", mg)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(mg *Migration, up bool) error {
+
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+
+	var bookkeeping database.QueryBuilder
+	if up {
+		bookkeeping = m.db.QueryBuilder(constant.QueryTypeInsert)
+		bookkeeping.Table(m.table)
+		bookkeeping.AddSet("version", mg.Version)
+		bookkeeping.AddSet("name", mg.Name)
+		bookkeeping.AddSet("checksum", mg.Checksum)
+		bookkeeping.AddSet("applied_at", util.FullTime())
+	} else {
+		bookkeeping = m.db.QueryBuilder(constant.QueryTypeDelete)
+		bookkeeping.Table(m.table)
+		bookkeeping.AddCond("version", constant.EQ, mg.Version)
+	}
+
+	bq, barg := bookkeeping.Build()
+
+	if m.dryRun {
+		fmt.Fprintf(m.out, "-- %s %s\n", direction, mg)
+		for _, s := range statements(mg, up) {
+			fmt.Fprintln(m.out, s)
+		}
+		fmt.Fprintln(m.out, bq)
+		return nil
+	}
+
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	run := func() error {
+
+		f := mg.DownFunc
+		ss := mg.Down
+		if up {
+			f = mg.UpFunc
+			ss = mg.Up
+		}
+
+		if f != nil {
+			return f(tx)
+		}
+
+		for _, s := range ss {
+			if _, err := tx.Exec(s); err != nil {
+				return fmt.Errorf("%s %s : %v\n%s", direction, mg, err, s)
+			}
+		}
+		return nil
+	}
+
+	if err := run(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.NamedExec(bq, barg); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("migrated %s : %s", direction, mg)
+	return nil
+}
+
+// Up applies every pending migration in version order
+func (m *Migrator) Up() error {
+
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	records, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	if err := m.verify(records); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, mg := range m.migrations {
+
+		if _, ok := records[mg.Version]; ok {
+			continue
+		}
+
+		if err := m.apply(mg, true); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if count == 0 {
+		log.Println("no pending migrations")
+	}
+
+	return nil
+}
+
+// Down rolls back the last n applied migrations
+func (m *Migrator) Down(n int) error {
+
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = m.down(n)
+	return err
+}
+
+func (m *Migrator) down(n int) ([]*Migration, error) {
+
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, 0, len(records))
+	for v := range records {
+		versions = append(versions, v)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+
+	if n > len(versions) {
+		n = len(versions)
+	}
+
+	reverted := make([]*Migration, 0, n)
+
+	for _, v := range versions[:n] {
+
+		mg := m.find(v)
+		if mg == nil {
+			return reverted, fmt.Errorf("applied migration has no file : %d_%s", v, records[v].Name)
+		}
+
+		if !mg.HasDown() {
+			return reverted, fmt.Errorf("migration has no down : %s", mg)
+		}
+
+		if err := m.apply(mg, false); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, mg)
+	}
+
+	return reverted, nil
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (m *Migrator) Redo() error {
+
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	reverted, err := m.down(1)
+	if err != nil {
+		return err
+	}
+
+	for _, mg := range reverted {
+		if err := m.apply(mg, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) Status() ([]Status, error) {
+
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+
+	for _, mg := range m.migrations {
+
+		s := Status{Version: mg.Version, Name: mg.Name}
+
+		if r, ok := records[mg.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = r.AppliedAt
+			s.Dirty = r.Checksum != mg.Checksum
+			delete(records, mg.Version)
+		}
+
+		status = append(status, s)
+	}
+
+	for _, r := range records {
+		status = append(status, Status{Version: r.Version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Version < status[j].Version
+	})
+
+	return status, nil
+}
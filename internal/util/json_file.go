@@ -0,0 +1,64 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ReadJSON decodes the file f into v, false when the file does not exist.
+// The numbers of an any stay exact, a float64 loses big ids.
+func ReadJSON(f string, v any) (bool, error) {
+
+	b, err := os.ReadFile(f)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	if err := dec.Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteJSON writes v indented to the file f, its directory is created
+func WriteJSON(f string, v any) error {
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return err
+	}
+
+	// written aside and renamed, a crash never leaves a broken file
+	tmp := f + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f)
+}
+
+// RemoveFile removes the file f, a missing file is not an error
+func RemoveFile(f string) error {
+
+	err := os.Remove(f)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
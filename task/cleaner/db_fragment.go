@@ -0,0 +1,401 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cleaner
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/config"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// table defragmenter config
+//
+// [table_defragmenter]
+// min_free_mb = 100      ; skip the tables with less free space
+// min_ratio = 0.1        ; skip the tables with a lower DATA_FREE / size ratio
+// window = 02:00-05:00   ; start a rebuild only in the window, may cross midnight
+// concurrency = 1
+// method = optimize      ; optimize or force (ALTER TABLE ... FORCE)
+// include = log_*
+// exclude = log_keep_*
+// protected = user, order_*
+
+const (
+	TableDefragmented = "defragmented"
+	TableDeferred     = "deferred"
+
+	MethodOptimize = "optimize"
+	MethodForce    = "force"
+
+	mb = 1024 * 1024
+)
+
+type DefragResult struct {
+	TableResult
+
+	Before   database.TableStat
+	After    database.TableStat
+	Duration time.Duration
+}
+
+// Reclaimed returns the bytes freed by the rebuild
+func (r DefragResult) Reclaimed() int64 {
+
+	if r.Status != TableDefragmented {
+		return 0
+	}
+	return r.Before.Size() - r.After.Size()
+}
+
+type window struct {
+	from, to time.Duration
+}
+
+// parseWindow parses HH:MM-HH:MM, an empty window is always open
+func parseWindow(s string) (*window, error) {
+
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid window %s, HH:MM-HH:MM expected", s)
+	}
+
+	w := &window{}
+	for i, p := range parts {
+
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %s : %v", s, err)
+		}
+
+		d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.from = d
+		} else {
+			w.to = d
+		}
+	}
+
+	return w, nil
+}
+
+func (w *window) open(now time.Time) bool {
+
+	if w == nil {
+		return true
+	}
+
+	d := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	if w.from <= w.to {
+		return d >= w.from && d < w.to
+	}
+	// crosses midnight
+	return d >= w.from || d < w.to
+}
+
+func (w *window) String() string {
+
+	if w == nil {
+		return "always"
+	}
+
+	hm := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return hm(w.from) + "-" + hm(w.to)
+}
+
+type DbTableDefragmenter struct {
+	section string
+	dryRun  bool
+
+	minFree     int64
+	minRatio    float64
+	window      *window
+	concurrency int
+	method      string
+
+	filter tableFilter
+
+	// err is a config error, the task refuses to run with it
+	err error
+
+	results []DefragResult
+}
+
+// NewDbTableDefragmenter creates a defragmenter rebuilding the most fragmented
+// tables of the db section, with the table_defragmenter config
+func NewDbTableDefragmenter(section string, dryRun bool) *DbTableDefragmenter {
+
+	if len(section) == 0 {
+		section = constant.CKDBMain
+	}
+
+	task := &DbTableDefragmenter{
+		section:     section,
+		dryRun:      dryRun,
+		minFree:     100 * mb,
+		minRatio:    0.1,
+		concurrency: 1,
+		method:      MethodOptimize,
+		filter:      newTableFilter(constant.CKTableDefragmenter),
+	}
+
+	if s, ok := config.Get(constant.CKTableDefragmenter); ok {
+
+		task.minFree = s.Key("min_free_mb").MustInt64(100) * mb
+		task.minRatio = s.Key("min_ratio").MustFloat64(0.1)
+		task.concurrency = s.Key("concurrency").MustInt(1)
+		task.method = s.Key("method").MustString(MethodOptimize)
+
+		// a broken window must not open the rebuilds at any hour
+		w, err := parseWindow(s.Key("window").String())
+		if err != nil {
+			task.err = fmt.Errorf("%s : %w", constant.CKTableDefragmenter, err)
+		}
+		task.window = w
+	}
+
+	return task
+}
+
+// Window replaces the window of the config, HH:MM-HH:MM
+func (task *DbTableDefragmenter) Window(s string) error {
+
+	w, err := parseWindow(s)
+	if err != nil {
+		return err
+	}
+	task.window = w
+	task.err = nil
+	return nil
+}
+
+func (task *DbTableDefragmenter) Concurrency(n int) {
+	task.concurrency = n
+}
+
+func (task *DbTableDefragmenter) Method(m string) {
+	task.method = m
+}
+
+func (task *DbTableDefragmenter) Results() []DefragResult {
+	return task.results
+}
+
+// candidates returns the skipped tables and the tables to rebuild, most free space first
+func (task *DbTableDefragmenter) candidates(stats []database.TableStat) (skipped, ranked []DefragResult) {
+
+	for _, s := range stats {
+
+		r := DefragResult{TableResult: TableResult{Table: s.Name}, Before: s}
+
+		reason := task.filter.skip(s.Name)
+
+		if len(reason) == 0 && !strings.EqualFold(s.Engine, "InnoDB") {
+			reason = fmt.Sprintf("%s engine", s.Engine)
+		}
+
+		if len(reason) == 0 && s.DataFree < task.minFree {
+			reason = fmt.Sprintf("%d MB free", s.DataFree/mb)
+		}
+
+		if len(reason) == 0 && s.Fragmentation() < task.minRatio {
+			reason = fmt.Sprintf("%.1f%% fragmented", s.Fragmentation()*100)
+		}
+
+		if len(reason) > 0 {
+			r.Status, r.Reason = TableSkipped, reason
+			skipped = append(skipped, r)
+			continue
+		}
+
+		ranked = append(ranked, r)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Before.DataFree > ranked[j].Before.DataFree
+	})
+
+	return skipped, ranked
+}
+
+func (task *DbTableDefragmenter) statement(db *database.DB, table string) string {
+
+	if task.method == MethodForce {
+		return fmt.Sprintf("ALTER TABLE %s FORCE", db.Dialect().Quote(table))
+	}
+	return fmt.Sprintf("OPTIMIZE TABLE %s", db.Dialect().Quote(table))
+}
+
+// optimizeTable runs OPTIMIZE TABLE, which reports the errors as rows instead of failing
+func optimizeTable(db *database.DB, stmt string) error {
+
+	rows, err := db.Queryx(stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		m := make(map[string]any)
+		if err := rows.MapScan(m); err != nil {
+			return err
+		}
+
+		if msgType := fmt.Sprintf("%s", m["Msg_type"]); strings.EqualFold(msgType, "error") {
+			return fmt.Errorf("%s", m["Msg_text"])
+		}
+	}
+
+	return rows.Err()
+}
+
+func (task *DbTableDefragmenter) rebuild(db *database.DB, r DefragResult) DefragResult {
+
+	if !task.window.open(time.Now()) {
+		r.Status, r.Reason = TableDeferred, fmt.Sprintf("out of the window %s", task.window)
+		return r
+	}
+
+	stmt := task.statement(db, r.Table)
+
+	// a dry run reports the cached stats, ANALYZE TABLE takes locks
+	if task.dryRun {
+		r.Status, r.Reason = TablePlanned, fmt.Sprintf("%s (%d MB free)", stmt, r.Before.DataFree/mb)
+		return r
+	}
+
+	// the ranking is on cached stats, measure again before the rebuild
+	before, err := db.TableStat(r.Table)
+	if err != nil {
+		r.Status, r.Reason = TableFailed, fmt.Sprintf("stat failed : %v", err)
+		return r
+	}
+	r.Before = before
+
+	start := time.Now()
+
+	if task.method == MethodForce {
+		_, err = db.Exec(stmt)
+	} else {
+		err = optimizeTable(db, stmt)
+	}
+
+	r.Duration = time.Since(start)
+
+	if err != nil {
+		r.Status, r.Reason = TableFailed, err.Error()
+		return r
+	}
+
+	after, err := db.TableStat(r.Table)
+	if err != nil {
+		r.Status, r.Reason = TableDefragmented, fmt.Sprintf("rebuilt, stat failed : %v", err)
+		r.After = before
+		return r
+	}
+
+	r.After = after
+	r.Status = TableDefragmented
+	r.Reason = fmt.Sprintf("%d MB reclaimed in %s", r.Reclaimed()/mb, r.Duration.Round(time.Second))
+
+	log.Printf("[%s] %s", r.Table, r.Reason)
+	return r
+}
+
+func (task *DbTableDefragmenter) defragment() error {
+
+	if task.err != nil {
+		return task.err
+	}
+
+	db := database.MustGet(task.section)
+
+	if db.Dialect().Name() != database.AdapterMySQL {
+		return fmt.Errorf("table defragmenter is supported on mysql only")
+	}
+
+	if task.method != MethodOptimize && task.method != MethodForce {
+		return fmt.Errorf("not allowed method %s", task.method)
+	}
+
+	stats, err := db.TableStats("")
+	if err != nil {
+		return err
+	}
+
+	skipped, ranked := task.candidates(stats)
+
+	concurrency := task.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]DefragResult, len(ranked))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = task.rebuild(db, ranked[j])
+			}
+		}()
+	}
+
+	for j := range ranked {
+		jobs <- j
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	task.results = append(results, skipped...)
+	return nil
+}
+
+// Report prints the result of every table and returns the count by status
+func (task *DbTableDefragmenter) Report() map[string]int {
+
+	counts := make(map[string]int)
+	reclaimed := int64(0)
+
+	for _, r := range task.results {
+		counts[r.Status]++
+		reclaimed += r.Reclaimed()
+		fmt.Printf("%-12s %-40s %s\n", r.Status, r.Table, r.Reason)
+	}
+
+	fmt.Printf("\n%d tables : %d defragmented, %d planned, %d deferred, %d skipped, %d failed, %d MB reclaimed\n",
+		len(task.results), counts[TableDefragmented], counts[TablePlanned], counts[TableDeferred],
+		counts[TableSkipped], counts[TableFailed], reclaimed/mb)
+
+	return counts
+}
+
+// Execute defragments the tables and reports them, false when a table failed
+func (task *DbTableDefragmenter) Execute() bool {
+
+	if err := task.defragment(); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return task.Report()[TableFailed] == 0
+}
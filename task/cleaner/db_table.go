@@ -6,54 +6,245 @@ package cleaner
 
 import (
 	"fmt"
+	"github.com/alcomist/go-portfolio/internal/config"
 	"github.com/alcomist/go-portfolio/internal/constant"
 	"github.com/alcomist/go-portfolio/internal/database"
+	"log"
+	"path"
+	"strings"
 )
 
+// table optimizer config
+//
+// [table_optimizer]
+// include = log_*
+// include = tmp_*
+// exclude = log_keep_*
+// protected = user, order_*
+
+const (
+	TableSkipped = "skipped"
+	TableRebuilt = "rebuilt"
+	TablePlanned = "planned"
+	TableFailed  = "failed"
+
+	backupPrefix = "bak_"
+)
+
+type TableResult struct {
+	Table  string
+	Status string
+	Reason string
+}
+
+// tableFilter selects the tables by glob patterns, protected patterns win over all
+type tableFilter struct {
+	include   []string
+	exclude   []string
+	protected []string
+}
+
+func newTableFilter(section string) tableFilter {
+
+	f := tableFilter{}
+
+	if s, ok := config.Get(section); ok {
+		f.include = config.Values(s, "include")
+		f.exclude = config.Values(s, "exclude")
+		f.protected = config.Values(s, "protected")
+	}
+
+	return f
+}
+
 type DbTableOptimizer struct {
+	section string
+	dryRun  bool
+
+	filter tableFilter
+
+	results []TableResult
+}
+
+// NewDbTableOptimizer creates an optimizer rebuilding (rename / create / drop) the
+// empty tables of the db section, with the filters of the table_optimizer config
+func NewDbTableOptimizer(section string, dryRun bool) *DbTableOptimizer {
+
+	if len(section) == 0 {
+		section = constant.CKDBMain
+	}
+
+	return &DbTableOptimizer{
+		section: section,
+		dryRun:  dryRun,
+		filter:  newTableFilter(constant.CKTableOptimizer),
+	}
+}
+
+// Include replaces the include patterns of the config
+func (task *DbTableOptimizer) Include(patterns ...string) {
+	task.filter.include = patterns
+}
+
+// Exclude adds exclude patterns to the ones of the config
+func (task *DbTableOptimizer) Exclude(patterns ...string) {
+	task.filter.exclude = append(task.filter.exclude, patterns...)
+}
+
+func (task *DbTableOptimizer) Results() []TableResult {
+	return task.results
+}
+
+func match(patterns []string, table string) (string, bool) {
+
+	for _, p := range patterns {
+		if ok, err := path.Match(p, table); err == nil && ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// skip returns why the table is skipped, "" when it is a candidate
+func (f tableFilter) skip(table string) string {
+
+	if p, ok := match(f.protected, table); ok {
+		return fmt.Sprintf("protected (%s)", p)
+	}
+
+	if strings.HasPrefix(table, backupPrefix) {
+		return "backup table"
+	}
+
+	if len(f.include) > 0 {
+		if _, ok := match(f.include, table); !ok {
+			return "not included"
+		}
+	}
+
+	if p, ok := match(f.exclude, table); ok {
+		return fmt.Sprintf("excluded (%s)", p)
+	}
+
+	return ""
 }
 
-func NewDbTableOptimizer() *DbTableOptimizer {
+func count(db *database.DB, table string) (int, error) {
+
+	c := 0
+	err := db.Get(&c, fmt.Sprintf("SELECT COUNT(*) FROM %s", db.Dialect().Quote(table)))
+	return c, err
+}
+
+func (task *DbTableOptimizer) rebuild(db *database.DB, table string) TableResult {
+
+	r := TableResult{Table: table, Status: TableFailed}
+
+	if reason := task.filter.skip(table); len(reason) > 0 {
+		r.Status, r.Reason = TableSkipped, reason
+		return r
+	}
+
+	// a failed count must not look like an empty table
+	c, err := count(db, table)
+	if err != nil {
+		r.Reason = fmt.Sprintf("count failed : %v", err)
+		return r
+	}
+
+	if c > 0 {
+		r.Status, r.Reason = TableSkipped, fmt.Sprintf("not empty (%d rows)", c)
+		return r
+	}
+
+	stmt := db.CreateStatement(table)
+	if len(stmt.DDL) == 0 {
+		r.Reason = "can not get the create statement"
+		return r
+	}
+
+	backup := backupPrefix + table
+	if db.Exist(backup) {
+		r.Reason = fmt.Sprintf("%s already exists", backup)
+		return r
+	}
+
+	if task.dryRun {
+		r.Status, r.Reason = TablePlanned, "empty, would be rebuilt"
+		return r
+	}
+
+	if !db.Rename(table, backup) {
+		r.Reason = "rename failed"
+		return r
+	}
+
+	if db.Run(stmt.DDL, nil) == -1 {
+
+		if db.Rename(backup, table) {
+			r.Reason = "create failed, rename rolled back"
+		} else {
+			r.Reason = fmt.Sprintf("create failed, rollback failed, the table is left as %s", backup)
+		}
+		return r
+	}
+
+	// rows written between the count and the rename are kept
+	if c, err := count(db, backup); err != nil || c > 0 {
+		r.Reason = fmt.Sprintf("rows arrived during the rebuild, %s is kept", backup)
+		return r
+	}
 
-	return &DbTableOptimizer{}
+	if !db.Drop(backup) {
+		r.Status, r.Reason = TableRebuilt, fmt.Sprintf("%s could not be dropped", backup)
+		return r
+	}
+
+	r.Status = TableRebuilt
+	return r
 }
 
 func (task *DbTableOptimizer) optimize() {
 
-	db := database.MustGet(constant.CKDBMain)
+	db := database.MustGet(task.section)
 
-	tables := db.Tables("")
+	task.results = make([]TableResult, 0)
 
-	for _, table := range tables {
+	for _, table := range db.Tables("") {
 
 		if len(table) == 0 {
 			continue
 		}
 
-		count := db.Count(table)
-		if count == 0 {
-			stmt := db.CreateStatement(table)
+		r := task.rebuild(db, table)
+		task.results = append(task.results, r)
 
-			backupTable := "bak_" + table
+		if r.Status == TableFailed {
+			log.Printf("[%s] %s : %s", r.Table, r.Status, r.Reason)
+		}
+	}
+}
 
-			if db.Rename(table, backupTable) {
-				if db.Run(stmt.DDL, nil) != -1 {
-					if db.Drop(backupTable) {
-						fmt.Printf("RENAME / CREATE / DROP SUCCESS : %s\n", table)
-					}
-				}
-			}
+// Report prints the result of every table and returns the count by status
+func (task *DbTableOptimizer) Report() map[string]int {
 
-			//fmt.Println(table)
-			//fmt.Println(stmt)
+	counts := make(map[string]int)
 
-			//break
-		}
+	for _, r := range task.results {
+		counts[r.Status]++
+		fmt.Printf("%-8s %-40s %s\n", r.Status, r.Table, r.Reason)
 	}
-	//fmt.Println(tables)
+
+	fmt.Printf("\n%d tables : %d rebuilt, %d planned, %d skipped, %d failed\n",
+		len(task.results), counts[TableRebuilt], counts[TablePlanned], counts[TableSkipped], counts[TableFailed])
+
+	return counts
 }
 
-func (task *DbTableOptimizer) Execute() {
+// Execute optimizes the tables and reports them, false when a table failed
+func (task *DbTableOptimizer) Execute() bool {
 
 	task.optimize()
+
+	return task.Report()[TableFailed] == 0
 }
@@ -7,6 +7,7 @@ replace github.com/alcomist/go-portfolio/internal => ./../internal
 require (
 	github.com/BurntSushi/toml v1.2.1
 	github.com/alcomist/go-portfolio/internal v0.0.0-00010101000000-000000000000
+	github.com/jmoiron/sqlx v1.3.5
 	github.com/xuri/excelize/v2 v2.8.1
 	golang.org/x/crypto v0.21.0
 )
@@ -16,7 +17,8 @@ require (
 	github.com/elastic/go-elasticsearch/v7 v7.17.10 // indirect
 	github.com/go-sql-driver/mysql v1.7.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/jmoiron/sqlx v1.3.5 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/mattn/go-sqlite3 v1.14.22 // indirect
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
 	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.3 // indirect
@@ -0,0 +1,130 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index_dumper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"log"
+	"os"
+	"time"
+)
+
+type Dumper struct {
+	cluster, index, dir string
+	query               string
+	size                int
+	routing             bool
+}
+
+// NewDumper creates a dumper of the docs (matching query, all when empty) and
+// of the settings and the mapping of the index. routing keeps the _routing of the docs.
+func NewDumper(cluster, index, dir, query string, size int, routing bool) *Dumper {
+
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+
+	return &Dumper{cluster: cluster, index: index, dir: dir, query: query, size: size, routing: routing}
+}
+
+func (task *Dumper) dumpDocs(ctx context.Context, e es.ElasticInstance) (int64, error) {
+
+	file, err := create(dataFile(task.dir, task.index))
+	if err != nil {
+		return 0, err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	p := es.NewPaginator(e, &es.Request{Index: task.index, Query: task.query, Size: task.size, Scroll: 5 * time.Minute})
+	defer func() {
+		if err := p.Close(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	count := int64(0)
+
+	for {
+
+		response, err := p.Next(ctx)
+		if err != nil {
+			file.Close()
+			return count, err
+		}
+
+		if response == nil {
+			break
+		}
+
+		for _, doc := range response.Result.Docs {
+
+			l := line{Id: doc.Id, Source: doc.Source.Map()}
+			if task.routing {
+				l.Routing = doc.Routing
+			}
+
+			if err := enc.Encode(l); err != nil {
+				file.Close()
+				return count, err
+			}
+			count++
+		}
+
+		log.Printf("[%s] %d / %d docs dumped", task.index, count, response.Result.TotalCount())
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return count, err
+	}
+
+	return count, file.Close()
+}
+
+func (task *Dumper) Execute() bool {
+
+	ctx := context.Background()
+
+	if err := os.MkdirAll(task.dir, 0755); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	e := es.MustGet(task.cluster)
+
+	settings, err := e.SettingsContext(ctx, task.index)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	properties, err := e.PropertiesContext(ctx, task.index)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	count, err := task.dumpDocs(ctx, e)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	// the meta is written last, a dump without it is not complete
+	m := &meta{Index: task.index, Settings: settings, Properties: properties, Count: count, Dumped: util.FullTime()}
+	if err := writeMeta(task.dir, m); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf("[%s] %d docs dumped to %s", task.index, count, dataFile(task.dir, task.index))
+	return true
+}
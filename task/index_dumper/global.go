@@ -0,0 +1,234 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index_dumper
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dump files of an index
+//
+// <dir>/<index>.meta.json    the settings and the mapping properties
+// <dir>/<index>.jsonl.gz     a line per doc, {"_id", "_routing", "_source"}
+
+const (
+	DefaultPageSize = 1000
+
+	// maxLine is the longest doc line read on restore
+	maxLine = 64 * 1024 * 1024
+)
+
+type meta struct {
+	Index      string         `json:"index"`
+	Settings   map[string]any `json:"settings"`
+	Properties map[string]any `json:"properties"`
+	Count      int64          `json:"count"`
+	Dumped     string         `json:"dumped"`
+}
+
+type line struct {
+	Id      string         `json:"_id"`
+	Routing string         `json:"_routing,omitempty"`
+	Source  map[string]any `json:"_source"`
+}
+
+func metaFile(dir, index string) string {
+	return filepath.Join(dir, index+".meta.json")
+}
+
+func dataFile(dir, index string) string {
+	return filepath.Join(dir, index+".jsonl.gz")
+}
+
+func readMeta(dir, index string) (*meta, error) {
+
+	b, err := os.ReadFile(metaFile(dir, index))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &meta{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func writeMeta(dir string, m *meta) error {
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaFile(dir, m.Index), b, 0644)
+}
+
+type fileWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+func create(name string) (*fileWriter, error) {
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{f: f, gz: gzip.NewWriter(f)}, nil
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	return w.gz.Write(p)
+}
+
+func (w *fileWriter) Close() error {
+
+	if err := w.gz.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+type fileReader struct {
+	f  *os.File
+	gz *gzip.Reader
+}
+
+func open(name string) (*fileReader, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileReader{f: f, gz: gz}, nil
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	return r.gz.Read(p)
+}
+
+func (r *fileReader) Close() error {
+
+	r.gz.Close()
+	return r.f.Close()
+}
+
+// fieldFilter keeps the included fields (all when none) but the excluded ones,
+// a dotted path is a field inside an object
+type fieldFilter struct {
+	includes, excludes [][]string
+}
+
+func newFieldFilter(includes, excludes []string) fieldFilter {
+
+	split := func(paths []string) [][]string {
+		ps := make([][]string, 0, len(paths))
+		for _, p := range paths {
+			if p = strings.TrimSpace(p); len(p) > 0 {
+				ps = append(ps, strings.Split(p, "."))
+			}
+		}
+		return ps
+	}
+
+	return fieldFilter{includes: split(includes), excludes: split(excludes)}
+}
+
+// child returns the fields inside an object, under "properties" for a mapping
+func child(v any, nested string) map[string]any {
+
+	m, _ := v.(map[string]any)
+	if len(nested) > 0 {
+		m, _ = m[nested].(map[string]any)
+	}
+	return m
+}
+
+func pick(m map[string]any, paths [][]string, nested string) map[string]any {
+
+	tails := make(map[string][][]string)
+	whole := make(map[string]bool)
+
+	for _, p := range paths {
+		if len(p) == 1 {
+			whole[p[0]] = true
+		} else {
+			tails[p[0]] = append(tails[p[0]], p[1:])
+		}
+	}
+
+	out := make(map[string]any)
+	for k, v := range m {
+
+		if whole[k] {
+			out[k] = v
+			continue
+		}
+
+		ts, ok := tails[k]
+		if !ok {
+			continue
+		}
+
+		c := child(v, nested)
+		if c == nil {
+			// not an object, e.g. an array of objects in a doc
+			out[k] = v
+			continue
+		}
+
+		if len(nested) == 0 {
+			out[k] = pick(c, ts, nested)
+			continue
+		}
+
+		field := make(map[string]any)
+		for fk, fv := range v.(map[string]any) {
+			field[fk] = fv
+		}
+		field[nested] = pick(c, ts, nested)
+		out[k] = field
+	}
+
+	return out
+}
+
+func drop(m map[string]any, path []string, nested string) {
+
+	if len(path) == 1 {
+		delete(m, path[0])
+		return
+	}
+
+	if c := child(m[path[0]], nested); c != nil {
+		drop(c, path[1:], nested)
+	}
+}
+
+// apply filters the fields of a doc source, or of the mapping properties
+// when nested is "properties"
+func (f fieldFilter) apply(m map[string]any, nested string) map[string]any {
+
+	if len(f.includes) > 0 {
+		m = pick(m, f.includes, nested)
+	}
+
+	for _, p := range f.excludes {
+		drop(m, p, nested)
+	}
+
+	return m
+}
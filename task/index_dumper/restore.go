@@ -0,0 +1,149 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index_dumper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"log"
+)
+
+type Restorer struct {
+	cluster, index, dir string
+	targetIndex         string
+	filter              fieldFilter
+	appendDocs          bool
+}
+
+// NewRestorer creates a restorer of the dump of the index into the target index
+// (the same name when empty). includes and excludes filter the fields of the
+// docs and of the mapping. appendDocs loads the docs into an existing index.
+func NewRestorer(cluster, index, dir, targetIndex string, includes, excludes []string, appendDocs bool) *Restorer {
+
+	if len(targetIndex) == 0 {
+		targetIndex = index
+	}
+
+	return &Restorer{
+		cluster:     cluster,
+		index:       index,
+		dir:         dir,
+		targetIndex: targetIndex,
+		filter:      newFieldFilter(includes, excludes),
+		appendDocs:  appendDocs,
+	}
+}
+
+func (task *Restorer) prepare(ctx context.Context, e es.ElasticInstance, m *meta) error {
+
+	exist, err := e.IndexExistContext(ctx, task.targetIndex)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		if !task.appendDocs {
+			return fmt.Errorf("%s already exists, restore into it with append", task.targetIndex)
+		}
+		return nil
+	}
+
+	properties := task.filter.apply(m.Properties, "properties")
+
+	if err := e.CreateIndexSettingsContext(ctx, task.targetIndex, m.Settings, properties); err != nil {
+		return err
+	}
+
+	log.Printf("[%s] %s created", task.cluster, task.targetIndex)
+	return nil
+}
+
+func (task *Restorer) restoreDocs(ctx context.Context, e es.ElasticInstance) (*es.BulkReport, error) {
+
+	file, err := open(dataFile(task.dir, task.index))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	w := es.NewBulkWriter(ctx, e)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), maxLine)
+
+	n := 0
+	for scanner.Scan() {
+
+		n++
+
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return w.Report(), fmt.Errorf("line %d : %w", n, err)
+		}
+
+		// the meta as the reindexer writes it, 6.x needs a type
+		meta := map[string]any{"_index": task.targetIndex, "_id": l.Id}
+		if e.IsMajorVersion(6) {
+			meta["_type"] = task.targetIndex
+		}
+		if len(l.Routing) > 0 {
+			meta["routing"] = l.Routing
+		}
+
+		if err := w.Add(es.BulkIndex, meta, task.filter.apply(l.Source, "")); err != nil {
+			return w.Report(), err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return w.Report(), err
+	}
+
+	if err := w.Close(); err != nil {
+		return w.Report(), err
+	}
+
+	return w.Report(), nil
+}
+
+func (task *Restorer) Execute() bool {
+
+	ctx := context.Background()
+
+	m, err := readMeta(task.dir, task.index)
+	if err != nil {
+		log.Printf("[%s] no complete dump : %s", task.index, err)
+		return false
+	}
+
+	e := es.MustGet(task.cluster)
+
+	if err := task.prepare(ctx, e, m); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	report, err := task.restoreDocs(ctx, e)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf("[%s] %s", task.targetIndex, report)
+
+	if err := report.Err(); err != nil {
+		log.Printf("[%s] %d docs failed, first : %s", task.targetIndex, report.Failed, err)
+		return false
+	}
+
+	if int64(report.Succeeded) != m.Count {
+		log.Printf("[%s] %d docs restored, the dump has %d", task.targetIndex, report.Succeeded, m.Count)
+	}
+
+	return true
+}
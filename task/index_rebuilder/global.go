@@ -0,0 +1,158 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index_rebuilder
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/task/reindexer"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	CommandRebuild  = "rebuild"
+	CommandRollback = "rollback"
+	CommandStatus   = "status"
+)
+
+type IndexRebuilder struct {
+	cluster, alias             string
+	sourceCluster, sourceIndex string
+	template                   string
+
+	keep         int
+	minDocs      int64
+	minRatio     float64
+	replaceIndex bool
+}
+
+// New creates a rebuilder of the alias of the cluster, a rebuild reindexes
+// the source index of the source cluster into a new generation of the alias.
+// The generation is created with the template file (<kind>/<name>.json),
+// with the mapping of the source index when there is none.
+func New(cluster, alias, sourceCluster, sourceIndex, template string, keep int, minDocs int64, minRatio float64, replaceIndex bool) *IndexRebuilder {
+
+	if len(sourceCluster) == 0 {
+		sourceCluster = cluster
+	}
+
+	return &IndexRebuilder{
+		cluster:       cluster,
+		alias:         alias,
+		sourceCluster: sourceCluster,
+		sourceIndex:   sourceIndex,
+		template:      template,
+		keep:          keep,
+		minDocs:       minDocs,
+		minRatio:      minRatio,
+		replaceIndex:  replaceIndex,
+	}
+}
+
+func (task *IndexRebuilder) rebuild(ctx context.Context, e es.ElasticInstance) error {
+
+	if len(task.sourceIndex) == 0 {
+		return fmt.Errorf("no source index to rebuild %s from", task.alias)
+	}
+
+	opts := es.RebuildOptions{
+		MinDocs:      task.minDocs,
+		MinRatio:     task.minRatio,
+		Keep:         task.keep,
+		ReplaceIndex: task.replaceIndex,
+	}
+
+	if len(task.template) > 0 {
+
+		t, err := es.LoadTemplate(task.template)
+		if err != nil {
+			return err
+		}
+		opts.Template = &t
+
+	} else {
+
+		src := es.MustGet(task.sourceCluster)
+
+		properties, err := src.PropertiesContext(ctx, task.sourceIndex)
+		if err != nil {
+			return err
+		}
+		opts.Properties = properties
+	}
+
+	populate := func(ctx context.Context, index string) error {
+
+		r := reindexer.New(task.sourceCluster, task.cluster, task.sourceIndex, index, os.TempDir(), reindexer.DefaultPageSize, false, true)
+		if !r.Execute() {
+			return fmt.Errorf("reindex %s -> %s failed", task.sourceIndex, index)
+		}
+		return nil
+	}
+
+	_, err := e.Rebuild(ctx, task.alias, populate, opts)
+	return err
+}
+
+func (task *IndexRebuilder) status(ctx context.Context, e es.ElasticInstance) error {
+
+	current, err := e.AliasIndicesContext(ctx, task.alias)
+	if err != nil {
+		return err
+	}
+
+	generations, err := e.GenerationsContext(ctx, task.alias)
+	if err != nil {
+		return err
+	}
+
+	active := strings.Join(current, ",")
+
+	for _, g := range generations {
+
+		c, err := e.CountContext(ctx, g, "")
+		if err != nil {
+			return err
+		}
+
+		mark := " "
+		if strings.Contains(","+active+",", ","+g+",") {
+			mark = "*"
+		}
+		fmt.Printf("%s %-40s %d docs\n", mark, g, c)
+	}
+
+	return nil
+}
+
+// Execute runs the command (rebuild, rollback, status)
+func (task *IndexRebuilder) Execute(command string) bool {
+
+	ctx := context.Background()
+	e := es.MustGet(task.cluster)
+
+	var err error
+
+	switch command {
+	case CommandRebuild:
+		err = task.rebuild(ctx, e)
+	case CommandRollback:
+		_, err = e.Rollback(ctx, task.alias)
+	case CommandStatus:
+		err = task.status(ctx, e)
+	default:
+		err = fmt.Errorf("unknown command : %s", command)
+	}
+
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return true
+}
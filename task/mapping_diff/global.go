@@ -0,0 +1,101 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping_diff
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"log"
+)
+
+type MappingDiffer struct {
+	cluster, index             string
+	targetCluster, targetIndex string
+	template, kind             string
+}
+
+// New creates a differ of the mapping of the index of the cluster against the
+// target index of the target cluster, or against the template of the kind
+// (of the target cluster) when template is not empty
+func New(cluster, index, targetCluster, targetIndex, template, kind string) *MappingDiffer {
+
+	if len(targetCluster) == 0 {
+		targetCluster = cluster
+	}
+
+	if len(kind) == 0 {
+		kind = es.TemplateLegacy
+	}
+
+	return &MappingDiffer{
+		cluster:       cluster,
+		index:         index,
+		targetCluster: targetCluster,
+		targetIndex:   targetIndex,
+		template:      template,
+		kind:          kind,
+	}
+}
+
+func (task *MappingDiffer) target(ctx context.Context) (string, map[string]any, error) {
+
+	e := es.MustGet(task.targetCluster)
+
+	if len(task.template) == 0 {
+		properties, err := e.PropertiesContext(ctx, task.targetIndex)
+		return fmt.Sprintf("%s/%s", task.targetCluster, task.targetIndex), properties, err
+	}
+
+	t, err := e.GetTemplateContext(ctx, task.kind, task.template)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if t == nil {
+		return "", nil, fmt.Errorf("%s template %s does not exist", task.kind, task.template)
+	}
+
+	properties, err := e.TemplatePropertiesContext(ctx, *t)
+	return fmt.Sprintf("%s/%s", task.targetCluster, t), properties, err
+}
+
+func (task *MappingDiffer) Execute() bool {
+
+	ctx := context.Background()
+
+	e := es.MustGet(task.cluster)
+
+	from, err := e.PropertiesContext(ctx, task.index)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	name, to, err := task.target(ctx)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	changes := es.DiffMappings(from, to)
+	if len(changes) == 0 {
+		log.Printf("%s/%s and %s have the same mapping", task.cluster, task.index, name)
+		return true
+	}
+
+	fmt.Printf("%s/%s -> %s\n\n", task.cluster, task.index, name)
+
+	reindex := 0
+	for _, c := range changes {
+		if c.Reindex {
+			reindex++
+		}
+		fmt.Println(c)
+	}
+
+	fmt.Printf("\n%d changes, %d need a reindex, %d can be applied in place\n", len(changes), reindex, len(changes)-reindex)
+	return true
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrator
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/migrate"
+	"log"
+	"strconv"
+)
+
+const (
+	CommandUp     = "up"
+	CommandDown   = "down"
+	CommandStatus = "status"
+	CommandRedo   = "redo"
+)
+
+type Migrator struct {
+	section, dir string
+	dryRun       bool
+}
+
+// New creates a migrator running the migration files of dir against the db section
+func New(section, dir string, dryRun bool) *Migrator {
+
+	return &Migrator{section: section, dir: dir, dryRun: dryRun}
+}
+
+func (task *Migrator) status(m *migrate.Migrator) error {
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range status {
+
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt
+		}
+		if s.Dirty {
+			state += " (checksum mismatch)"
+		}
+		if s.Missing {
+			state += " (file missing)"
+		}
+
+		fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+	}
+
+	return nil
+}
+
+// Execute runs the command (up, down [N], status, redo)
+func (task *Migrator) Execute(command string, args ...string) bool {
+
+	migrations, err := migrate.Load(task.dir)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	m := migrate.New(database.MustGet(task.section), migrations)
+	m.DryRun(task.dryRun)
+
+	switch command {
+	case CommandUp:
+		err = m.Up()
+	case CommandDown:
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				log.Printf("invalid down count : %s", args[0])
+				return false
+			}
+		}
+		err = m.Down(n)
+	case CommandStatus:
+		err = task.status(m)
+	case CommandRedo:
+		err = m.Redo()
+	default:
+		err = fmt.Errorf("unknown command : %s", command)
+	}
+
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reindexer
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"path/filepath"
+)
+
+// Checkpoint is the progress of a reindex, saved after every page
+type Checkpoint struct {
+	file string
+
+	Source      string    `json:"source"`
+	Target      string    `json:"target"`
+	Index       string    `json:"index"`
+	TargetIndex string    `json:"target_index"`
+	Cursor      es.Cursor `json:"cursor"`
+	Read        int64     `json:"read"`
+	Written     int64     `json:"written"`
+	Done        bool      `json:"done"`
+	Updated     string    `json:"updated"`
+}
+
+// NewCheckpoint creates the checkpoint of the index in dir, one file per source and target
+func NewCheckpoint(dir, source, target, index, targetIndex string) *Checkpoint {
+
+	name := fmt.Sprintf("reindex.%s.%s.%s.%s.json", source, index, target, targetIndex)
+
+	return &Checkpoint{file: filepath.Join(dir, name), Source: source, Target: target, Index: index, TargetIndex: targetIndex}
+}
+
+// Load reads the saved checkpoint, a missing file is not an error
+func (c *Checkpoint) Load() error {
+
+	saved := Checkpoint{}
+	found, err := util.ReadJSON(c.file, &saved)
+	if err != nil || !found {
+		return err
+	}
+
+	c.Cursor, c.Read, c.Written, c.Done = saved.Cursor, saved.Read, saved.Written, saved.Done
+	return nil
+}
+
+// Save writes the checkpoint with the time of the update
+func (c *Checkpoint) Save() error {
+
+	c.Updated = util.FullTime()
+	return util.WriteJSON(c.file, c)
+}
+
+// Remove deletes the saved checkpoint, once the reindex is done
+func (c *Checkpoint) Remove() error {
+	return util.RemoveFile(c.file)
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reindexer
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/adapter"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"log"
+	"time"
+)
+
+const (
+	DefaultPageSize = 1000
+
+	keepAlive = 5 * time.Minute
+)
+
+// Transform changes the docs of a page before they are written, a doc removed
+// from the page is not written
+type Transform func(r *es.Response) error
+
+// AdapterTransform runs f on the page wrapped in an adapter, e.g. AdaptHashData
+func AdapterTransform(f func(a *adapter.Adapter)) Transform {
+
+	return func(r *es.Response) error {
+		f(adapter.New(r))
+		return nil
+	}
+}
+
+type Reindexer struct {
+	source, target     string
+	index, targetIndex string
+	checkpointDir      string
+	size               int
+	resume, verify     bool
+
+	query      string
+	tiebreaker string
+	transform  Transform
+}
+
+// New creates a reindexer of the index of the source cluster into the target
+// index of the target cluster. The docs are paged with search_after (in a point
+// in time on 7.10+), the progress is saved in checkpointDir.
+func New(source, target, index, targetIndex, checkpointDir string, size int, resume, verify bool) *Reindexer {
+
+	if len(targetIndex) == 0 {
+		targetIndex = index
+	}
+
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+
+	return &Reindexer{
+		source:        source,
+		target:        target,
+		index:         index,
+		targetIndex:   targetIndex,
+		checkpointDir: checkpointDir,
+		size:          size,
+		resume:        resume,
+		verify:        verify,
+		tiebreaker:    "_id",
+	}
+}
+
+// Query limits the docs to reindex, a search body ({"query": ...})
+func (task *Reindexer) Query(q string) {
+	task.query = q
+}
+
+// Tiebreaker sorts the pages by a field with a unique value per doc, _id by default.
+// A checkpoint resumed in a new point in time is valid with such a field only.
+func (task *Reindexer) Tiebreaker(field string) {
+	task.tiebreaker = field
+}
+
+func (task *Reindexer) Transform(t Transform) {
+	task.transform = t
+}
+
+// prepare creates the target index with the source mapping, the mapping
+// is converted between 6.x and 7.x by CreateIndex
+func (task *Reindexer) prepare(ctx context.Context, src, dst es.ElasticInstance) error {
+
+	exist, err := dst.IndexExistContext(ctx, task.targetIndex)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return nil
+	}
+
+	properties, err := src.PropertiesContext(ctx, task.index)
+	if err != nil {
+		return err
+	}
+
+	if err := dst.CreateIndexContext(ctx, task.targetIndex, properties); err != nil {
+		return err
+	}
+
+	log.Printf("[%s] %s created", task.target, task.targetIndex)
+	return nil
+}
+
+// meta returns the bulk action meta of the doc, 6.x needs a type,
+// the index name as CreateIndex does. A routed doc keeps its routing,
+// otherwise it lands on another shard than its source.
+func (task *Reindexer) meta(dst es.ElasticInstance, doc *es.Doc) map[string]any {
+
+	meta := map[string]any{"_index": task.targetIndex, "_id": doc.Id}
+	if dst.IsMajorVersion(6) {
+		meta["_type"] = task.targetIndex
+	}
+	if len(doc.Routing) > 0 {
+		meta["routing"] = doc.Routing
+	}
+	return meta
+}
+
+func (task *Reindexer) copy(ctx context.Context, src, dst es.ElasticInstance, cp *Checkpoint) error {
+
+	p := es.NewPaginator(src, &es.Request{Index: task.index, Query: task.query, Size: task.size, Scroll: keepAlive})
+	defer func() {
+		if err := p.Close(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	p.Tiebreaker(task.tiebreaker)
+
+	if len(cp.Cursor.SearchAfter) > 0 {
+		if err := p.From(cp.Cursor); err != nil {
+			return err
+		}
+		log.Printf("[%s] resuming after %d docs", task.index, cp.Read)
+	}
+
+	w := es.NewBulkWriter(ctx, dst)
+
+	for {
+
+		response, err := p.Next(ctx)
+		if err != nil {
+			return err
+		}
+
+		if response == nil {
+			break
+		}
+
+		cp.Read += int64(len(response.Result.Docs))
+
+		if task.transform != nil {
+			if err := task.transform(response); err != nil {
+				return err
+			}
+		}
+
+		for _, doc := range response.Result.Docs {
+			if err := w.Add(es.BulkIndex, task.meta(dst, doc), doc.Source.Map()); err != nil {
+				return err
+			}
+		}
+
+		// the checkpoint is saved once the page is written
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if err := w.Report().Err(); err != nil {
+			return fmt.Errorf("%d docs failed, first : %w", w.Report().Failed, err)
+		}
+
+		cp.Written += int64(len(response.Result.Docs))
+		cp.Cursor = p.Cursor()
+
+		if err := cp.Save(); err != nil {
+			return err
+		}
+
+		log.Printf("[%s] %d / %d docs reindexed", task.index, cp.Written, response.Result.TotalCount())
+	}
+
+	cp.Done = true
+	return cp.Save()
+}
+
+func (task *Reindexer) verifyCounts(ctx context.Context, src, dst es.ElasticInstance, cp *Checkpoint) error {
+
+	if err := dst.RefreshContext(ctx, task.targetIndex); err != nil {
+		return err
+	}
+
+	sc, err := src.CountContext(ctx, task.index, task.query)
+	if err != nil {
+		return err
+	}
+
+	tc, err := dst.CountContext(ctx, task.targetIndex, "")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[%s] source %d, read %d, written %d, target %d", task.index, sc, cp.Read, cp.Written, tc)
+
+	// the source may change during the reindex, and the target may hold other docs
+	if sc != cp.Read {
+		return fmt.Errorf("%s has %d docs, %d read", task.index, sc, cp.Read)
+	}
+
+	if tc < cp.Written {
+		return fmt.Errorf("%s has %d docs, %d written", task.targetIndex, tc, cp.Written)
+	}
+
+	return nil
+}
+
+func (task *Reindexer) Execute() bool {
+
+	ctx := context.Background()
+
+	src := es.MustGet(task.source)
+	dst := es.MustGet(task.target)
+
+	if err := task.prepare(ctx, src, dst); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	cp := NewCheckpoint(task.checkpointDir, task.source, task.target, task.index, task.targetIndex)
+
+	if task.resume {
+		if err := cp.Load(); err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if cp.Done {
+		log.Printf("[%s] already reindexed (%d docs)", task.index, cp.Written)
+	} else if err := task.copy(ctx, src, dst, cp); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	if task.verify {
+		if err := task.verifyCounts(ctx, src, dst, cp); err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if err := cp.Remove(); err != nil {
+		log.Println(err)
+	}
+
+	log.Printf("[%s] %s.%s -> %s.%s done (%d docs)", task.index, task.source, task.index, task.target, task.targetIndex, cp.Written)
+	return true
+}
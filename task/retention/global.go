@@ -0,0 +1,185 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package retention
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/internal/slack"
+	"log"
+	"strings"
+	"time"
+)
+
+type result struct {
+	policy    Policy
+	kept      int
+	deleted   []string
+	protected []string
+	failed    []string
+	err       error
+}
+
+type RetentionCleaner struct {
+	dryRun bool
+	name   string
+
+	results []result
+}
+
+// New creates a cleaner applying the retention policies of the config,
+// only the policy named name when it is not empty
+func New(name string, dryRun bool) *RetentionCleaner {
+
+	return &RetentionCleaner{name: name, dryRun: dryRun}
+}
+
+func (task *RetentionCleaner) cleanTables(p Policy, r *result) {
+
+	db := database.MustGet(p.Section)
+
+	keep, remove := p.Apply(db.Tables(p.Prefix), time.Now())
+	r.kept = len(keep)
+
+	for _, t := range remove {
+
+		if task.dryRun {
+			r.deleted = append(r.deleted, t)
+			continue
+		}
+
+		if db.Drop(t) {
+			r.deleted = append(r.deleted, t)
+		} else {
+			r.failed = append(r.failed, t)
+		}
+	}
+}
+
+func (task *RetentionCleaner) cleanIndices(p Policy, r *result) {
+
+	e := es.MustGet(p.Section)
+
+	// an index behind an alias is in use, it is never deleted
+	aliases := e.Aliases(p.Prefix)
+	if aliases == nil {
+		r.err = fmt.Errorf("can not read the aliases of %s*, nothing deleted", p.Prefix)
+		return
+	}
+
+	keep, remove := p.Apply(e.Indices(p.Prefix), time.Now())
+	r.kept = len(keep)
+
+	for _, index := range remove {
+
+		if as, ok := aliases[index]; ok {
+			r.protected = append(r.protected, fmt.Sprintf("%s (%s)", index, strings.Join(as, ", ")))
+			continue
+		}
+
+		if task.dryRun {
+			r.deleted = append(r.deleted, index)
+			continue
+		}
+
+		if e.DeleteIndex(index) {
+			r.deleted = append(r.deleted, index)
+		} else {
+			r.failed = append(r.failed, index)
+		}
+	}
+}
+
+func (task *RetentionCleaner) summary() string {
+
+	var b strings.Builder
+
+	title := "retention cleaner"
+	if task.dryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintf(&b, "[%s]\n", title)
+
+	verb := "deleted"
+	if task.dryRun {
+		verb = "to delete"
+	}
+
+	for _, r := range task.results {
+
+		fmt.Fprintf(&b, "%s\n", r.policy)
+
+		if r.err != nil {
+			fmt.Fprintf(&b, "  error : %v\n", r.err)
+			continue
+		}
+
+		fmt.Fprintf(&b, "  kept %d, %s %d", r.kept, verb, len(r.deleted))
+		if len(r.protected) > 0 {
+			fmt.Fprintf(&b, ", behind alias %d", len(r.protected))
+		}
+		if len(r.failed) > 0 {
+			fmt.Fprintf(&b, ", failed %d", len(r.failed))
+		}
+		b.WriteString("\n")
+
+		for _, n := range r.deleted {
+			fmt.Fprintf(&b, "  - %s\n", n)
+		}
+		for _, n := range r.protected {
+			fmt.Fprintf(&b, "  ! %s\n", n)
+		}
+		for _, n := range r.failed {
+			fmt.Fprintf(&b, "  x %s\n", n)
+		}
+	}
+
+	return b.String()
+}
+
+func (task *RetentionCleaner) Execute() bool {
+
+	policies, err := LoadPolicies()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	ok := true
+
+	for _, p := range policies {
+
+		if len(task.name) > 0 && p.Name != task.name {
+			continue
+		}
+
+		r := result{policy: p}
+
+		if p.Target == TargetES {
+			task.cleanIndices(p, &r)
+		} else {
+			task.cleanTables(p, &r)
+		}
+
+		if r.err != nil || len(r.failed) > 0 {
+			ok = false
+		}
+
+		task.results = append(task.results, r)
+	}
+
+	if len(task.results) == 0 {
+		log.Println("no retention policy")
+		return ok
+	}
+
+	s := task.summary()
+	log.Print(s)
+	slack.Post(constant.SlackChannelDefault, s)
+
+	return ok
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package retention
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/config"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"sort"
+	"strings"
+	"time"
+)
+
+// retention config, one section per prefix
+//
+// [retention.log_table]
+// target = db          ; db or es
+// section = main_db    ; db section or es cluster
+// prefix = log_
+// keep_days = 30       ; keep the ones dated in the last 30 days
+// keep_last = 7        ; keep the newest 7
+// min_keep = 1         ; never keep less than 1 (default)
+
+const (
+	TargetDB = "db"
+	TargetES = "es"
+)
+
+type Policy struct {
+	Name    string
+	Target  string
+	Section string
+	Prefix  string
+
+	KeepDays int
+	KeepLast int
+	MinKeep  int
+}
+
+func (p Policy) String() string {
+
+	rules := make([]string, 0)
+	if p.KeepDays > 0 {
+		rules = append(rules, fmt.Sprintf("keep %d days", p.KeepDays))
+	}
+	if p.KeepLast > 0 {
+		rules = append(rules, fmt.Sprintf("keep last %d", p.KeepLast))
+	}
+
+	return fmt.Sprintf("%s (%s %s* : %s)", p.Name, p.Target, p.Prefix, strings.Join(rules, ", "))
+}
+
+func (p Policy) validate() error {
+
+	if p.Target != TargetDB && p.Target != TargetES {
+		return fmt.Errorf("%s : not allowed target %s", p.Name, p.Target)
+	}
+
+	// an empty prefix would match every table or index
+	if len(p.Prefix) == 0 {
+		return fmt.Errorf("%s : no prefix", p.Name)
+	}
+
+	if p.KeepDays <= 0 && p.KeepLast <= 0 {
+		return fmt.Errorf("%s : no keep_days nor keep_last", p.Name)
+	}
+
+	return nil
+}
+
+// LoadPolicies reads the retention.* sections of the config
+func LoadPolicies() ([]Policy, error) {
+
+	policies := make([]Policy, 0)
+
+	for _, s := range config.Sections(constant.CKRetention) {
+
+		if !strings.HasPrefix(s.Name(), constant.CKRetention) {
+			continue
+		}
+
+		p := Policy{
+			Name:     s.Name(),
+			Target:   s.Key("target").MustString(TargetDB),
+			Prefix:   s.Key("prefix").String(),
+			KeepDays: s.Key("keep_days").MustInt(0),
+			KeepLast: s.Key("keep_last").MustInt(0),
+			MinKeep:  s.Key("min_keep").MustInt(1),
+		}
+
+		section := constant.CKDBMain
+		if p.Target == TargetES {
+			section = constant.CKECMain
+		}
+		p.Section = s.Key("section").MustString(section)
+
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+type dated struct {
+	name string
+	date time.Time
+}
+
+// Apply splits the names into the ones to keep and the ones to delete.
+// Names without a _YYYYMMDD suffix are never deleted.
+func (p Policy) Apply(names []string, now time.Time) (keep, remove []string) {
+
+	keep, remove = make([]string, 0), make([]string, 0)
+
+	ds := make([]dated, 0, len(names))
+	for _, name := range names {
+
+		if !strings.HasPrefix(name, p.Prefix) {
+			continue
+		}
+
+		date, err := time.ParseInLocation(constant.TimeFormat, util.IndexDate(name), now.Location())
+		if err != nil {
+			keep = append(keep, name)
+			continue
+		}
+		ds = append(ds, dated{name, date})
+	}
+
+	// newest first
+	sort.Slice(ds, func(i, j int) bool {
+		if ds[i].date.Equal(ds[j].date) {
+			return ds[i].name > ds[j].name
+		}
+		return ds[i].date.After(ds[j].date)
+	})
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	limit := today.AddDate(0, 0, -p.KeepDays)
+
+	for i, d := range ds {
+
+		kept := i < p.MinKeep ||
+			(p.KeepLast > 0 && i < p.KeepLast) ||
+			(p.KeepDays > 0 && !d.date.Before(limit))
+
+		if kept {
+			keep = append(keep, d.name)
+		} else {
+			remove = append(remove, d.name)
+		}
+	}
+
+	return keep, remove
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema_diff
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"log"
+	"strings"
+)
+
+type SchemaDiffer struct {
+	target, source, dir, prefix string
+	drop                        bool
+}
+
+// New creates a differ converging the target db section into the source
+// db section, or into the DDL files of dir when source is empty
+func New(target, source, dir, prefix string, drop bool) *SchemaDiffer {
+
+	return &SchemaDiffer{target: target, source: source, dir: dir, prefix: prefix, drop: drop}
+}
+
+func (task *SchemaDiffer) desired() (map[string]*database.TableSchema, error) {
+
+	if len(task.source) > 0 {
+		return database.MustGet(task.source).Schema(task.prefix)
+	}
+
+	schemas, err := database.LoadSchemaDir(task.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(task.prefix) > 0 {
+		for name := range schemas {
+			if !strings.HasPrefix(name, task.prefix) {
+				delete(schemas, name)
+			}
+		}
+	}
+
+	return schemas, nil
+}
+
+func (task *SchemaDiffer) Execute() bool {
+
+	current, err := database.MustGet(task.target).Schema(task.prefix)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	desired, err := task.desired()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	stmts := database.DiffSchemas(current, desired, database.SchemaDiffOptions{AllowDrop: task.drop})
+	if len(stmts) == 0 {
+		log.Printf("[%s] schema is up to date", task.target)
+		return true
+	}
+
+	for _, stmt := range stmts {
+		fmt.Println(stmt)
+	}
+
+	return true
+}
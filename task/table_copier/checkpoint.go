@@ -0,0 +1,60 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_copier
+
+import (
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"path/filepath"
+)
+
+// checkpoint is the progress of a copy, saved after every batch
+type checkpoint struct {
+	file string
+
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Table   string `json:"table"`
+	Key     string `json:"key"`
+	LastKey any    `json:"last_key"`
+	Copied  int64  `json:"copied"`
+	Done    bool   `json:"done"`
+	Updated string `json:"updated"`
+}
+
+func newCheckpoint(dir, source, target, table, key string) *checkpoint {
+
+	name := fmt.Sprintf("table_copy.%s.%s.%s.json", source, target, table)
+
+	return &checkpoint{file: filepath.Join(dir, name), Source: source, Target: target, Table: table, Key: key}
+}
+
+// load reads the saved checkpoint, a missing file is not an error
+func (c *checkpoint) load() error {
+
+	// keys stay exact, ReadJSON keeps the numbers of LastKey
+	saved := checkpoint{}
+	found, err := util.ReadJSON(c.file, &saved)
+	if err != nil || !found {
+		return err
+	}
+
+	if saved.Key != c.Key {
+		return fmt.Errorf("checkpoint key %s differs from %s : %s", saved.Key, c.Key, c.file)
+	}
+
+	c.LastKey, c.Copied, c.Done = saved.LastKey, saved.Copied, saved.Done
+	return nil
+}
+
+func (c *checkpoint) save() error {
+
+	c.Updated = util.FullTime()
+	return util.WriteJSON(c.file, c)
+}
+
+func (c *checkpoint) remove() error {
+	return util.RemoveFile(c.file)
+}
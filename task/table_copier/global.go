@@ -0,0 +1,243 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_copier
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"log"
+	"strings"
+)
+
+const DefaultBatchSize = 1000
+
+type TableCopier struct {
+	source, target     string
+	table, targetTable string
+	key, checkpointDir string
+	size               int
+	resume, verify     bool
+}
+
+// New creates a copier of the table of the source db section into the target
+// db section. The rows are streamed by key (the primary key when empty) and
+// upserted in batches, the progress is saved in checkpointDir.
+func New(source, target, table, targetTable, key, checkpointDir string, size int, resume, verify bool) *TableCopier {
+
+	if len(targetTable) == 0 {
+		targetTable = table
+	}
+
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	return &TableCopier{
+		source:        source,
+		target:        target,
+		table:         table,
+		targetTable:   targetTable,
+		key:           key,
+		checkpointDir: checkpointDir,
+		size:          size,
+		resume:        resume,
+		verify:        verify,
+	}
+}
+
+// prepare creates the target table from the source DDL, or checks that the
+// existing target table can hold the source rows
+func (task *TableCopier) prepare(src, dst *database.DB) (*database.TableSchema, error) {
+
+	stmt := src.CreateStatement(task.table)
+	if len(stmt.DDL) == 0 {
+		return nil, fmt.Errorf("can not get the create statement of %s", task.table)
+	}
+
+	schema, err := database.ParseCreateTable(stmt.DDL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dst.Exist(task.targetTable) {
+
+		if dst.Dialect().Name() != database.AdapterMySQL {
+			return nil, fmt.Errorf("%s does not exist, create it first on %s", task.targetTable, dst.Dialect().Name())
+		}
+
+		// the referenced tables may not be on the target, foreign keys are not copied
+		create := *schema
+		create.Name = task.targetTable
+		create.ForeignKeys = nil
+
+		if _, err := dst.Exec(create.CreateStatement()); err != nil {
+			return nil, err
+		}
+
+		log.Printf("[%s] %s created", task.target, task.targetTable)
+		return schema, nil
+	}
+
+	if dst.Dialect().Name() != database.AdapterMySQL {
+		log.Printf("[%s] schema check is supported on mysql only, skipped", task.target)
+		return schema, nil
+	}
+
+	current, err := dst.Describe(task.targetTable)
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := database.Incompatibilities(schema, current); len(problems) > 0 {
+		return nil, fmt.Errorf("%s is not compatible with %s : %s", task.targetTable, task.table, strings.Join(problems, ", "))
+	}
+
+	return schema, nil
+}
+
+func (task *TableCopier) streamKey(schema *database.TableSchema) (string, error) {
+
+	if len(task.key) > 0 {
+		return task.key, nil
+	}
+
+	pk := schema.PrimaryKey()
+	if len(pk) != 1 {
+		return "", fmt.Errorf("%s has no single column primary key, give the stream key", task.table)
+	}
+
+	return pk[0], nil
+}
+
+func columnsOf(schema *database.TableSchema) []string {
+
+	columns := make([]string, 0, len(schema.Columns))
+	for _, c := range schema.Columns {
+		columns = append(columns, c.Field)
+	}
+	return columns
+}
+
+func (task *TableCopier) copy(src, dst *database.DB, schema *database.TableSchema, cp *checkpoint) error {
+
+	columns := columnsOf(schema)
+
+	conflicts := schema.PrimaryKey()
+	if len(conflicts) == 0 {
+		conflicts = []string{cp.Key}
+	}
+
+	builder := src.DqlBuilder()
+	builder.Table(src.Dialect().Quote(task.table))
+
+	s := src.Stream(context.Background(), builder, cp.Key, task.size)
+	defer s.Close()
+
+	if cp.LastKey != nil {
+		s.From(cp.LastKey)
+		log.Printf("[%s] resuming after %s %v (%d rows copied)", task.table, cp.Key, cp.LastKey, cp.Copied)
+	}
+
+	rows := make([][]any, 0, task.size)
+
+	flush := func() error {
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if _, err := dst.UpsertRows(task.targetTable, columns, conflicts, rows); err != nil {
+			return err
+		}
+
+		cp.LastKey = s.LastKey()
+		cp.Copied += int64(len(rows))
+		rows = rows[:0]
+
+		if err := cp.save(); err != nil {
+			return err
+		}
+
+		log.Printf("[%s] %d rows copied (last %s %v)", task.table, cp.Copied, cp.Key, cp.LastKey)
+		return nil
+	}
+
+	for s.Next() {
+
+		row := s.Row()
+
+		values := make([]any, 0, len(columns))
+		for _, c := range columns {
+			values = append(values, row.Raw(c))
+		}
+		rows = append(rows, values)
+
+		if len(rows) >= task.size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Err() != nil {
+		return s.Err()
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	cp.Done = true
+	return cp.save()
+}
+
+func (task *TableCopier) Execute() bool {
+
+	src := database.MustGet(task.source)
+	dst := database.MustGet(task.target)
+
+	schema, err := task.prepare(src, dst)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	key, err := task.streamKey(schema)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	cp := newCheckpoint(task.checkpointDir, task.source, task.target, task.table, key)
+
+	if task.resume {
+		if err := cp.load(); err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if cp.Done {
+		log.Printf("[%s] already copied (%d rows)", task.table, cp.Copied)
+	} else if err := task.copy(src, dst, schema, cp); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	if task.verify {
+		if err := task.verifyCopy(src, dst, schema, key); err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if err := cp.remove(); err != nil {
+		log.Println(err)
+	}
+
+	log.Printf("[%s] %s.%s -> %s.%s done (%d rows)", task.table, task.source, task.table, task.target, task.targetTable, cp.Copied)
+	return true
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_copier
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"hash/crc32"
+	"log"
+	"strings"
+)
+
+// chunk is a key range [lo, hi] of the source table
+type chunk struct {
+	lo, hi any
+}
+
+type chunkSum struct {
+	Count    int64  `db:"count"`
+	Checksum uint64 `db:"checksum"`
+}
+
+// chunks splits the source keys into ranges of task.size rows
+func (task *TableCopier) chunks(src *database.DB, key string) ([]chunk, error) {
+
+	builder := src.DqlBuilder()
+	builder.Table(src.Dialect().Quote(task.table))
+	builder.AddColumn(src.Dialect().Quote(key))
+
+	s := src.Stream(context.Background(), builder, key, task.size)
+	defer s.Close()
+
+	cs := make([]chunk, 0)
+
+	var c *chunk
+	n := 0
+
+	for s.Next() {
+
+		if c == nil {
+			c = &chunk{lo: s.LastKey()}
+		}
+		c.hi = s.LastKey()
+		n++
+
+		if n == task.size {
+			cs = append(cs, *c)
+			c, n = nil, 0
+		}
+	}
+
+	if c != nil {
+		cs = append(cs, *c)
+	}
+
+	return cs, s.Err()
+}
+
+// serverChecksum lets mysql compute the checksum of a chunk
+func serverChecksum(db *database.DB, table, key string, columns []string, c chunk) (chunkSum, error) {
+
+	d := db.Dialect()
+
+	values := make([]string, 0, len(columns))
+	for _, col := range columns {
+		values = append(values, fmt.Sprintf("IFNULL(%s, '\\0')", d.Quote(col)))
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table(d.Quote(table))
+	builder.AddColumn("COUNT(*) AS count", fmt.Sprintf("COALESCE(BIT_XOR(CRC32(CONCAT_WS('#', %s))), 0) AS checksum", strings.Join(values, ", ")))
+	builder.AddCond(key, constant.GTE, c.lo)
+	builder.AddCond(key, constant.LTE, c.hi)
+
+	sum := chunkSum{}
+	err := db.GetBy(&sum, builder)
+	return sum, err
+}
+
+// clientChecksum reads the rows of a chunk and computes the checksum,
+// used when the dbs can not compute the same checksum by themselves
+func clientChecksum(db *database.DB, table, key string, columns []string, c chunk, size int) (chunkSum, error) {
+
+	builder := db.DqlBuilder()
+	builder.Table(db.Dialect().Quote(table))
+	builder.AddCond(key, constant.GTE, c.lo)
+	builder.AddCond(key, constant.LTE, c.hi)
+
+	s := db.Stream(context.Background(), builder, key, size)
+	defer s.Close()
+
+	sum := chunkSum{}
+
+	for s.Next() {
+
+		row := s.Row()
+
+		values := make([]string, 0, len(columns))
+		for _, col := range columns {
+			if v := row.Raw(col); v == nil {
+				values = append(values, "\x00")
+			} else {
+				values = append(values, fmt.Sprintf("%v", v))
+			}
+		}
+
+		sum.Count++
+		sum.Checksum ^= uint64(crc32.ChecksumIEEE([]byte(strings.Join(values, "#"))))
+	}
+
+	return sum, s.Err()
+}
+
+// verifyCopy compares the row count and checksum of every key chunk of source and target
+func (task *TableCopier) verifyCopy(src, dst *database.DB, schema *database.TableSchema, key string) error {
+
+	columns := columnsOf(schema)
+
+	cs, err := task.chunks(src, key)
+	if err != nil {
+		return err
+	}
+
+	server := src.Dialect().Name() == database.AdapterMySQL && dst.Dialect().Name() == database.AdapterMySQL
+
+	checksum := func(db *database.DB, table string, c chunk) (chunkSum, error) {
+		if server {
+			return serverChecksum(db, table, key, columns, c)
+		}
+		return clientChecksum(db, table, key, columns, c, task.size)
+	}
+
+	mismatches := 0
+
+	for _, c := range cs {
+
+		ss, err := checksum(src, task.table, c)
+		if err != nil {
+			return err
+		}
+
+		ts, err := checksum(dst, task.targetTable, c)
+		if err != nil {
+			return err
+		}
+
+		if ss != ts {
+			mismatches++
+			log.Printf("[%s] chunk %s %v ~ %v differs : source %d rows (%x), target %d rows (%x)",
+				task.table, key, c.lo, c.hi, ss.Count, ss.Checksum, ts.Count, ts.Checksum)
+		}
+	}
+
+	sc, tc := src.Count(task.table), dst.Count(task.targetTable)
+	log.Printf("[%s] verified %d chunks : source %d rows, target %d rows", task.table, len(cs), sc, tc)
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d chunks differ", mismatches, len(cs))
+	}
+
+	if tc < sc {
+		return fmt.Errorf("target has less rows than source : %d < %d", tc, sc)
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_dumper
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+type Exporter struct {
+	section, table, dir string
+	format, where, key  string
+	compress            bool
+	size                int
+}
+
+// NewExporter creates an exporter dumping the schema and the rows (filtered by where)
+// of the table. key is the unique column the rows are streamed by, the single
+// column primary key when empty.
+func NewExporter(section, table, dir, format, where, key string, compress bool, size int) *Exporter {
+
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	return &Exporter{section: section, table: table, dir: dir, format: format, where: where, key: key, compress: compress, size: size}
+}
+
+func (task *Exporter) streamKey(db *database.DB) (string, error) {
+
+	if len(task.key) > 0 {
+		return task.key, nil
+	}
+
+	schema, err := db.Describe(task.table)
+	if err != nil {
+		return "", fmt.Errorf("can not find the primary key, give the stream key : %v", err)
+	}
+
+	pk := schema.PrimaryKey()
+	if len(pk) != 1 {
+		return "", fmt.Errorf("%s has no single column primary key, give the stream key", task.table)
+	}
+
+	return pk[0], nil
+}
+
+func (task *Exporter) exportSchema(db *database.DB) error {
+
+	if db.Dialect().Name() != database.AdapterMySQL {
+		log.Printf("[%s] schema export is supported on mysql only, skipped", task.table)
+		return nil
+	}
+
+	stmt := db.CreateStatement(task.table)
+	if len(stmt.DDL) == 0 {
+		return fmt.Errorf("can not get the create statement of %s", task.table)
+	}
+
+	return os.WriteFile(schemaFile(task.dir, task.table), []byte(stmt.DDL+";\n"), 0644)
+}
+
+// value converts a scanned value for the dump files, the fractional
+// seconds of DATETIME(6) are kept
+func value(v any) any {
+
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999")
+	}
+	return v
+}
+
+// cell converts the value of a column for the csv and jsonl dumps, a binary
+// column is base64 encoded as its bytes are not valid utf-8
+func cell(binary map[string]bool, col string, v any) any {
+
+	v = value(v)
+	if s, ok := v.(string); ok && binary[col] {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	return v
+}
+
+// literal renders a value as a mysql literal
+func literal(v any) string {
+
+	switch x := value(v).(type) {
+	case nil:
+		return "NULL"
+	case string:
+		r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\x00", `\0`, "\x1a", `\Z`)
+		return "'" + r.Replace(x) + "'"
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+type rowWriter interface {
+	header(columns []string) error
+	write(columns []string, row *util.Interface) error
+	flush() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	binary map[string]bool
+}
+
+func (c *csvWriter) header(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) write(columns []string, row *util.Interface) error {
+
+	record := make([]string, 0, len(columns))
+	for _, col := range columns {
+		v := cell(c.binary, col, row.Raw(col))
+		if v == nil {
+			record = append(record, csvNull)
+		} else {
+			record = append(record, fmt.Sprintf("%v", v))
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	w      *bufio.Writer
+	enc    *json.Encoder
+	binary map[string]bool
+}
+
+func (j *jsonlWriter) header(columns []string) error {
+	return nil
+}
+
+func (j *jsonlWriter) write(columns []string, row *util.Interface) error {
+
+	doc := make(map[string]any, len(columns))
+	for _, col := range columns {
+		doc[col] = cell(j.binary, col, row.Raw(col))
+	}
+	return j.enc.Encode(doc)
+}
+
+func (j *jsonlWriter) flush() error {
+	return j.w.Flush()
+}
+
+// sqlWriter writes one multi row INSERT per line
+type sqlWriter struct {
+	w      *bufio.Writer
+	table  string
+	size   int
+	rows   []string
+	binary map[string]bool
+}
+
+func (s *sqlWriter) header(columns []string) error {
+	return nil
+}
+
+func (s *sqlWriter) write(columns []string, row *util.Interface) error {
+
+	values := make([]string, 0, len(columns))
+	for _, col := range columns {
+
+		// a binary column is a hex literal, its bytes are not valid utf-8
+		if b, ok := value(row.Raw(col)).(string); ok && s.binary[col] {
+			values = append(values, fmt.Sprintf("X'%x'", b))
+			continue
+		}
+		values = append(values, literal(row.Raw(col)))
+	}
+	s.rows = append(s.rows, "("+strings.Join(values, ",")+")")
+
+	if len(s.rows) >= s.size {
+		return s.insert(columns)
+	}
+	return nil
+}
+
+func (s *sqlWriter) insert(columns []string) error {
+
+	if len(s.rows) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quoted = append(quoted, "`"+col+"`")
+	}
+
+	_, err := fmt.Fprintf(s.w, "INSERT INTO `%s` (%s) VALUES %s;\n", s.table, strings.Join(quoted, ","), strings.Join(s.rows, ","))
+	s.rows = s.rows[:0]
+	return err
+}
+
+func (s *sqlWriter) flush() error {
+	return s.w.Flush()
+}
+
+// writer returns the row writer of the format, binary is filled with the
+// binary columns once the first row is read
+func (task *Exporter) writer(w io.Writer, binary map[string]bool) rowWriter {
+
+	switch task.format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw), binary: binary}
+	case FormatSQL:
+		return &sqlWriter{w: bufio.NewWriter(w), table: task.table, size: task.size, binary: binary}
+	default:
+		return &csvWriter{w: csv.NewWriter(w), binary: binary}
+	}
+}
+
+func (task *Exporter) exportData(db *database.DB) (int64, error) {
+
+	key, err := task.streamKey(db)
+	if err != nil {
+		return 0, err
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table(db.Dialect().Quote(task.table))
+	builder.AddWhere(task.where)
+
+	file, err := create(dataFile(task.dir, task.table, task.format, task.compress), task.compress)
+	if err != nil {
+		return 0, err
+	}
+
+	binary := make(map[string]bool)
+	w := task.writer(file, binary)
+
+	s := db.Stream(context.Background(), builder, key, task.size)
+	defer s.Close()
+
+	for s.Next() {
+
+		if s.Count() == 1 {
+
+			for col, t := range s.ColumnTypes() {
+				binary[col] = database.IsBinaryType(t)
+			}
+
+			if err := w.header(s.Columns()); err != nil {
+				file.Close()
+				return 0, err
+			}
+		}
+
+		row := s.Row()
+		if err := w.write(s.Columns(), &row); err != nil {
+			file.Close()
+			return s.Count(), err
+		}
+	}
+
+	if s.Err() != nil {
+		file.Close()
+		return s.Count(), s.Err()
+	}
+
+	if sw, ok := w.(*sqlWriter); ok && s.Count() > 0 {
+		if err := sw.insert(s.Columns()); err != nil {
+			file.Close()
+			return s.Count(), err
+		}
+	}
+
+	if err := w.flush(); err != nil {
+		file.Close()
+		return s.Count(), err
+	}
+
+	return s.Count(), file.Close()
+}
+
+func (task *Exporter) Execute() bool {
+
+	if !validFormat(task.format) {
+		log.Printf("not allowed format : %s", task.format)
+		return false
+	}
+
+	if err := os.MkdirAll(task.dir, 0755); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	db := database.MustGet(task.section)
+
+	if err := task.exportSchema(db); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	count, err := task.exportData(db)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf("[%s] %d rows exported to %s", task.table, count, dataFile(task.dir, task.table, task.format, task.compress))
+	return true
+}
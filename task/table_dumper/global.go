@@ -0,0 +1,131 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_dumper
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dump files of a table
+//
+// <dir>/<table>.schema.sql
+// <dir>/<table>.csv | <table>.jsonl | <table>.sql (+ .gz)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatSQL   = "sql"
+
+	ModeTruncate = "truncate"
+	ModeAppend   = "append"
+	ModeUpsert   = "upsert"
+
+	DefaultBatchSize = 1000
+
+	// csvNull is the csv value of NULL, the same as mysql LOAD DATA
+	csvNull = `\N`
+)
+
+func validFormat(f string) bool {
+	return f == FormatCSV || f == FormatJSONL || f == FormatSQL
+}
+
+func schemaFile(dir, table string) string {
+	return filepath.Join(dir, table+".schema.sql")
+}
+
+func dataFile(dir, table, format string, compress bool) string {
+
+	f := filepath.Join(dir, fmt.Sprintf("%s.%s", table, format))
+	if compress {
+		f += ".gz"
+	}
+	return f
+}
+
+type fileWriter struct {
+	f  *os.File
+	gz *gzip.Writer
+	io.Writer
+}
+
+func create(name string, compress bool) (*fileWriter, error) {
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fileWriter{f: f, Writer: f}
+	if compress {
+		w.gz = gzip.NewWriter(f)
+		w.Writer = w.gz
+	}
+
+	return w, nil
+}
+
+func (w *fileWriter) Close() error {
+
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+type fileReader struct {
+	f  *os.File
+	gz *gzip.Reader
+	io.Reader
+}
+
+func open(name string) (*fileReader, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &fileReader{f: f, Reader: f}
+
+	if strings.HasSuffix(name, ".gz") {
+		if r.gz, err = gzip.NewReader(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		r.Reader = r.gz
+	}
+
+	return r, nil
+}
+
+func (r *fileReader) Close() error {
+
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.f.Close()
+}
+
+// findDataFile returns the data file of the table, compressed or not
+func findDataFile(dir, table, format string) (string, error) {
+
+	for _, compress := range []bool{false, true} {
+		f := dataFile(dir, table, format, compress)
+		if _, err := os.Stat(f); err == nil {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %s dump of %s in %s", format, table, dir)
+}
@@ -0,0 +1,372 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package table_dumper
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Importer struct {
+	section, table, dir string
+	format, mode        string
+	conflicts           []string
+	size                int
+}
+
+// NewImporter creates an importer loading the dump of the table. mode is one of
+// truncate, append and upsert, conflicts are the unique key columns of upsert
+// (not needed on mysql).
+func NewImporter(section, table, dir, format, mode string, conflicts []string, size int) *Importer {
+
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	return &Importer{section: section, table: table, dir: dir, format: format, mode: mode, conflicts: conflicts, size: size}
+}
+
+func (task *Importer) validate(db *database.DB) error {
+
+	if !validFormat(task.format) {
+		return fmt.Errorf("not allowed format : %s", task.format)
+	}
+
+	switch task.mode {
+	case ModeTruncate, ModeAppend:
+	case ModeUpsert:
+		if task.format == FormatSQL {
+			return fmt.Errorf("upsert mode is not supported for sql dumps")
+		}
+		if db.Dialect().Name() != database.AdapterMySQL && len(task.conflicts) == 0 {
+			return fmt.Errorf("upsert mode needs the conflict columns on %s", db.Dialect().Name())
+		}
+	default:
+		return fmt.Errorf("not allowed mode : %s", task.mode)
+	}
+
+	if task.format == FormatSQL && db.Dialect().Name() != database.AdapterMySQL {
+		return fmt.Errorf("sql dumps are mysql statements, use csv or jsonl for %s", db.Dialect().Name())
+	}
+
+	return nil
+}
+
+// prepare creates the table from the schema file when missing
+func (task *Importer) prepare(db *database.DB) error {
+
+	if !db.Exist(task.table) {
+
+		b, err := os.ReadFile(schemaFile(task.dir, task.table))
+		if err != nil {
+			return fmt.Errorf("%s does not exist and has no schema file : %v", task.table, err)
+		}
+
+		if _, err := db.Exec(strings.TrimSuffix(strings.TrimSpace(string(b)), ";")); err != nil {
+			return err
+		}
+
+		log.Printf("[%s] table created", task.table)
+	}
+
+	return nil
+}
+
+// binaryColumns returns the binary columns of the table, base64 encoded in the csv and jsonl dumps
+func (task *Importer) binaryColumns(db *database.DB) (map[string]bool, error) {
+
+	types, err := db.ColumnTypes(task.table)
+	if err != nil {
+		return nil, err
+	}
+
+	binary := make(map[string]bool)
+	for col, t := range types {
+		binary[col] = database.IsBinaryType(t)
+	}
+	return binary, nil
+}
+
+func decodeBinary(binary map[string]bool, columns []string, rows [][]any) error {
+
+	for i, col := range columns {
+
+		if !binary[col] {
+			continue
+		}
+
+		for _, row := range rows {
+			if s, ok := row[i].(string); ok {
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("invalid base64 value of binary column %s : %w", col, err)
+				}
+				row[i] = b
+			}
+		}
+	}
+	return nil
+}
+
+// rowReader returns the columns and the rows of a dump in batches, io.EOF at the end
+type rowReader interface {
+	read(n int) ([]string, [][]any, error)
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func (c *csvReader) read(n int) ([]string, [][]any, error) {
+
+	if c.columns == nil {
+		header, err := c.r.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.columns = header
+	}
+
+	rows := make([][]any, 0, n)
+
+	for len(rows) < n {
+
+		record, err := c.r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := make([]any, len(record))
+		for i, v := range record {
+			if v == csvNull {
+				row[i] = nil
+			} else {
+				row[i] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return c.columns, nil, io.EOF
+	}
+	return c.columns, rows, nil
+}
+
+type jsonlReader struct {
+	dec     *json.Decoder
+	columns []string
+	index   map[string]int
+}
+
+func (j *jsonlReader) read(n int) ([]string, [][]any, error) {
+
+	rows := make([][]any, 0, n)
+
+	for len(rows) < n {
+
+		doc := make(map[string]any)
+		err := j.dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the columns of the first document, ordered by name
+		if j.columns == nil {
+			j.index = make(map[string]int)
+			for k := range doc {
+				j.columns = append(j.columns, k)
+			}
+			sort.Strings(j.columns)
+			for i, k := range j.columns {
+				j.index[k] = i
+			}
+		}
+
+		row := make([]any, len(j.columns))
+		for k, v := range doc {
+
+			i, ok := j.index[k]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown column in jsonl dump : %s", k)
+			}
+
+			// json columns are kept as json text
+			switch v.(type) {
+			case map[string]any, []any:
+				b, _ := json.Marshal(v)
+				v = string(b)
+			}
+			row[i] = v
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return j.columns, nil, io.EOF
+	}
+	return j.columns, rows, nil
+}
+
+func (task *Importer) importSQL(tx *database.Tx, r io.Reader) (int64, error) {
+
+	br := bufio.NewReader(r)
+	total := int64(0)
+
+	for {
+
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+
+		stmt := strings.TrimSpace(line)
+		if len(stmt) > 0 && !strings.HasPrefix(stmt, "--") {
+
+			result, err := tx.Exec(strings.TrimSuffix(stmt, ";"))
+			if err != nil {
+				return total, err
+			}
+
+			if n, err := result.RowsAffected(); err == nil {
+				total += n
+			}
+		}
+
+		if err == io.EOF {
+			return total, nil
+		}
+	}
+}
+
+func (task *Importer) importRows(db *database.DB, tx *database.Tx, rr rowReader, binary map[string]bool) (int64, error) {
+
+	total := int64(0)
+
+	for {
+
+		columns, rows, err := rr.read(task.size)
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		if err := decodeBinary(binary, columns, rows); err != nil {
+			return total, err
+		}
+
+		var n int64
+		if task.mode == ModeUpsert {
+			n, err = db.UpsertRowsTx(tx, task.table, columns, task.conflicts, rows)
+		} else {
+			n, err = db.InsertRowsTx(tx, task.table, columns, rows)
+		}
+
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+}
+
+// load empties the table in truncate mode and loads the dump in one transaction,
+// a failed import leaves the table as it was. The table is emptied with DELETE
+// as TRUNCATE commits the transaction on mysql.
+func (task *Importer) load(db *database.DB, r io.Reader, binary map[string]bool) (int64, error) {
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	if task.mode == ModeTruncate {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", db.Dialect().Quote(task.table))); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	var count int64
+
+	switch task.format {
+	case FormatSQL:
+		count, err = task.importSQL(tx, r)
+	case FormatJSONL:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		count, err = task.importRows(db, tx, &jsonlReader{dec: dec}, binary)
+	default:
+		count, err = task.importRows(db, tx, &csvReader{r: csv.NewReader(r)}, binary)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return count, err
+	}
+
+	return count, tx.Commit()
+}
+
+func (task *Importer) Execute() bool {
+
+	db := database.MustGet(task.section)
+
+	if err := task.validate(db); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	name, err := findDataFile(task.dir, task.table, task.format)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	if err := task.prepare(db); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	binary, err := task.binaryColumns(db)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	file, err := open(name)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	defer file.Close()
+
+	count, err := task.load(db, file, binary)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf("[%s] %d rows imported from %s (%s)", task.table, count, name, task.mode)
+	return true
+}
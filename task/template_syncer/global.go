@@ -0,0 +1,207 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template_syncer
+
+import (
+	"context"
+	"fmt"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// template changes, a template is updated when the file has a newer version or
+// another body, a cluster template without file is deleted by prune unless managed
+const (
+	ChangeCreate    = "create"
+	ChangeUpdate    = "update"
+	ChangeUnchanged = "unchanged"
+	ChangeOutdated  = "outdated"
+	ChangeExtra     = "extra"
+	ChangeDelete    = "delete"
+)
+
+type TemplateChange struct {
+	Kind    string
+	Name    string
+	Local   int
+	Remote  int
+	Change  string
+	Applied bool
+
+	template *es.IndexTemplate
+}
+
+type TemplateSyncer struct {
+	cluster, dir string
+	apply, prune bool
+
+	changes []TemplateChange
+}
+
+// New creates a syncer of the template files of dir (<kind>/<name>.json) to the
+// cluster, the changes are only reported unless apply
+func New(cluster, dir string, apply, prune bool) *TemplateSyncer {
+
+	return &TemplateSyncer{cluster: cluster, dir: dir, apply: apply, prune: prune}
+}
+
+func (task *TemplateSyncer) Changes() []TemplateChange {
+	return task.changes
+}
+
+func hasKindDir(dir, kind string) bool {
+
+	info, err := os.Stat(filepath.Join(dir, kind))
+	return err == nil && info.IsDir()
+}
+
+// diff compares the local templates with the cluster ones by version and body
+func (task *TemplateSyncer) diff(ctx context.Context, e es.ElasticInstance, locals []es.IndexTemplate) error {
+
+	task.changes = make([]TemplateChange, 0)
+
+	for _, kind := range es.TemplateKinds {
+
+		if !hasKindDir(task.dir, kind) {
+			continue
+		}
+
+		remotes, err := e.ListTemplatesContext(ctx, kind, "")
+		if err != nil {
+			return err
+		}
+
+		byName := make(map[string]es.IndexTemplate)
+		for _, r := range remotes {
+			byName[r.Name] = r
+		}
+
+		for i := range locals {
+
+			l := locals[i]
+			if l.Kind != kind {
+				continue
+			}
+
+			c := TemplateChange{Kind: kind, Name: l.Name, Local: l.Version, template: &locals[i]}
+
+			r, ok := byName[l.Name]
+			delete(byName, l.Name)
+
+			switch {
+			case !ok:
+				c.Change = ChangeCreate
+			case l.Version > 0 && l.Version < r.Version:
+				c.Remote, c.Change = r.Version, ChangeOutdated
+			case l.Version > r.Version || !l.SameBody(r):
+				c.Remote, c.Change = r.Version, ChangeUpdate
+			default:
+				c.Remote, c.Change = r.Version, ChangeUnchanged
+			}
+
+			task.changes = append(task.changes, c)
+		}
+
+		for _, r := range remotes {
+
+			if _, ok := byName[r.Name]; !ok {
+				continue
+			}
+
+			// the managed templates are installed again by the cluster, never pruned
+			c := TemplateChange{Kind: kind, Name: r.Name, Remote: r.Version, Change: ChangeExtra}
+			if task.prune && !r.Managed() {
+				c.Change = ChangeDelete
+			}
+			task.changes = append(task.changes, c)
+		}
+	}
+
+	return nil
+}
+
+// sync puts the created / updated templates in kind order, then deletes
+// the pruned ones in the reverse order
+func (task *TemplateSyncer) sync(ctx context.Context, e es.ElasticInstance) error {
+
+	for i := range task.changes {
+
+		c := &task.changes[i]
+		if c.Change != ChangeCreate && c.Change != ChangeUpdate {
+			continue
+		}
+
+		if err := e.PutTemplateContext(ctx, *c.template); err != nil {
+			return fmt.Errorf("%s/%s : %w", c.Kind, c.Name, err)
+		}
+		c.Applied = true
+	}
+
+	for i := len(task.changes) - 1; i >= 0; i-- {
+
+		c := &task.changes[i]
+		if c.Change != ChangeDelete {
+			continue
+		}
+
+		if err := e.DeleteTemplateContext(ctx, c.Kind, c.Name); err != nil {
+			return fmt.Errorf("%s/%s : %w", c.Kind, c.Name, err)
+		}
+		c.Applied = true
+	}
+
+	return nil
+}
+
+func (task *TemplateSyncer) report() {
+
+	for _, c := range task.changes {
+
+		state := ""
+		if c.Applied {
+			state = "(applied)"
+		}
+		fmt.Printf("%-10s %-10s %-40s local %-4d cluster %-4d %s\n", c.Change, c.Kind, c.Name, c.Local, c.Remote, state)
+	}
+}
+
+func (task *TemplateSyncer) Execute() bool {
+
+	ctx := context.Background()
+
+	locals, err := es.LoadTemplates(task.dir)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	e := es.MustGet(task.cluster)
+
+	if err := task.diff(ctx, e, locals); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	ok := true
+
+	if task.apply {
+		if err := task.sync(ctx, e); err != nil {
+			log.Println(err)
+			ok = false
+		}
+	}
+
+	task.report()
+
+	for _, c := range task.changes {
+		if c.Change == ChangeOutdated {
+			log.Printf("%s/%s : the cluster version %d is newer than the file version %d", c.Kind, c.Name, c.Remote, c.Local)
+		}
+	}
+
+	return ok
+}
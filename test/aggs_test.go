@@ -0,0 +1,178 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAggResult(t *testing.T) {
+
+	body := `{"aggregations":{
+		"by_category":{"buckets":[
+			{"key":"book","doc_count":3,
+			 "price":{"count":3,"min":1,"max":5,"avg":3,"sum":9},
+			 "variants":{"doc_count":7,"colors":{"value":4}},
+			 "top":{"hits":{"hits":[{"_index":"goods","_id":"g1","_source":{"name":"go"}}]}}},
+			{"key":"pen","doc_count":1}]},
+		"by_price":{"buckets":{"cheap":{"doc_count":2},"all":{"doc_count":4}}}}}`
+
+	var r map[string]any
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	var e es.ElasticInstance
+	root := e.AggregationResult(r)
+
+	buckets := root.Agg("by_category").Buckets()
+	if len(buckets) != 2 || buckets[0].KeyString() != "book" || buckets[1].DocCount() != 1 {
+		t.Fatalf("by_category buckets = %v", buckets)
+	}
+
+	book := buckets[0]
+	if got := book.Agg("price").Stats(); got.Sum != 9 || got.Count != 3 {
+		t.Errorf("price stats = %v (WANT:sum 9, count 3)", got)
+	}
+
+	if got := book.Agg("variants").Agg("colors").Value(); got != 4 {
+		t.Errorf("variants colors = %v (WANT:4)", got)
+	}
+
+	if hits := book.Agg("top").Hits(); len(hits) != 1 || hits[0].Id != "g1" || hits[0].String("name") != "go" {
+		t.Errorf("top hits = %v", hits)
+	}
+
+	// keyed buckets are sorted by key
+	if keyed := root.Agg("by_price").Buckets(); len(keyed) != 2 || keyed[0].KeyString() != "all" || keyed[0].DocCount() != 4 {
+		t.Errorf("by_price buckets = %v", keyed)
+	}
+
+	// a missing aggregation walks to zero values
+	if got := root.Agg("missing").Agg("deeper").Value(); got != 0 {
+		t.Errorf("missing value = %v (WANT:0)", got)
+	}
+}
+
+func TestPartitionCount(t *testing.T) {
+
+	var tests = []struct {
+		cdr, size int
+		want      int
+	}{
+		{1000, 100, 9},
+		{1200, 100, 10},
+		{50, 100, 1},
+		{0, 100, 0},
+	}
+
+	for _, test := range tests {
+		if got := es.PartitionCount(test.cdr, test.size); got != test.want {
+			t.Errorf("es.PartitionCount(%d,%d) = %v (WANT:%v)", test.cdr, test.size, got, test.want)
+		}
+	}
+}
+
+func TestDateIntervalKey(t *testing.T) {
+
+	var tests = []struct {
+		major, minor int
+		interval     string
+		want         string
+	}{
+		{7, 17, "1d", es.IntervalCalendar},
+		{7, 17, "month", es.IntervalCalendar},
+		{7, 17, "7d", es.IntervalFixed},
+		{7, 17, "90s", es.IntervalFixed},
+		{7, 2, "1M", es.IntervalCalendar},
+		// the intervals before 7.2 are neither
+		{7, 1, "1d", es.IntervalLegacy},
+		{6, 8, "7d", es.IntervalLegacy},
+	}
+
+	for _, test := range tests {
+
+		e := es.ElasticInstance{MajorVersion: test.major, MinorVersion: test.minor}
+		if got := e.DateIntervalKey(test.interval); got != test.want {
+			t.Errorf("DateIntervalKey(%d.%d, %s) = %v (WANT:%v)", test.major, test.minor, test.interval, got, test.want)
+		}
+	}
+}
+
+func TestCompositeIterator(t *testing.T) {
+
+	// two pages of two buckets and one, searched after the key of the body
+	pages := map[string]string{
+		"":  `{"after_key":{"k":"b"},"buckets":[{"key":{"k":"a"},"doc_count":1},{"key":{"k":"b"},"doc_count":2}]}`,
+		"a": `{"after_key":{"k":"c"},"buckets":[{"key":{"k":"b"},"doc_count":2},{"key":{"k":"c"},"doc_count":3}]}`,
+		"b": `{"after_key":{"k":"c"},"buckets":[{"key":{"k":"c"},"doc_count":3}]}`,
+		"c": `{"buckets":[]}`,
+	}
+
+	e := esServer(t, func(w http.ResponseWriter, r *http.Request) {
+
+		var body struct {
+			Aggs struct {
+				ByK struct {
+					Composite struct {
+						After struct {
+							K string `json:"k"`
+						} `json:"after"`
+					} `json:"composite"`
+				} `json:"by_k"`
+			} `json:"aggs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid search body : %v", err)
+		}
+
+		io.WriteString(w, `{"aggregations":{"by_k":`+pages[body.Aggs.ByK.Composite.After.K]+`}}`)
+	})
+
+	ctx := context.Background()
+	req := &es.Request{Index: "goods"}
+
+	keys := func(it *es.CompositeIterator, n int) []string {
+
+		ks := make([]string, 0)
+		for n < 0 || len(ks) < n {
+			b, err := it.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b == nil {
+				break
+			}
+			ks = append(ks, b.Key().(map[string]any)["k"].(string))
+		}
+		return ks
+	}
+
+	it := es.NewCompositeIterator(e, req, "by_k", es.NewCompositeAgg(2).TermsSource("k", "k"))
+	if got := keys(it, -1); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("keys = %v (WANT:%v)", got, []string{"a", "b", "c"})
+	}
+
+	// a key saved in the middle of a page resumes with the rest of the page
+	it = es.NewCompositeIterator(e, req, "by_k", es.NewCompositeAgg(2).TermsSource("k", "k"))
+	keys(it, 1)
+
+	after := it.AfterKey()
+	if !reflect.DeepEqual(after, map[string]any{"k": "a"}) {
+		t.Errorf("AfterKey() = %v (WANT:%v)", after, map[string]any{"k": "a"})
+	}
+
+	resumed := es.NewCompositeIterator(e, req, "by_k", es.NewCompositeAgg(2).TermsSource("k", "k"))
+	resumed.From(after)
+	if got := keys(resumed, -1); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("keys after %v = %v (WANT:%v)", after, got, []string{"b", "c"})
+	}
+}
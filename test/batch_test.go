@@ -0,0 +1,50 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/internal/database"
+	"testing"
+)
+
+func TestInsertRows(t *testing.T) {
+
+	db, err := database.Open(database.AdapterSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+	db.MustExec("CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+
+	columns := []string{"id", "name", "price"}
+
+	n, err := db.InsertRows("item", columns, [][]any{{1, "apple", 100}, {2, "pear", nil}, {3, "plum", 300}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("InsertRows() = %v (WANT:%v)", n, 3)
+	}
+
+	if _, err := db.UpsertRows("item", columns, []string{"id"}, [][]any{{2, "pear", 200}, {4, "kiwi", 400}}); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table("item")
+	builder.AddColumn("SUM(price)")
+	builder.AddWhere("name <> 'plum' OR price IS NULL")
+
+	sum := 0
+	if err := db.GetBy(&sum, builder); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 700 {
+		t.Errorf("SUM(price) = %v (WANT:%v)", sum, 700)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"github.com/alcomist/go-portfolio/internal/es"
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// esServer returns an instance of a 7.17 server answering the other
+// requests with handler
+func esServer(t *testing.T, handler http.HandlerFunc) es.ElasticInstance {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/" {
+			io.WriteString(w, `{"version":{"number":"7.17.10"},"tagline":"You Know, for Search"}`)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	e, err := es.ConnectConfig(context.Background(), "test", elasticsearch7.Config{Addresses: []string{ts.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestBulkWriterInvalidLine(t *testing.T) {
+
+	bodies := make([]string, 0)
+
+	e := esServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		io.WriteString(w, `{"took":1,"errors":false,"items":[{"index":{"_index":"goods","_id":"1","status":201}}]}`)
+	})
+
+	w := es.NewBulkWriter(context.Background(), e)
+
+	if _, err := w.Write([]byte("{\"index\":{\"_index\":\"goods\",\"_id\":\"0\"}, broken\n")); err == nil {
+		t.Errorf("Write(invalid action) = nil (WANT:error)")
+	}
+
+	item := "{\"index\":{\"_index\":\"goods\",\"_id\":\"1\"}}\n{\"name\":\"apple\"}\n"
+	if _, err := w.Write([]byte(item)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rejected line is not sent as the start of the next item
+	if len(bodies) != 1 || bodies[0] != item {
+		t.Errorf("bulk bodies = %q (WANT:%q)", bodies, []string{item})
+	}
+
+	if r := w.Report(); r.Added != 1 || r.Succeeded != 1 {
+		t.Errorf("Report() = added %d, succeeded %d (WANT:1, 1)", r.Added, r.Succeeded)
+	}
+}
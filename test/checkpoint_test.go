@@ -0,0 +1,86 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"github.com/alcomist/go-portfolio/task/reindexer"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReindexCheckpoint(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// a missing checkpoint starts from the beginning
+	cp := reindexer.NewCheckpoint(dir, "src", "dst", "goods", "goods_v2")
+	if err := cp.Load(); err != nil {
+		t.Fatalf("Load() = %v (WANT:nil)", err)
+	}
+	if len(cp.Cursor.SearchAfter) > 0 || cp.Read != 0 || cp.Done {
+		t.Errorf("Load() of no file = %+v (WANT:empty)", cp)
+	}
+
+	cp.Cursor = es.Cursor{
+		PitID:       "pit",
+		SearchAfter: []json.RawMessage{json.RawMessage(`1712345678901234567`), json.RawMessage(`"doc_9"`)},
+		Tiebreaker:  "_id",
+	}
+	cp.Read, cp.Written = 2000, 1998
+
+	if err := cp.Save(); err != nil {
+		t.Fatalf("Save() = %v (WANT:nil)", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("Save() files = %v (WANT:1 file, no tmp)", files)
+	}
+
+	saved := reindexer.NewCheckpoint(dir, "src", "dst", "goods", "goods_v2")
+	if err := saved.Load(); err != nil {
+		t.Fatalf("Load() = %v (WANT:nil)", err)
+	}
+
+	// the sort values are kept raw, a long id does not lose its precision
+	if !reflect.DeepEqual(saved.Cursor, cp.Cursor) || saved.Read != cp.Read || saved.Written != cp.Written || saved.Done {
+		t.Errorf("Load() = %+v (WANT:%+v)", saved, cp)
+	}
+
+	// the saved cursor is resumed by a paginator sorted by the same tiebreaker only
+	var e es.ElasticInstance
+	for _, tiebreaker := range []string{"_id", "uid"} {
+
+		p := es.NewPaginator(e, &es.Request{Index: "goods"})
+		p.Tiebreaker(tiebreaker)
+
+		err := p.From(saved.Cursor)
+		if (err == nil) != (tiebreaker == "_id") {
+			t.Errorf("From(%v) with %s = %v", saved.Cursor, tiebreaker, err)
+		}
+	}
+
+	// another target has its own checkpoint
+	other := reindexer.NewCheckpoint(dir, "src", "dst", "goods", "goods_v3")
+	if err := other.Load(); err != nil || other.Read != 0 {
+		t.Errorf("Load() of goods_v3 = %+v, %v (WANT:empty)", other, err)
+	}
+
+	if err := saved.Remove(); err != nil {
+		t.Fatalf("Remove() = %v (WANT:nil)", err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("Remove() left %s", files[0])
+	}
+
+	// removing twice is not an error
+	if err := saved.Remove(); err != nil {
+		t.Errorf("Remove() again = %v (WANT:nil)", err)
+	}
+}
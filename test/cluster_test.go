@@ -0,0 +1,91 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"errors"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"testing"
+	"time"
+)
+
+func openMemory(t *testing.T, closed bool) *database.DB {
+
+	db, err := database.Open(database.AdapterSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a closed db fails its ping, as an unreachable node
+	if closed {
+		db.Close()
+	} else {
+		t.Cleanup(func() { db.Close() })
+	}
+	return db
+}
+
+func TestClusterTarget(t *testing.T) {
+
+	primary := openMemory(t, false)
+	down := openMemory(t, true)
+	fresh := openMemory(t, false)
+	lagging := openMemory(t, false)
+	broken := openMemory(t, false)
+
+	lags := map[*database.DB]time.Duration{fresh: 0, lagging: 30 * time.Second}
+
+	lagOf := func(db *database.DB) (time.Duration, error) {
+		lag, ok := lags[db]
+		if !ok {
+			return 0, errors.New("replication is not running")
+		}
+		return lag, nil
+	}
+
+	var tests = []struct {
+		target   string
+		primary  *database.DB
+		replicas []*database.DB
+		want     []*database.DB
+	}{
+		// no fallback, even when the primary is down
+		{constant.TargetPrimaryOnly, down, []*database.DB{fresh}, []*database.DB{down}},
+		{constant.TargetPrimary, primary, []*database.DB{fresh}, []*database.DB{primary}},
+		{constant.TargetPrimary, down, []*database.DB{down, lagging}, []*database.DB{lagging}},
+		{constant.TargetPrimary, down, []*database.DB{down}, []*database.DB{down}},
+		// any healthy replica, the lag is ignored
+		{constant.TargetSecondary, primary, []*database.DB{down, lagging, broken}, []*database.DB{lagging}},
+		{constant.TargetSecondary, primary, []*database.DB{fresh, lagging}, []*database.DB{fresh, lagging}},
+		{constant.TargetSecondary, primary, []*database.DB{down, broken}, []*database.DB{primary}},
+		// a replica within max_lag, the primary otherwise
+		{constant.TargetMainIf, primary, []*database.DB{lagging, fresh, down}, []*database.DB{fresh}},
+		{"", primary, []*database.DB{fresh}, []*database.DB{fresh}},
+		{constant.TargetMainIf, primary, []*database.DB{lagging, broken, down}, []*database.DB{primary}},
+		{constant.TargetMainIf, primary, nil, []*database.DB{primary}},
+	}
+
+	for i, test := range tests {
+
+		c := database.NewCluster("main_db", test.primary, test.replicas...)
+		c.LagFunc(lagOf)
+		c.MaxLag(5 * time.Second)
+
+		// every replica is tried by the round robin
+		for n := 0; n < len(test.replicas)+1; n++ {
+
+			got := c.Target(test.target)
+
+			ok := false
+			for _, w := range test.want {
+				ok = ok || got == w
+			}
+			if !ok {
+				t.Errorf("%d : Target(%q) = %p (WANT one of %v)", i, test.target, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"testing"
+)
+
+func TestDialectInsertBuilder(t *testing.T) {
+
+	var tests = []struct {
+		adapter string
+		want    string
+	}{
+		{database.AdapterMySQL, "INSERT INTO `item` (`id`, `name`) VALUES(:id, :name) ON DUPLICATE KEY UPDATE `name`=:update_name;"},
+		{database.AdapterPostgres, `INSERT INTO "item" ("id", "name") VALUES(:id, :name) ON CONFLICT ("id") DO UPDATE SET "name"=:update_name;`},
+		{database.AdapterSQLite, `INSERT INTO "item" ("id", "name") VALUES(:id, :name) ON CONFLICT ("id") DO UPDATE SET "name"=:update_name;`},
+	}
+
+	for _, test := range tests {
+
+		builder := database.NewQueryBuilder(constant.QueryTypeInsert)
+		builder.Dialect(database.NewDialect(test.adapter))
+		builder.Table("item")
+		builder.AddSet("id", 1)
+		builder.AddSet("name", "a")
+		builder.AddUpdate("name", "a")
+		builder.AddConflict("id")
+
+		if got, _ := builder.Build(); got != test.want {
+			t.Errorf("insert builder (%s) = %v (WANT:%v)", test.adapter, got, test.want)
+		}
+	}
+}
+
+func TestDialectDqlBuilder(t *testing.T) {
+
+	var tests = []struct {
+		adapter string
+		want    string
+	}{
+		{database.AdapterMySQL, "SELECT * FROM item WHERE `id`>? AND `name`=? ORDER BY id ASC LIMIT 20, 10 "},
+		{database.AdapterPostgres, `SELECT * FROM item WHERE "id">$1 AND "name"=$2 ORDER BY id ASC LIMIT 10 OFFSET 20 `},
+		{database.AdapterSQLite, `SELECT * FROM item WHERE "id">? AND "name"=? ORDER BY id ASC LIMIT 10 OFFSET 20 `},
+	}
+
+	for _, test := range tests {
+
+		builder := database.NewDqlBuilder()
+		builder.Dialect(database.NewDialect(test.adapter))
+		builder.Table("item")
+		builder.AddCond("id", ">", 1)
+		builder.AddCond("name", constant.EQ, "a")
+		builder.AddOrder("id", constant.DBOrderAsc)
+		builder.Limit(10)
+		builder.Offset(20)
+
+		if got, _ := builder.Build(); got != test.want {
+			t.Errorf("dql builder (%s) = %v (WANT:%v)", test.adapter, got, test.want)
+		}
+	}
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+
+	db, err := database.Open(database.AdapterSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// every connection of an in-memory sqlite is a new database
+	db.SetMaxOpenConns(1)
+
+	create := db.QueryBuilder(constant.QueryTypeCreate)
+	create.Table("item")
+	create.AddSet("id", "pk")
+	create.AddSet("name", "string NOT NULL")
+	create.AddSet("price", "int")
+
+	q, _ := create.Build()
+	if db.Run(q, nil) == -1 {
+		t.Fatalf("create table failed : %s", q)
+	}
+
+	for _, price := range []int{100, 200} {
+
+		insert := db.QueryBuilder(constant.QueryTypeInsert)
+		insert.Table("item")
+		insert.AddSet("id", 1)
+		insert.AddSet("name", "apple")
+		insert.AddSet("price", price)
+		insert.AddUpdate("price", price)
+		insert.AddConflict("id")
+
+		q, arg := insert.Build()
+		if db.Run(q, arg) != 1 {
+			t.Fatalf("upsert failed : %s", q)
+		}
+	}
+
+	if got := db.Tables("it"); len(got) != 1 || got[0] != "item" {
+		t.Errorf("db.Tables(%q) = %v (WANT:%v)", "it", got, []string{"item"})
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table("item")
+	builder.AddColumn("price")
+	builder.AddCond("name", constant.EQ, "apple")
+
+	price := 0
+	if err := db.GetBy(&price, builder); err != nil {
+		t.Fatal(err)
+	}
+
+	if price != 200 {
+		t.Errorf("upserted price = %v (WANT:%v)", price, 200)
+	}
+}
@@ -6,6 +6,18 @@ replace github.com/alcomist/go-portfolio/internal => ./../internal
 
 replace github.com/alcomist/go-portfolio/task => ./../task
 
-require github.com/alcomist/go-portfolio/internal v0.0.0-00010101000000-000000000000
+require (
+	github.com/alcomist/go-portfolio/internal v0.0.0-00010101000000-000000000000
+	github.com/alcomist/go-portfolio/task v0.0.0-00010101000000-000000000000
+	github.com/elastic/go-elasticsearch/v7 v7.17.10
+)
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/go-sql-driver/mysql v1.7.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/jmoiron/sqlx v1.3.5 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/mattn/go-sqlite3 v1.14.22 // indirect
+	gopkg.in/ini.v1 v1.67.0 // indirect
+)
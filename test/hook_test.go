@@ -0,0 +1,132 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+
+	tests := []struct {
+		e    database.QueryEvent
+		want string
+	}{
+		{
+			database.QueryEvent{Query: "SELECT * FROM `user` WHERE `name` = ? AND `password` = ?", Args: []any{"o'neil", "1234"}},
+			"SELECT * FROM `user` WHERE `name` = 'o''neil' AND `password` = '[REDACTED]'",
+		},
+		{
+			database.QueryEvent{Query: "INSERT INTO user (id, api_token) VALUES ($1, $2)", Args: []any{7, "abc"}},
+			"INSERT INTO user (id, api_token) VALUES (7, '[REDACTED]')",
+		},
+		{
+			database.QueryEvent{Query: "UPDATE user SET secret = ?, memo = ? WHERE id = ?", Args: []any{"s", nil, 1},
+				NamedQuery: "UPDATE user SET secret = :set_secret, memo = :set_memo WHERE id = :id"},
+			"UPDATE user SET secret = '[REDACTED]', memo = NULL WHERE id = 1",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := database.Interpolate(&tt.e); got != tt.want {
+			t.Errorf("Interpolate() = %v (WANT:%v)", got, tt.want)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+
+	db, err := database.Open(database.AdapterSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	var buf bytes.Buffer
+	metrics := database.NewMetricsHook()
+
+	db.AddHook(database.NewLogHook(log.New(&buf, "", 0)))
+	db.AddHook(metrics)
+
+	db.MustExec("CREATE TABLE account (id INTEGER PRIMARY KEY, name TEXT, password TEXT)")
+
+	insert := db.QueryBuilder(constant.QueryTypeInsert)
+	insert.Table("account")
+	insert.AddSet("id", 1)
+	insert.AddSet("name", "kim")
+	insert.AddSet("password", "hunter2")
+
+	q, arg := insert.Build()
+	if db.Run(q, arg) != 1 {
+		t.Fatalf("insert failed : %s", q)
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table("account")
+	builder.AddColumn("name")
+	builder.AddCond("name", constant.EQ, "kim")
+
+	names := make([]string, 0)
+	if err := db.SelectBy(&names, builder); err != nil {
+		t.Fatal(err)
+	}
+
+	// the context variants pass through the hooks as well
+	ctx := context.Background()
+
+	if _, err := db.NamedExecContext(ctx, "UPDATE account SET name = :name WHERE id = :id", map[string]any{"name": "lee", "id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SelectContext(ctx, &names, "SELECT name FROM account"); err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+	if err := db.GetContext(ctx, &name, "SELECT name FROM account WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// and the statements of a transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Exec("UPDATE account SET name = ? WHERE id = ?", "park", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Get(&name, "SELECT name FROM account WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("log contains a password : %s", buf.String())
+	}
+
+	got := map[string]int64{}
+	for _, m := range metrics.Metrics() {
+		if m.Table == "account" {
+			got[m.Op] = m.Latency.Count
+		}
+	}
+
+	if got[database.QueryOpExec] != 4 || got[database.QueryOpQuery] != 4 {
+		t.Errorf("metrics(account) = %v (WANT:%v)", got, "exec 4, query 4")
+	}
+}
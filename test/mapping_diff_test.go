@@ -0,0 +1,67 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"reflect"
+	"testing"
+)
+
+func properties(t *testing.T, s string) map[string]any {
+
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("invalid properties %s : %v", s, err)
+	}
+	return m
+}
+
+func TestDiffMappings(t *testing.T) {
+
+	var tests = []struct {
+		from, to string
+		changes  []es.FieldChange
+		reindex  bool
+	}{
+		{`{"name":{"type":"text"}}`, `{"name":{"type":"text"}}`, []es.FieldChange{}, false},
+		// an added field is put in place, a removed one needs a reindex
+		{`{"name":{"type":"text"}}`, `{"name":{"type":"text"},"price":{"type":"long"}}`,
+			[]es.FieldChange{{Path: "price", Change: es.FieldAdded, To: "long"}}, false},
+		{`{"name":{"type":"text"},"price":{"type":"long"}}`, `{"name":{"type":"text"}}`,
+			[]es.FieldChange{{Path: "price", Change: es.FieldRemoved, From: "long", Reindex: true}}, true},
+		{`{"price":{"type":"integer"}}`, `{"price":{"type":"long"}}`,
+			[]es.FieldChange{{Path: "price", Change: es.FieldChanged, From: "integer", To: "long", Reindex: true}}, true},
+		// the fields of a nested path are dotted
+		{`{"options":{"type":"nested","properties":{"color":{"type":"keyword"}}}}`,
+			`{"options":{"type":"nested","properties":{"color":{"type":"text"},"size":{"type":"keyword"}}}}`,
+			[]es.FieldChange{
+				{Path: "options.color", Change: es.FieldChanged, From: "keyword", To: "text", Reindex: true},
+				{Path: "options.size", Change: es.FieldAdded, To: "keyword"},
+			}, true},
+		// a multi-field is under its field
+		{`{"title":{"type":"text"}}`, `{"title":{"type":"text","fields":{"keyword":{"type":"keyword"}}}}`,
+			[]es.FieldChange{{Path: "title.keyword", Change: es.FieldAdded, To: "keyword"}}, false},
+		// ignore_above is updatable, analyzer is not
+		{`{"code":{"type":"keyword","ignore_above":256}}`, `{"code":{"type":"keyword","ignore_above":512}}`,
+			[]es.FieldChange{{Path: "code ignore_above", Change: es.FieldChanged, From: "256", To: "512"}}, false},
+		{`{"title":{"type":"text","analyzer":"standard"}}`, `{"title":{"type":"text","analyzer":"nori"}}`,
+			[]es.FieldChange{{Path: "title analyzer", Change: es.FieldChanged, From: "standard", To: "nori", Reindex: true}}, true},
+	}
+
+	for _, test := range tests {
+
+		changes := es.DiffMappings(properties(t, test.from), properties(t, test.to))
+
+		if !reflect.DeepEqual(changes, test.changes) {
+			t.Errorf("DiffMappings(%s, %s) = %v (WANT:%v)", test.from, test.to, changes, test.changes)
+		}
+
+		if reindex := es.NeedsReindex(changes); reindex != test.reindex {
+			t.Errorf("NeedsReindex(%s, %s) = %v (WANT:%v)", test.from, test.to, reindex, test.reindex)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/migrate"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"0001_create_item.up.sql":   "-- item\nCREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT);",
+		"0001_create_item.down.sql": "DROP TABLE item;",
+		"0002_add_price.up.sql":     "ALTER TABLE item ADD COLUMN price INTEGER;\nCREATE INDEX idx_price ON item (price);",
+		"0002_add_price.down.sql":   "DROP INDEX idx_price;\nALTER TABLE item DROP COLUMN price;",
+		"0003_create_mall.up.sql":   "CREATE TABLE mall (id INTEGER PRIMARY KEY);",
+		"0003_create_mall.down.sql": "DROP TABLE mall;",
+		"README.md":                 "not a migration",
+	}
+
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := migrate.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(migrations); got != 3 {
+		t.Fatalf("len(Load()) = %v (WANT:%v)", got, 3)
+	}
+
+	if got := len(migrations[1].Up); got != 2 {
+		t.Errorf("len(Up) = %v (WANT:%v)", got, 2)
+	}
+
+	db, err := database.Open(database.AdapterSQLite, filepath.Join(dir, "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := migrate.New(db, migrations)
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !db.Exist("mall") {
+		t.Errorf("Exist(mall) = %v (WANT:%v)", false, true)
+	}
+
+	if err := m.Down(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Exist("mall") {
+		t.Errorf("Exist(mall) = %v (WANT:%v)", true, false)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := 0
+	for _, s := range status {
+		if s.Applied {
+			applied++
+		}
+	}
+
+	if applied != 1 {
+		t.Errorf("applied = %v (WANT:%v)", applied, 1)
+	}
+
+	// a changed migration file is refused
+	migrations[0].Checksum = "changed"
+	if err := m.Up(); err == nil {
+		t.Errorf("Up() with checksum mismatch = %v (WANT:%v)", nil, "error")
+	}
+}
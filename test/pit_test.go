@@ -0,0 +1,41 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"testing"
+)
+
+func TestPaginatorFrom(t *testing.T) {
+
+	after := []json.RawMessage{json.RawMessage(`"doc_9"`)}
+
+	var tests = []struct {
+		tiebreaker string
+		cursor     es.Cursor
+		ok         bool
+	}{
+		{"_id", es.Cursor{SearchAfter: after, Tiebreaker: "_id"}, true},
+		{"uid", es.Cursor{SearchAfter: after, Tiebreaker: "uid"}, true},
+		// _shard_doc values are valid in their point in time only
+		{"_id", es.Cursor{SearchAfter: after, Tiebreaker: "_shard_doc"}, false},
+		{"_id", es.Cursor{SearchAfter: after, Tiebreaker: "uid"}, false},
+		// a cursor without a position starts from the beginning
+		{"_id", es.Cursor{PitID: "pit"}, true},
+	}
+
+	for _, test := range tests {
+
+		var e es.ElasticInstance
+		p := es.NewPaginator(e, &es.Request{Index: "goods"})
+		p.Tiebreaker(test.tiebreaker)
+
+		if err := p.From(test.cursor); (err == nil) != test.ok {
+			t.Errorf("From(%v) with %s = %v (WANT ok:%v)", test.cursor, test.tiebreaker, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/internal/es"
+	"testing"
+)
+
+func TestQueryRange(t *testing.T) {
+
+	var tests = []struct {
+		r    es.QueryRange
+		want string
+	}{
+		{es.QueryRange{10, 20}, "price:[10 TO 20]"},
+		{es.QueryRange{-1, 20}, "price:[* TO 20]"},
+		{es.QueryRange{10, -1}, "price:[10 TO *]"},
+		{es.QueryRange{-10, 100}, "price:[-10 TO 100]"},
+		{es.QueryRange{-15, -100}, "price:[-15 TO -100]"},
+	}
+
+	for _, test := range tests {
+		b := es.NewQueryBuilder()
+		b.Add("price", test.r)
+		if got := b.QueryString(); got != test.want {
+			t.Errorf("QueryString(%v) = %v (WANT:%v)", test.r, got, test.want)
+		}
+	}
+}
+
+func TestSearchSource(t *testing.T) {
+
+	q := es.NewBoolQuery().
+		Must(es.NewMatchQuery("title", "go")).
+		Filter(es.NewRangeQuery("date").Gte("2024-01-01").Format("yyyy-MM-dd"))
+
+	got := es.NewSearchSource(q).Size(10).String()
+	want := `{"query":{"bool":{"filter":[{"range":{"date":{"format":"yyyy-MM-dd","gte":"2024-01-01"}}}],"must":[{"match":{"title":{"query":"go"}}}]}},"size":10}`
+
+	if got != want {
+		t.Errorf("SearchSource = %v (WANT:%v)", got, want)
+	}
+}
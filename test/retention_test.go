@@ -0,0 +1,59 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/task/retention"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyApply(t *testing.T) {
+
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	names := []string{
+		"log_20240310", "log_20240309", "log_20240301", "log_20240201",
+		"log_archive", "user_20240101",
+	}
+
+	var tests = []struct {
+		p      retention.Policy
+		keep   []string
+		remove []string
+	}{
+		// the day keep_days ago is kept, names without a date are never removed
+		{
+			retention.Policy{Prefix: "log_", KeepDays: 9, MinKeep: 1},
+			[]string{"log_archive", "log_20240310", "log_20240309", "log_20240301"},
+			[]string{"log_20240201"},
+		},
+		{
+			retention.Policy{Prefix: "log_", KeepLast: 2, MinKeep: 1},
+			[]string{"log_archive", "log_20240310", "log_20240309"},
+			[]string{"log_20240301", "log_20240201"},
+		},
+		// min_keep wins over keep_days
+		{
+			retention.Policy{Prefix: "log_", KeepDays: 1, MinKeep: 3},
+			[]string{"log_archive", "log_20240310", "log_20240309", "log_20240301"},
+			[]string{"log_20240201"},
+		},
+		// the other prefixes are not touched
+		{
+			retention.Policy{Prefix: "user_", KeepDays: 1, MinKeep: 0},
+			[]string{},
+			[]string{"user_20240101"},
+		},
+	}
+
+	for _, test := range tests {
+		keep, remove := test.p.Apply(names, now)
+		if !reflect.DeepEqual(keep, test.keep) || !reflect.DeepEqual(remove, test.remove) {
+			t.Errorf("%v Apply() = %v, %v (WANT:%v, %v)", test.p, keep, remove, test.keep, test.remove)
+		}
+	}
+}
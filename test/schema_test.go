@@ -0,0 +1,154 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"github.com/alcomist/go-portfolio/internal/database"
+	"strings"
+	"testing"
+)
+
+const currentDDL = "CREATE TABLE `item` (\n" +
+	"  `id` bigint(20) NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(100) COLLATE utf8mb4_unicode_ci DEFAULT NULL,\n" +
+	"  `price` int(11) NOT NULL DEFAULT '0',\n" +
+	"  `legacy` tinyint(1) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_name` (`name`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=10 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+
+const desiredDDL = "CREATE TABLE `item` (\n" +
+	"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(255) COLLATE utf8mb4_unicode_ci DEFAULT NULL,\n" +
+	"  `price` int NOT NULL DEFAULT '0',\n" +
+	"  `mall_id` bigint NOT NULL,\n" +
+	"  `mtime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uk_name` (`name`(50),`mall_id`),\n" +
+	"  CONSTRAINT `fk_mall` FOREIGN KEY (`mall_id`) REFERENCES `mall` (`id`) ON DELETE CASCADE\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+
+func TestParseCreateTable(t *testing.T) {
+
+	s, err := database.ParseCreateTable(desiredDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(s.Columns); got != 5 {
+		t.Errorf("len(Columns) = %v (WANT:%v)", got, 5)
+	}
+
+	if got := strings.Join(s.PrimaryKey(), ","); got != "id" {
+		t.Errorf("PrimaryKey() = %v (WANT:%v)", got, "id")
+	}
+
+	uk, ok := s.Index("uk_name")
+	if !ok || !uk.Unique || strings.Join(uk.Columns, ",") != "name(50),mall_id" {
+		t.Errorf("Index(uk_name) = %v (WANT:%v)", uk, "unique name(50),mall_id")
+	}
+
+	fk, ok := s.ForeignKey("fk_mall")
+	if !ok || fk.RefTable != "mall" || fk.OnDelete != "CASCADE" {
+		t.Errorf("ForeignKey(fk_mall) = %v (WANT:%v)", fk, "mall(id) ON DELETE CASCADE")
+	}
+
+	mtime, _ := s.Column("mtime")
+	if mtime.Extra != "on update current_timestamp" || mtime.Default == nil || *mtime.Default != "CURRENT_TIMESTAMP" {
+		t.Errorf("Column(mtime) = %v (WANT:%v)", mtime, "DEFAULT CURRENT_TIMESTAMP ON UPDATE")
+	}
+}
+
+func TestParseTruncatedColumn(t *testing.T) {
+
+	ddl := "CREATE TABLE `item` (\n" +
+		"  `id` bigint NOT NULL,\n" +
+		"  `price` int NOT NULL DEFAULT\n" +
+		") ENGINE=InnoDB"
+
+	s, err := database.ParseCreateTable(ddl)
+	if err != nil {
+		return
+	}
+
+	if price, ok := s.Column("price"); ok && price.Default != nil {
+		t.Errorf("Column(price).Default = %v (WANT:%v)", *price.Default, nil)
+	}
+}
+
+func TestParseTruncatedForeignKey(t *testing.T) {
+
+	var tests = []string{
+		"CONSTRAINT `fk_item_user` FOREIGN KEY",
+		"CONSTRAINT `fk_item_user` FOREIGN KEY `fk_user`",
+		"FOREIGN KEY (`user_id`) REFERENCES",
+		"FOREIGN KEY (`user_id`) REFERENCES `user`",
+		"FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE",
+	}
+
+	for _, def := range tests {
+
+		ddl := "CREATE TABLE `item` (\n  `id` bigint NOT NULL,\n  " + def + "\n) ENGINE=InnoDB"
+
+		// a truncated definition must not panic
+		s, err := database.ParseCreateTable(ddl)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := s.Column("id"); !ok {
+			t.Errorf("ParseCreateTable(%s) lost the column id", def)
+		}
+	}
+}
+
+func TestDiffTable(t *testing.T) {
+
+	current, err := database.ParseCreateTable(currentDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desired, err := database.ParseCreateTable(desiredDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ALTER TABLE `item` DROP INDEX `idx_name`;",
+		"ALTER TABLE `item` MODIFY COLUMN `name` varchar(255) NULL DEFAULT NULL;",
+		"ALTER TABLE `item` ADD COLUMN `mall_id` bigint NOT NULL AFTER `price`;",
+		"ALTER TABLE `item` ADD COLUMN `mtime` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER `mall_id`;",
+		"ALTER TABLE `item` DROP COLUMN `legacy`;",
+		"ALTER TABLE `item` ADD UNIQUE KEY `uk_name` (`name`(50), `mall_id`);",
+		"ALTER TABLE `item` ADD CONSTRAINT `fk_mall` FOREIGN KEY (`mall_id`) REFERENCES `mall` (`id`) ON DELETE CASCADE;",
+	}
+
+	got := database.DiffTable(current, desired, database.SchemaDiffOptions{AllowDrop: true})
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("DiffTable\n%v\n(WANT:\n%v)", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the desired schema applied to itself has no changes
+	if got := database.DiffTable(desired, desired, database.SchemaDiffOptions{}); len(got) != 0 {
+		t.Errorf("DiffTable(desired, desired) = %v (WANT:%v)", got, []string{})
+	}
+}
+
+func TestIncompatibilities(t *testing.T) {
+
+	current, _ := database.ParseCreateTable(currentDDL)
+	desired, _ := database.ParseCreateTable(desiredDDL)
+
+	if got := database.Incompatibilities(current, current); got != nil {
+		t.Errorf("Incompatibilities(current, current) = %v (WANT:%v)", got, nil)
+	}
+
+	// legacy is missing and name is narrower in desired
+	if got := database.Incompatibilities(current, desired); len(got) != 2 {
+		t.Errorf("Incompatibilities(current, desired) = %v (WANT:%v)", got, 2)
+	}
+}
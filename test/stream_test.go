@@ -0,0 +1,96 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"github.com/alcomist/go-portfolio/internal/constant"
+	"github.com/alcomist/go-portfolio/internal/database"
+	"github.com/alcomist/go-portfolio/internal/util"
+	"path/filepath"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+
+	db, err := database.Open(database.AdapterSQLite, filepath.Join(t.TempDir(), "stream.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.MustExec("CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+	for i := 1; i <= 25; i++ {
+		db.MustExec("INSERT INTO item (id, name, price) VALUES (?, ?, ?)", i, "item", i*10)
+	}
+
+	builder := db.DqlBuilder()
+	builder.Table("item")
+	builder.AddCond("price", constant.GT, 20)
+
+	s := db.Stream(context.Background(), builder, "id", 10)
+
+	ids := make([]int, 0)
+	for s.Next() {
+		row := s.Row()
+		ids = append(ids, row.Int("id"))
+	}
+
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	if len(ids) != 23 || ids[0] != 3 || ids[22] != 25 {
+		t.Errorf("Stream() = %v (WANT:%v)", ids, "3..25")
+	}
+
+	// resume after a saved key, decoding into structs
+	type item struct {
+		ID    int    `db:"id"`
+		Name  string `db:"name"`
+		Price int    `db:"price"`
+	}
+
+	s = db.Stream(context.Background(), builder, "id", 10).From(20)
+
+	items := make([]item, 0)
+	for s.Next() {
+		var it item
+		if err := s.Scan(&it); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, it)
+	}
+
+	if len(items) != 5 || items[0].ID != 21 || items[4].Price != 250 {
+		t.Errorf("Stream().From(20) = %v (WANT:%v)", items, "21..25")
+	}
+
+	if got := util.ToInt64(s.LastKey()); got != 25 {
+		t.Errorf("LastKey() = %v (WANT:%v)", got, 25)
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+
+	db, err := database.Open(database.AdapterSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.MustExec("CREATE TABLE file (id INTEGER PRIMARY KEY, name TEXT, body BLOB)")
+
+	types, err := db.ColumnTypes("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for col, want := range map[string]bool{"id": false, "name": false, "body": true} {
+		if got := database.IsBinaryType(types[col]); got != want {
+			t.Errorf("IsBinaryType(%s %s) = %v (WANT:%v)", col, types[col], got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 30K Dev. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alcomist/go-portfolio/internal/es"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func template(t *testing.T, body string) es.IndexTemplate {
+
+	var m map[string]any
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		t.Fatalf("invalid body %s : %v", body, err)
+	}
+	return es.IndexTemplate{Kind: es.TemplateComposable, Name: "goods", Body: m}
+}
+
+func TestTemplateSameBody(t *testing.T) {
+
+	var tests = []struct {
+		local, remote string
+		same          bool
+	}{
+		// the cluster returns the settings flattened under index. as strings
+		{`{"index_patterns":["goods*"],"template":{"settings":{"number_of_shards":1}}}`,
+			`{"index_patterns":["goods*"],"template":{"settings":{"index":{"number_of_shards":"1"}}}}`, true},
+		// and drops the empty objects, the order 0 of a legacy template is the default
+		{`{"index_patterns":["goods*"],"aliases":{},"order":0}`,
+			`{"index_patterns":["goods*"]}`, true},
+		// an edited file of the same version is not the same
+		{`{"version":2,"template":{"mappings":{"properties":{"name":{"type":"text"}}}}}`,
+			`{"version":2,"template":{"mappings":{"properties":{"name":{"type":"keyword"}}}}}`, false},
+		{`{"index_patterns":["goods*"],"template":{"settings":{"number_of_replicas":1}}}`,
+			`{"index_patterns":["goods*"],"template":{"settings":{"index":{"number_of_replicas":"2"}}}}`, false},
+		// a field named settings is a mapping, not flattened
+		{`{"template":{"mappings":{"properties":{"settings":{"type":"object"}}}}}`,
+			`{"template":{"mappings":{"properties":{"settings":{"type":"object"}}}}}`, true},
+		{`{"version":1,"index_patterns":["goods*"]}`, `{"version":2,"index_patterns":["goods*"]}`, false},
+	}
+
+	for _, test := range tests {
+
+		local, remote := template(t, test.local), template(t, test.remote)
+		if got := local.SameBody(remote); got != test.same {
+			t.Errorf("SameBody(%s, %s) = %v (WANT:%v)", test.local, test.remote, got, test.same)
+		}
+	}
+}
+
+func TestTemplateManaged(t *testing.T) {
+
+	var tests = []struct {
+		body    string
+		managed bool
+	}{
+		{`{"index_patterns":["logs-*-*"],"_meta":{"description":"default logs template","managed":true}}`, true},
+		{`{"index_patterns":["goods*"],"_meta":{"managed":false}}`, false},
+		{`{"index_patterns":["goods*"]}`, false},
+	}
+
+	for _, test := range tests {
+
+		if got := template(t, test.body).Managed(); got != test.managed {
+			t.Errorf("Managed(%s) = %v (WANT:%v)", test.body, got, test.managed)
+		}
+	}
+}
+
+func TestTemplateProperties(t *testing.T) {
+
+	components := map[string]string{
+		"goods_base":  `{"template":{"mappings":{"properties":{"name":{"type":"keyword"},"options":{"properties":{"color":{"type":"keyword"}}}}}}}`,
+		"goods_price": `{"template":{"mappings":{"properties":{"price":{"type":"long"}}}}}`,
+	}
+
+	e := esServer(t, func(w http.ResponseWriter, r *http.Request) {
+
+		name := strings.TrimPrefix(r.URL.Path, "/_component_template/")
+		body, ok := components[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":{"type":"resource_not_found_exception"},"status":404}`)
+			return
+		}
+		io.WriteString(w, `{"component_templates":[{"name":"`+name+`","component_template":`+body+`}]}`)
+	})
+
+	var tests = []struct {
+		body, want string
+	}{
+		// the components in order, then the template itself, the object fields merged
+		{`{"index_patterns":["goods*"],"composed_of":["goods_base","goods_price"],
+			"template":{"mappings":{"properties":{"name":{"type":"text"},"options":{"properties":{"size":{"type":"keyword"}}}}}}}`,
+			`{"name":{"type":"text"},"price":{"type":"long"},
+			"options":{"properties":{"color":{"type":"keyword"},"size":{"type":"keyword"}}}}`},
+		// the fields of a composable template without mappings all come from its components
+		{`{"index_patterns":["goods*"],"composed_of":["goods_price"]}`, `{"price":{"type":"long"}}`},
+		{`{"index_patterns":["goods*"],"template":{"mappings":{"properties":{"name":{"type":"text"}}}}}`,
+			`{"name":{"type":"text"}}`},
+	}
+
+	for _, test := range tests {
+
+		got, err := e.TemplatePropertiesContext(context.Background(), template(t, test.body))
+		if err != nil {
+			t.Errorf("TemplatePropertiesContext(%s) : %v", test.body, err)
+			continue
+		}
+
+		if want := properties(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("TemplatePropertiesContext(%s) = %v (WANT:%v)", test.body, got, want)
+		}
+	}
+
+	// a missing component is an error, not a mapping without its fields
+	missing := template(t, `{"index_patterns":["goods*"],"composed_of":["goods_base","goods_stock"]}`)
+	if _, err := e.TemplatePropertiesContext(context.Background(), missing); err == nil {
+		t.Errorf("TemplatePropertiesContext(missing component) = nil (WANT:error)")
+	}
+}